@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
@@ -15,7 +17,9 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
-	"github.com/xiaozhe/dedupgo/internal/core"
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
 )
 
 func init() {
@@ -100,31 +104,53 @@ func main() {
 		fyne.TextStyle{Italic: true},
 	)
 
+	// 已选择的目录，勾选“参考”的目录作为主库，其中的文件永远保留
+	var selectedPaths []string
+	referencePaths := make(map[string]bool)
+
 	// 创建主要控件
-	pathList := widget.NewMultiLineEntry()
-	pathList.SetText("等待添加扫描目录...\n")
-	pathList.Disable()
-	pathList.TextStyle = fyne.TextStyle{Monospace: true}
-	pathList.Wrapping = fyne.TextWrapBreak
-
-	// 使用 MultiLineEntry 替代 TextGrid
-	resultArea := widget.NewMultiLineEntry()
-	resultArea.SetText("\n  📊扫描结果将在这里显示\n\n")
-	resultArea.Disable()
-	resultArea.TextStyle = fyne.TextStyle{Monospace: true}
-	resultArea.Wrapping = fyne.TextWrapBreak
-
-	// 添加删除按钮（初始隐藏）
-	deleteButton := widget.NewButtonWithIcon("删除重复文件", theme.DeleteIcon(), nil)
+	pathHint := widget.NewLabel("等待添加扫描目录...")
+	pathList := widget.NewList(
+		func() int {
+			return len(selectedPaths)
+		},
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil,
+				widget.NewCheck("参考", nil),
+				widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true}),
+			)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			path := selectedPaths[id]
+			row := item.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText("📁 " + path)
+			check := row.Objects[1].(*widget.Check)
+			check.OnChanged = nil
+			check.SetChecked(referencePaths[path])
+			check.OnChanged = func(checked bool) {
+				referencePaths[path] = checked
+			}
+		},
+	)
+
+	// 结果视图，每个文件可以单独勾选删除
+	containerSize := fyne.NewSize(500, 500)  // 设置固定的宽度和高度
+	resultView := newResultView(containerSize)
+	resultView.showMessage("📊 扫描结果将在这里显示")
+
+	// 添加删除按钮（初始隐藏），只删除勾选的文件
+	deleteButton := widget.NewButtonWithIcon("删除选中的文件", theme.DeleteIcon(), nil)
 	deleteButton.Hide()
 	deleteButton.Importance = widget.DangerImportance
+	resultView.onChanged = func() {
+		if files, _ := resultView.selection(); files > 0 {
+			deleteButton.Enable()
+		} else {
+			deleteButton.Disable()
+		}
+	}
 
-	// 创建滚动容器，设置相同的最小高度和宽度
-	containerSize := fyne.NewSize(500, 500)  // 设置固定的宽度和高度
-	pathListScroll := container.NewScroll(pathList)
-	pathListScroll.SetMinSize(containerSize)
-	resultScroll := container.NewScroll(resultArea)
-	resultScroll.SetMinSize(containerSize)
+	pathListBox := container.NewMax(pathList, container.NewVBox(pathHint))
 
 	// 创建带有边框和标题的容器
 	pathListBorder := container.NewBorder(
@@ -133,7 +159,7 @@ func main() {
 			widget.NewLabelWithStyle("已选择的目录", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		),
 		nil, nil, nil,
-		container.NewPadded(pathListScroll),
+		container.NewPadded(pathListBox),
 	)
 
 	resultBorder := container.NewBorder(
@@ -142,24 +168,24 @@ func main() {
 			widget.NewLabelWithStyle("扫描结果", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		),
 		nil, nil, nil,
-		container.NewPadded(resultScroll),
+		container.NewPadded(resultView.content),
 	)
 
 	// 创建主要布局容器，使用固定的分割比例
 	mainContent := container.NewHSplit(
 		container.NewPadded(pathListBorder),
-		container.NewVBox(
-			container.NewPadded(resultBorder),
+		container.NewBorder(
+			nil,
 			container.NewPadded(container.NewHBox(
 				layout.NewSpacer(),
 				deleteButton,
 				layout.NewSpacer(),
 			)),
+			nil, nil,
+			container.NewPadded(resultBorder),
 		),
 	)
 
-	var selectedPaths []string
-	
 	// 添加目录按钮
 	addButton := widget.NewButtonWithIcon("添加目录", theme.FolderOpenIcon(), nil)
 	addButton.Importance = widget.HighImportance
@@ -173,10 +199,75 @@ func main() {
 	minSizeEntry.SetPlaceHolder("最小文件大小（如：1MB）")
 	minSizeEntry.Resize(fyne.NewSize(150, minSizeEntry.MinSize().Height))
 
+	// 配置方案：读取配置文件中的 profiles，选择后用其中的设置填充扫描选项
+	const baseProfile = "默认"
+	settings, configErr := config.Load("", "")
+	currentConfig := config.DefaultConfig()
+	profileNames := []string{baseProfile}
+	if configErr == nil {
+		currentConfig = settings.Config
+		profileNames = append(profileNames, settings.Profiles...)
+	}
+	// profilePaths 由配置方案的参考目录加入的目录，切换配置方案时移除
+	var profilePaths []string
+
+	applyConfig := func(cfg *config.Config) {
+		currentConfig = cfg
+		hashAlgo.SetSelected(cfg.HashAlgorithm)
+		if cfg.MinSize == "0" {
+			minSizeEntry.SetText("")
+		} else {
+			minSizeEntry.SetText(cfg.MinSize)
+		}
+
+		var kept []string
+		for _, path := range selectedPaths {
+			if !containsPath(profilePaths, path) {
+				kept = append(kept, path)
+			}
+		}
+		for _, path := range profilePaths {
+			delete(referencePaths, path)
+		}
+		selectedPaths, profilePaths = kept, nil
+		for _, dir := range cfg.ReferenceDirs {
+			if !containsPath(selectedPaths, dir) {
+				selectedPaths = append(selectedPaths, dir)
+				profilePaths = append(profilePaths, dir)
+			}
+			referencePaths[dir] = true
+		}
+		if len(selectedPaths) > 0 {
+			pathHint.Hide()
+		}
+		pathList.Refresh()
+	}
+
+	profileSelect := widget.NewSelect(profileNames, nil)
+	profileSelect.SetSelected(baseProfile)
+	profileSelect.OnChanged = func(name string) {
+		profile := name
+		if profile == baseProfile {
+			profile = ""
+		}
+		cfg, err := config.LoadConfig("", profile)
+		if err != nil {
+			dialog.ShowError(err, myWindow)
+			return
+		}
+		applyConfig(cfg)
+	}
+	if configErr == nil {
+		applyConfig(currentConfig)
+	}
+
 	// 状态标签样式优化
 	statusLabel := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 	statusLabel.Hide()
 
+	// 扫描和删除的进度，可以随时取消
+	progress := newProgressPanel()
+
 	// 扫描按钮样式优化
 	scanButton := widget.NewButtonWithIcon("开始扫描", theme.SearchIcon(), nil)
 	scanButton.Importance = widget.HighImportance
@@ -195,6 +286,9 @@ func main() {
 
 	// 优化选项布局
 	options := container.NewHBox(
+		widget.NewLabelWithStyle("配置方案", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewPadded(profileSelect),
+		widget.NewSeparator(),
 		widget.NewLabelWithStyle("哈希算法", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		container.NewPadded(hashAlgo),
 		widget.NewSeparator(),
@@ -217,13 +311,11 @@ func main() {
 	// 设置主布局
 	content := container.NewBorder(
 		header,
-		container.NewPadded(statusLabel),
+		container.NewPadded(container.NewVBox(statusLabel, progress.content)),
 		nil, nil,
 		container.NewPadded(mainContent),
 	)
 
-	var currentResult *core.Result
-	
 	// 添加目录按钮的事件处理
 	addButton.OnTapped = func() {
 		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
@@ -234,13 +326,9 @@ func main() {
 			if uri == nil {
 				return
 			}
-			path := uri.Path()
-			selectedPaths = append(selectedPaths, path)
-			currentText := pathList.Text
-			if currentText == "等待添加扫描目录...\n" {
-				currentText = ""
-			}
-			pathList.SetText(currentText + "📁 " + path + "\n")
+			selectedPaths = append(selectedPaths, uri.Path())
+			pathHint.Hide()
+			pathList.Refresh()
 		}, myWindow)
 	}
 
@@ -251,114 +339,106 @@ func main() {
 			return
 		}
 
+		if _, err := utils.ParseSize(minSizeEntry.Text); err != nil {
+			dialog.ShowError(err, myWindow)
+			return
+		}
+		var references []string
+		for _, path := range selectedPaths {
+			if referencePaths[path] {
+				references = append(references, path)
+			}
+		}
+		// 界面上的选项覆盖配置方案中的对应设置
+		cfg := *currentConfig
+		cfg.HashAlgorithm = hashAlgo.Selected
+		cfg.MinSize = minSizeEntry.Text
+		if cfg.MinSize == "" {
+			cfg.MinSize = "0"
+		}
+		cfg.ReferenceDirs = references
+		opts, err := cfg.ScanOptions()
+		if err != nil {
+			dialog.ShowError(err, myWindow)
+			return
+		}
+		scanner, err := dedup.New(append(opts, dedup.WithProgress(progress.scanProgress))...)
+		if err != nil {
+			dialog.ShowError(err, myWindow)
+			return
+		}
+
 		deleteButton.Hide()
 		statusLabel.SetText("🔍 正在扫描文件...")
 		statusLabel.Show()
-		resultArea.SetText("\n  正在扫描中，请稍候...\n  这可能需要一些时间，具体取决于文件数量\n")
+		resultView.showMessage("正在扫描中，请稍候...\n这可能需要一些时间，具体取决于文件数量")
 		scanButton.Disable()
 		addButton.Disable()
-
-		scanner := core.NewScanner(
-			hashAlgo.Selected,
-			0,
-			nil,
-			nil,
-		)
+		clearButton.Disable()
+		ctx := progress.start("正在准备扫描...")
 
 		go func() {
-			result, err := scanner.Scan(selectedPaths...)
+			result, err := scanner.Scan(ctx, selectedPaths...)
+			progress.finish()
 			if err != nil {
-				dialog.ShowError(err, myWindow)
+				if errors.Is(err, context.Canceled) {
+					resultView.showMessage("扫描已取消")
+				} else {
+					dialog.ShowError(err, myWindow)
+				}
 				scanButton.Enable()
 				addButton.Enable()
+				clearButton.Enable()
 				statusLabel.Hide()
 				return
 			}
 
-			currentResult = result
-
-			var sb strings.Builder
-			// 使用表格样式展示统计信息
-			sb.WriteString("\n")  // 添加顶部间距
-			sb.WriteString("  扫描结果统计:\n")
-			sb.WriteString("  ┌─────────────────┬─────────────┐\n")
-			sb.WriteString(fmt.Sprintf("  │ 📁 总文件数    │ %9d │\n", result.TotalFiles))
-			sb.WriteString(fmt.Sprintf("  │ 💾 总大小      │ %8.1f MB│\n", float64(result.TotalSize)/(1024*1024)))
-			sb.WriteString(fmt.Sprintf("  │ 🗑️ 可节省空间  │ %8.1f MB│\n", float64(result.SavedSize)/(1024*1024)))
-			sb.WriteString(fmt.Sprintf("  │ 🔍 重复文件组  │ %9d │\n", len(result.DuplicateGroups)))
-			sb.WriteString("  └─────────────────┴─────────────┘\n\n")
-
-			if len(result.DuplicateGroups) == 0 {
-				sb.WriteString("  ✨ 恭喜！未发现重复文件\n")
-			} else {
-				sb.WriteString("  📑 重复文件列表:\n\n")
-				groupNum := 1
-				for _, files := range result.DuplicateGroups {
-					fileInfo, _ := os.Stat(files[0])
-					fileSize := fileInfo.Size()
-					savedSpace := float64(fileSize * int64(len(files)-1)) / (1024 * 1024)
-
-					sb.WriteString("  ┌───────────────────────────────┐\n")
-					sb.WriteString(fmt.Sprintf("  │ 📌 第 %d 组                   │\n", groupNum))
-					sb.WriteString("  ├───────────────────────────────┤\n")
-					sb.WriteString(fmt.Sprintf("  │ 📦 文件数: %-3d               │\n", len(files)))
-					sb.WriteString(fmt.Sprintf("  │ 📏 大小: %-6.1f MB           │\n", float64(fileSize)/(1024*1024)))
-					sb.WriteString(fmt.Sprintf("  │ 💾 节省: %-6.1f MB           │\n", savedSpace))
-					sb.WriteString("  ├───────────────────────────────┤\n")
-					
-					for i, file := range files {
-						fInfo, _ := os.Stat(file)
-						if i == 0 {
-							sb.WriteString("  │ 🟢 原始文件                   │\n")
-						} else {
-							sb.WriteString("  │ 🔴 重复文件                   │\n")
-						}
-						sb.WriteString(fmt.Sprintf("  │   %s\n", file))
-						sb.WriteString(fmt.Sprintf("  │   修改于: %s   │\n", fInfo.ModTime().Format("2006-01-02 15:04")))
-						sb.WriteString("  │                               │\n")
-					}
-					sb.WriteString("  └───────────────────────────────┘\n\n")
-					groupNum++
-				}
-				
-				if len(result.DuplicateGroups) > 0 {
-					deleteButton.Show()
-				}
+			resultView.setResult(result, dedup.KeepFirst)
+			if len(dedup.NewPlan(result, dedup.KeepFirst).Groups) > 0 {
+				deleteButton.Show()
 			}
-
-			resultArea.SetText(sb.String())
 			statusLabel.Hide()
 			scanButton.Enable()
 			addButton.Enable()
+			clearButton.Enable()
 		}()
 	}
 
 	// 设置删除按钮的动作
 	deleteButton.OnTapped = func() {
-		if currentResult == nil || len(currentResult.DuplicateGroups) == 0 {
+		plan := resultView.plan()
+		if len(plan.Groups) == 0 {
+			dialog.ShowInformation("提示", "请先勾选要删除的文件", myWindow)
 			return
 		}
 
-		// 计算要删除的文件数量和总大小
+		// 列出所有勾选的文件，确认的正是将要删除的文件
 		var totalFiles int
-		var totalSize int64
-		for _, files := range currentResult.DuplicateGroups {
-			totalFiles += len(files) - 1 // 减去每组保留的文件
-			for i, file := range files {
-				if i > 0 { // 跳过每组的第一个文件（保留文件）
-					if info, err := os.Stat(file); err == nil {
-						totalSize += info.Size()
-					}
-				}
+		var sb strings.Builder
+		for _, group := range plan.Groups {
+			totalFiles += len(group.Remove)
+			sb.WriteString(fmt.Sprintf("保留 %s\n", group.Keep.Path))
+			for _, file := range group.Remove {
+				sb.WriteString(fmt.Sprintf("    删除 %s\n", file.Path))
 			}
 		}
+		list := widget.NewLabelWithStyle(sb.String(), fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		listScroll := container.NewScroll(list)
+		listScroll.SetMinSize(fyne.NewSize(600, 300))
+		useTrash := currentConfig.UseTrash
+		notice, hint := "注意：删除的文件将被移动到回收站", "提示：删除的文件已移动到回收站，可以随时恢复。"
+		if !useTrash {
+			notice, hint = "注意：配置中关闭了回收站，文件将被永久删除", "提示：删除的文件已记录到操作日志，可以用 dedupgo restore 从保留的副本恢复。"
+		}
+		summary := widget.NewLabel(fmt.Sprintf("确定要删除 %d 组中的 %d 个文件吗？\n总计可释放 %s 空间\n\n%s",
+			len(plan.Groups), totalFiles, utils.FormatSize(plan.Reclaimable()), notice))
 
 		// 显示确认对话框
-		dialog.ShowConfirm(
+		dialog.ShowCustomConfirm(
 			"确认删除",
-			fmt.Sprintf("确定要删除 %d 个重复文件吗？\n总计可释放 %.2f MB 空间\n\n注意：删除的文件将被移动到回收站", 
-				totalFiles, 
-				float64(totalSize)/(1024*1024)),
+			"删除",
+			"取消",
+			container.NewBorder(summary, nil, nil, nil, listScroll),
 			func(confirm bool) {
 				if !confirm {
 					return
@@ -369,37 +449,41 @@ func main() {
 				statusLabel.Show()
 				deleteButton.Disable()
 				scanButton.Disable()
+				addButton.Disable()
+				clearButton.Disable()
+				ctx := progress.start("正在准备删除...")
 				
 				go func() {
-					var deletedCount int
-					var errorCount int
-					
-					for _, files := range currentResult.DuplicateGroups {
-						for i, file := range files {
-							if i > 0 { // 跳过每组的第一个文件（保留文件）
-								if err := core.MoveToTrash(file); err != nil {
-									errorCount++
-								} else {
-									deletedCount++
-								}
-							}
-						}
-					}
-
-					// 更新结果显示
-					resultArea.SetText(fmt.Sprintf(
-						"🗑️ 删除操作完成！\n\n"+
-							"✅ 成功删除: %d 个文件\n"+
-							"❌ 删除失败: %d 个文件\n\n"+
-							"提示：删除的文件已移动到回收站，可以随时恢复。",
-						deletedCount,
-						errorCount,
-					))
-
+					// 只移除勾选的文件，每组保留选择的文件或参考文件；取消时已删除的文件仍计入结果
+					report, err := applyPlan(ctx, plan, useTrash, progress)
+					progress.finish()
 					statusLabel.Hide()
-					deleteButton.Hide() // 隐藏删除按钮
 					scanButton.Enable()
-					currentResult = nil // 清除当前结果
+					addButton.Enable()
+					clearButton.Enable()
+					resultView.removeFiles(report.Removed)
+					if err != nil && !errors.Is(err, context.Canceled) {
+						dialog.ShowError(err, myWindow)
+						return
+					}
+
+					var failed strings.Builder
+					for _, f := range report.Failed {
+						failed.WriteString(fmt.Sprintf("\n%s: %v", f.File.Path, f.Err))
+					}
+					title := "删除操作完成"
+					if err != nil {
+						title = "删除已取消"
+					}
+					dialog.ShowInformation(title, fmt.Sprintf(
+						"✅ 成功删除: %d 个文件，释放 %s\n"+
+							"❌ 删除失败: %d 个文件%s\n\n%s",
+						len(report.Removed),
+						utils.FormatSize(report.FreedSize),
+						len(report.Failed),
+						failed.String(),
+						hint,
+					), myWindow)
 				}()
 			},
 			myWindow,
@@ -409,8 +493,11 @@ func main() {
 	// 清除按钮的事件处理
 	clearButton.OnTapped = func() {
 		selectedPaths = nil
-		pathList.SetText("等待添加扫描目录...\n")
-		resultArea.SetText("\n  📊 欢迎使用 DedupGo\n\n  扫描结果将在这里显示\n  请先添加要扫描的目录...\n")
+		profilePaths = nil
+		referencePaths = make(map[string]bool)
+		pathHint.Show()
+		pathList.Refresh()
+		resultView.showMessage("📊 欢迎使用 DedupGo\n\n扫描结果将在这里显示\n请先添加要扫描的目录...")
 		statusLabel.Hide()
 		deleteButton.Hide()
 		scanButton.Enable()
@@ -419,8 +506,47 @@ func main() {
 
 	// 设置窗口
 	myWindow.SetContent(content)
+	if configErr != nil {
+		dialog.ShowError(fmt.Errorf("加载配置失败: %v", configErr), myWindow)
+	}
 	myWindow.Resize(fyne.NewSize(1200, 800))
 	mainContent.SetOffset(0.35)
 	myWindow.CenterOnScreen()
 	myWindow.ShowAndRun()
-} 
\ No newline at end of file
+} 
+
+// applyPlan 执行计划，与命令行相同地按配置移到回收站或直接删除，并把每个被移除的文件记录到操作日志中，
+// 以便用 dedupgo restore 恢复。progress 显示删除进度。
+func applyPlan(ctx context.Context, plan *dedup.Plan, useTrash bool, progress *progressPanel) (*dedup.ApplyReport, error) {
+	var executor dedup.Executor = dedup.DeleteExecutor{}
+	action := dedup.JournalDelete
+	if useTrash {
+		executor = dedup.TrashExecutor{}
+		action = dedup.JournalTrash
+	}
+
+	path, err := config.JournalPath()
+	if err != nil {
+		return &dedup.ApplyReport{}, err
+	}
+	journal, err := dedup.OpenJournal(path)
+	if err != nil {
+		return &dedup.ApplyReport{}, fmt.Errorf("打开操作日志失败: %v", err)
+	}
+
+	report, err := plan.Apply(ctx, progress.executor(journal.Executor(plan, executor, action), plan))
+	if cerr := journal.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("写入操作日志失败: %v", cerr)
+	}
+	return report, err
+}
+
+// containsPath 判断 paths 中是否包含 path
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// progressPanel 显示扫描或删除的进度、统计和当前文件，按“取消”停止正在进行的任务。
+// 进度在工作协程中更新，写入数据绑定后由界面在主线程中刷新。
+type progressPanel struct {
+	value binding.Float
+	text  binding.String
+	file  binding.String
+
+	cancelButton *widget.Button
+	content      *fyne.Container
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped bool
+	// hashStart 进入哈希阶段时的扫描用时，用于计算读取速度，尚未进入时为 -1
+	hashStart time.Duration
+}
+
+func newProgressPanel() *progressPanel {
+	p := &progressPanel{
+		value: binding.NewFloat(),
+		text:  binding.NewString(),
+		file:  binding.NewString(),
+	}
+	p.cancelButton = widget.NewButtonWithIcon("取消", theme.CancelIcon(), p.stop)
+
+	fileLabel := widget.NewLabelWithData(p.file)
+	fileLabel.Truncation = fyne.TextTruncateEllipsis
+	fileLabel.TextStyle = fyne.TextStyle{Monospace: true}
+	p.content = container.NewVBox(
+		container.NewBorder(nil, nil, nil, p.cancelButton, widget.NewProgressBarWithData(p.value)),
+		widget.NewLabelWithData(p.text),
+		fileLabel,
+	)
+	p.content.Hide()
+	return p
+}
+
+// start 显示进度并返回任务使用的 context，按“取消”时取消
+func (p *progressPanel) start(text string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.cancel, p.stopped = cancel, false
+	p.hashStart = -1
+	p.mu.Unlock()
+
+	p.value.Set(0)
+	p.text.Set(text)
+	p.file.Set("")
+	p.cancelButton.Enable()
+	p.content.Show()
+	return ctx
+}
+
+// finish 任务结束后隐藏进度
+func (p *progressPanel) finish() {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.mu.Unlock()
+	p.content.Hide()
+}
+
+// stop 取消正在进行的任务，任务在处理完当前文件后结束
+func (p *progressPanel) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil && !p.stopped {
+		p.cancel()
+		p.stopped = true
+		p.cancelButton.Disable()
+		p.text.Set("正在取消...")
+	}
+}
+
+// scanProgress 作为扫描的进度回调，显示各阶段的文件数、字节数、读取速度和剩余时间
+func (p *progressPanel) scanProgress(pr dedup.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel == nil || p.stopped {
+		// 已取消或已结束，不再覆盖提示
+		return
+	}
+	if pr.Phase == dedup.PhaseHashing && p.hashStart < 0 {
+		p.hashStart = pr.Elapsed
+	}
+	hashTime := pr.Elapsed - p.hashStart
+
+	switch pr.Phase {
+	case dedup.PhaseWalking:
+		p.value.Set(0)
+		p.text.Set(fmt.Sprintf("正在遍历目录: 已发现 %d 个文件 (%s) · 用时 %s",
+			pr.FilesFound, utils.FormatSize(pr.BytesFound), formatDuration(pr.Elapsed)))
+	case dedup.PhaseHashing:
+		if pr.BytesToHash > 0 {
+			p.value.Set(float64(pr.BytesHashed) / float64(pr.BytesToHash))
+		}
+		text := fmt.Sprintf("正在计算哈希: %d/%d 个文件 · %s/%s · 发现 %d 组重复",
+			pr.FilesHashed, pr.FilesToHash, utils.FormatSize(pr.BytesHashed), utils.FormatSize(pr.BytesToHash), pr.Groups)
+		if hashTime > 0 {
+			text += fmt.Sprintf(" · %s/s", utils.FormatSize(int64(float64(pr.BytesHashed)/hashTime.Seconds())))
+		}
+		if pr.Remaining > 0 {
+			text += " · 剩余约 " + formatDuration(pr.Remaining)
+		}
+		p.text.Set(text)
+	case dedup.PhaseAnalyzing:
+		p.value.Set(1)
+		p.text.Set(fmt.Sprintf("正在分析: 发现 %d 组重复 · 用时 %s", pr.Groups, formatDuration(pr.Elapsed)))
+	default:
+		p.value.Set(1)
+		p.text.Set(fmt.Sprintf("扫描完成: %d 个文件，%d 组重复 · 用时 %s", pr.FilesFound, pr.Groups, formatDuration(pr.Elapsed)))
+	}
+	p.file.Set(pr.CurrentFile)
+}
+
+// executor 包装 exec，每处理一个文件更新一次删除进度
+func (p *progressPanel) executor(exec dedup.Executor, plan *dedup.Plan) dedup.Executor {
+	var total int
+	for _, group := range plan.Groups {
+		total += len(group.Remove)
+	}
+	start := time.Now()
+	var done int
+	var freed int64
+	return dedup.ExecutorFunc(func(file dedup.FileInfo) error {
+		p.file.Set(file.Path)
+		err := exec.Remove(file)
+		done++
+		if err == nil {
+			freed += file.Size
+		}
+
+		p.value.Set(float64(done) / float64(total))
+		text := fmt.Sprintf("正在删除: %d/%d 个文件 · 已释放 %s", done, total, utils.FormatSize(freed))
+		if elapsed := time.Since(start); done < total {
+			text += " · 剩余约 " + formatDuration(elapsed/time.Duration(done)*time.Duration(total-done))
+		}
+		p.mu.Lock()
+		if !p.stopped {
+			p.text.Set(text)
+		}
+		p.mu.Unlock()
+		return err
+	})
+}
+
+// formatDuration 按秒取整显示时间
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// keepLabel 每个文件行上“保留此文件”单选按钮的选项
+const keepLabel = "保留"
+
+// resultGroup 结果视图中的一组重复文件及其选择
+type resultGroup struct {
+	group dedup.DuplicateGroup
+	// active 该组能否处理，与 NewPlan 相同：使用参考目录扫描时不含参考文件的组不处理，
+	// 没有参考文件的组至少要有两个可以删除的文件
+	active bool
+	// keep 保留的文件在 group.Files 中的下标，有参考文件时为 -1，表示只保留参考文件
+	keep int
+	// selected 勾选删除的文件在 group.Files 中的下标
+	selected map[int]bool
+}
+
+// deletable 判断组内第 i 个文件能否勾选删除：保留的文件、参考文件和归档内的文件不能删除
+func (g *resultGroup) deletable(i int) bool {
+	file := g.group.Files[i]
+	return g.active && i != g.keep && !file.Reference && !file.InArchive()
+}
+
+// applyRule 按规则决定保留的文件，并勾选其余可以删除的文件
+func (g *resultGroup) applyRule(rule dedup.KeepRule) {
+	if !g.active {
+		return
+	}
+	if !g.group.HasReference() {
+		var index []int
+		for i, file := range g.group.Files {
+			if !file.InArchive() {
+				index = append(index, i)
+			}
+		}
+		g.keep = index[rule(g.group.Removable())]
+	}
+	g.selectOthers()
+}
+
+// setKeep 保留第 i 个文件，勾选组内其余可以删除的文件
+func (g *resultGroup) setKeep(i int) {
+	g.keep = i
+	g.selectOthers()
+}
+
+func (g *resultGroup) selectOthers() {
+	g.selected = make(map[int]bool)
+	for i := range g.group.Files {
+		if g.deletable(i) {
+			g.selected[i] = true
+		}
+	}
+}
+
+// resultView 以树形列表显示扫描结果：每组一个分支，每个文件一行，
+// 可以勾选要删除的文件，也可以选择每组保留哪个文件
+type resultView struct {
+	groups  []*resultGroup
+	tree    *widget.Tree
+	summary *widget.Label
+	message *widget.Label
+	result  *dedup.Result
+	// onChanged 勾选的文件变化时调用
+	onChanged func()
+
+	content fyne.CanvasObject
+}
+
+func newResultView(minSize fyne.Size) *resultView {
+	v := &resultView{
+		summary: widget.NewLabel(""),
+		message: widget.NewLabel(""),
+	}
+	v.summary.Wrapping = fyne.TextWrapWord
+	v.tree = widget.NewTree(v.childUIDs, v.isBranch, v.createNode, v.updateNode)
+
+	rules := container.NewHBox(widget.NewLabel("按规则选择:"))
+	for _, r := range []struct {
+		name string
+		rule dedup.KeepRule
+	}{
+		{"保留第一个", dedup.KeepFirst},
+		{"保留最旧", dedup.KeepOldest},
+		{"保留最新", dedup.KeepNewest},
+		{"保留路径最短", dedup.KeepShortestPath},
+	} {
+		rule := r.rule
+		rules.Add(widget.NewButton(r.name, func() { v.selectByRule(rule) }))
+	}
+	rules.Add(widget.NewButton("全部取消", v.clearSelection))
+
+	// 透明矩形撑起结果区域的最小尺寸
+	size := canvas.NewRectangle(color.Transparent)
+	size.SetMinSize(minSize)
+	v.content = container.NewBorder(
+		container.NewVBox(v.summary, rules),
+		nil, nil, nil,
+		container.NewMax(size, v.tree, container.NewCenter(v.message)),
+	)
+	return v
+}
+
+// showMessage 清空结果并在结果区域显示提示
+func (v *resultView) showMessage(text string) {
+	v.result, v.groups = nil, nil
+	v.summary.SetText("")
+	v.message.SetText(text)
+	v.message.Show()
+	v.tree.Refresh()
+	v.changed()
+}
+
+// setResult 显示扫描结果，每组按 rule 建议保留的文件，初始不勾选任何文件
+func (v *resultView) setResult(result *dedup.Result, rule dedup.KeepRule) {
+	v.result = result
+	v.groups = nil
+	for _, group := range result.DuplicateGroups {
+		g := &resultGroup{group: group, keep: -1, selected: make(map[int]bool)}
+		removable := len(group.Removable())
+		switch {
+		case group.HasReference():
+			g.active = removable > 0
+		case len(result.ReferenceDirs) > 0:
+			g.active = false
+		default:
+			g.active = removable >= 2
+		}
+		if g.active {
+			g.applyRule(rule)
+			g.selected = make(map[int]bool)
+		}
+		v.groups = append(v.groups, g)
+	}
+
+	if len(v.groups) == 0 {
+		v.message.SetText("✨ 恭喜！未发现重复文件")
+		v.message.Show()
+	} else {
+		v.message.Hide()
+	}
+	v.tree.Refresh()
+	v.tree.OpenAllBranches()
+	v.tree.ScrollToTop()
+	v.changed()
+}
+
+// selectByRule 所有可以处理的组按规则保留一个文件，勾选其余的文件
+func (v *resultView) selectByRule(rule dedup.KeepRule) {
+	for _, g := range v.groups {
+		g.applyRule(rule)
+	}
+	v.tree.Refresh()
+	v.changed()
+}
+
+func (v *resultView) clearSelection() {
+	for _, g := range v.groups {
+		g.selected = make(map[int]bool)
+	}
+	v.tree.Refresh()
+	v.changed()
+}
+
+// changed 更新统计信息并通知勾选的变化
+func (v *resultView) changed() {
+	if v.result != nil {
+		files, size := v.selection()
+		v.summary.SetText(fmt.Sprintf("共扫描 %d 个文件 (%s)，发现 %d 组重复，可释放 %s；已选择删除 %d 个文件，释放 %s",
+			v.result.TotalFiles, utils.FormatSize(v.result.TotalSize), len(v.groups), utils.FormatSize(v.result.SavedSize),
+			files, utils.FormatSize(size)))
+	}
+	if v.onChanged != nil {
+		v.onChanged()
+	}
+}
+
+// selection 返回勾选删除的文件数和总大小
+func (v *resultView) selection() (files int, size int64) {
+	for _, g := range v.groups {
+		files += len(g.selected)
+		size += int64(len(g.selected)) * g.group.Size
+	}
+	return files, size
+}
+
+// plan 按勾选的文件生成处理计划，每组保留选择的文件，有参考文件时保留参考文件
+func (v *resultView) plan() *dedup.Plan {
+	plan := &dedup.Plan{Groups: []dedup.PlanGroup{}}
+	for _, g := range v.groups {
+		if len(g.selected) == 0 {
+			continue
+		}
+		pg := dedup.PlanGroup{Hash: g.group.Hash, Size: g.group.Size}
+		if refs := g.group.References(); len(refs) > 0 {
+			pg.Keep = refs[0]
+		} else {
+			pg.Keep = g.group.Files[g.keep]
+		}
+		for i, file := range g.group.Files {
+			if g.selected[i] {
+				pg.Remove = append(pg.Remove, file)
+			}
+		}
+		plan.Groups = append(plan.Groups, pg)
+	}
+	return plan
+}
+
+// removeFiles 从结果中去掉已删除的文件，剩下的文件不再构成重复的组不再显示
+func (v *resultView) removeFiles(removed []dedup.FileInfo) {
+	paths := make(map[string]bool)
+	for _, file := range removed {
+		paths[file.Path] = true
+	}
+
+	result := *v.result
+	result.DuplicateGroups = nil
+	result.SavedSize = 0
+	for _, group := range v.result.DuplicateGroups {
+		var files []dedup.FileInfo
+		for _, file := range group.Files {
+			if !paths[file.Path] {
+				files = append(files, file)
+			}
+		}
+		if len(files) < 2 {
+			continue
+		}
+		group.Files = files
+		result.DuplicateGroups = append(result.DuplicateGroups, group)
+		result.SavedSize += group.Reclaimable()
+	}
+
+	// 保留未删除的组中原来的选择
+	keep := make(map[string]string)
+	for _, g := range v.groups {
+		if g.keep >= 0 {
+			keep[g.group.Hash] = g.group.Files[g.keep].Path
+		}
+	}
+	v.setResult(&result, dedup.KeepFirst)
+	for _, g := range v.groups {
+		for i, file := range g.group.Files {
+			if g.active && !g.group.HasReference() && file.Path == keep[g.group.Hash] {
+				g.keep = i
+			}
+		}
+	}
+	v.tree.Refresh()
+}
+
+// 树的节点 ID：组为 "组下标"，文件为 "组下标/文件下标"，根节点为 ""
+func (v *resultView) childUIDs(id widget.TreeNodeID) []widget.TreeNodeID {
+	var ids []widget.TreeNodeID
+	if id == "" {
+		for i := range v.groups {
+			ids = append(ids, strconv.Itoa(i))
+		}
+		return ids
+	}
+	gi, _ := parseNodeID(id)
+	if gi < len(v.groups) {
+		for i := range v.groups[gi].group.Files {
+			ids = append(ids, id+"/"+strconv.Itoa(i))
+		}
+	}
+	return ids
+}
+
+func (v *resultView) isBranch(id widget.TreeNodeID) bool {
+	return !strings.Contains(id, "/")
+}
+
+// parseNodeID 返回节点的组下标和文件下标，组节点的文件下标为 -1
+func parseNodeID(id widget.TreeNodeID) (group, file int) {
+	g, f, ok := strings.Cut(id, "/")
+	group, _ = strconv.Atoi(g)
+	file = -1
+	if ok {
+		file, _ = strconv.Atoi(f)
+	}
+	return group, file
+}
+
+func (v *resultView) createNode(branch bool) fyne.CanvasObject {
+	if branch {
+		return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	radio := widget.NewRadioGroup([]string{keepLabel}, nil)
+	radio.Horizontal = true
+	radio.Required = true
+	return container.NewBorder(nil, nil,
+		container.NewHBox(widget.NewCheck("删除", nil), radio),
+		nil,
+		widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true}),
+	)
+}
+
+func (v *resultView) updateNode(id widget.TreeNodeID, branch bool, item fyne.CanvasObject) {
+	gi, fi := parseNodeID(id)
+	if gi >= len(v.groups) {
+		return
+	}
+	g := v.groups[gi]
+
+	if branch {
+		text := fmt.Sprintf("📌 第 %d 组 · %d 个文件 × %s · 可释放 %s", gi+1, len(g.group.Files),
+			utils.FormatSize(g.group.Size), utils.FormatSize(g.group.Reclaimable()))
+		if !g.active {
+			text += " · 不在参考目录中，不处理"
+		} else if len(g.selected) > 0 {
+			text += fmt.Sprintf(" · 已选择 %d 个", len(g.selected))
+		}
+		item.(*widget.Label).SetText(text)
+		return
+	}
+	if fi >= len(g.group.Files) {
+		return
+	}
+
+	file := g.group.Files[fi]
+	row := item.(*fyne.Container)
+	label := row.Objects[0].(*widget.Label)
+	controls := row.Objects[1].(*fyne.Container)
+	check := controls.Objects[0].(*widget.Check)
+	radio := controls.Objects[1].(*widget.RadioGroup)
+
+	prefix := ""
+	switch {
+	case file.Reference:
+		prefix = "🔵 参考 "
+	case file.InArchive():
+		prefix = "📦 归档 "
+	}
+	label.SetText(fmt.Sprintf("%s%s  修改于 %s", prefix, file.Path, file.ModTime.Local().Format("2006-01-02 15:04")))
+
+	check.OnChanged = nil
+	check.SetChecked(g.selected[fi])
+	if g.deletable(fi) {
+		check.Enable()
+	} else {
+		check.Disable()
+	}
+	check.OnChanged = func(checked bool) {
+		if checked {
+			g.selected[fi] = true
+		} else {
+			delete(g.selected, fi)
+		}
+		v.tree.RefreshItem(strconv.Itoa(gi))
+		v.changed()
+	}
+
+	// 有参考文件的组只保留参考文件，不需要选择保留哪个
+	radio.OnChanged = nil
+	if g.active && !g.group.HasReference() && !file.InArchive() {
+		if fi == g.keep {
+			radio.SetSelected(keepLabel)
+		} else {
+			radio.SetSelected("")
+		}
+		radio.Show()
+	} else {
+		radio.Hide()
+	}
+	radio.OnChanged = func(selected string) {
+		if selected == keepLabel && fi != g.keep {
+			g.setKeep(fi)
+			v.tree.Refresh()
+			v.changed()
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runCache 执行 dedupgo cache list|clear|dir：管理增量扫描状态
+func runCache(args []string) {
+	fs := newCommandFlags("cache", "list | clear [NAME...] | dir",
+		"管理 --state 保存的增量扫描状态。\n"+
+			"  list          列出所有状态\n"+
+			"  clear [NAME]  删除指定的状态，不指定时删除全部状态\n"+
+			"  dir           显示状态所在的目录")
+	withJournal := fs.Bool("journal", false, "clear 时同时删除操作日志（删除后无法再用 restore 恢复文件）")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	dir, err := config.StatesDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// 选项也可以写在操作之后，如 cache clear --journal
+	op := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+
+	switch op {
+	case "list":
+		listStates(dir)
+	case "clear":
+		names := fs.Args()
+		if len(names) == 0 {
+			names = stateNames(dir)
+		}
+		for _, name := range names {
+			path, err := config.StatePath(name)
+			if err == nil {
+				err = os.Remove(path)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "删除状态 %s 失败: %v\n", name, err)
+				os.Exit(exitError)
+			}
+			fmt.Printf("已删除状态 %s\n", name)
+		}
+		if *withJournal {
+			path, err := config.JournalPath()
+			if err == nil {
+				err = os.Remove(path)
+			}
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "删除操作日志失败: %v\n", err)
+				os.Exit(exitError)
+			}
+			fmt.Println("已删除操作日志")
+		}
+	case "dir":
+		fmt.Println(dir)
+	default:
+		fmt.Fprintf(os.Stderr, "未知的 cache 操作: %s\n", op)
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+// stateNames 返回状态目录中所有状态的名称，按名称排序
+func stateNames(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	var names []string
+	for _, match := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(match), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func listStates(dir string) {
+	names := stateNames(dir)
+	if len(names) == 0 {
+		fmt.Println("没有保存的增量扫描状态")
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name+".json")
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		st, err := dedup.LoadState(path)
+		if err != nil {
+			fmt.Printf("%-20s 无法读取: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%-20s %s  %s  %d 个文件  %d 组重复  %s\n", name, st.UpdatedAt.Local().Format("2006-01-02 15:04"),
+			st.Algorithm, len(st.Files), len(st.Groups), utils.FormatSize(info.Size()))
+	}
+
+	if path, err := config.JournalPath(); err == nil {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Printf("\n操作日志: %s (%s)\n", path, utils.FormatSize(info.Size()))
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+)
+
+// runConfig 执行 dedupgo config show|profiles|path|init|validate|schema：查看和管理配置
+func runConfig(args []string) {
+	fs := newCommandFlags("config", "show [--effective] [扫描选项] | profiles | path | init | validate | schema",
+		"查看和管理配置。配置依次由默认值、配置文件、--profile 选择的配置方案、DEDUPGO_* 环境变量\n"+
+			"和显式指定的命令行参数合并而成，后面的覆盖前面的。show 可以附加 scan 的扫描选项，查看它们生效后的配置。\n"+
+			"  show      显示合并后的完整配置\n"+
+			"  profiles  列出配置文件中定义的配置方案\n"+
+			"  path      显示使用的配置文件路径\n"+
+			"  init      生成带说明的默认配置文件\n"+
+			"  validate  检查配置文件中的基础配置和每个配置方案，有问题时退出码为 1\n"+
+			"  schema    输出配置文件的 JSON Schema")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	effective := fs.Bool("effective", false, "show 时逐项显示配置的值及其来源")
+	overwrite := fs.Bool("overwrite", false, "init 时覆盖已存在的配置文件")
+	addSettingFlags(fs)
+	fs.short("c", "config")
+	fs.short("p", "profile")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	op := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+
+	switch op {
+	case "show":
+		settings, err := loadSettings(fs, *configFile, *profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+			os.Exit(exitError)
+		}
+		if *effective {
+			outputEffective(settings)
+			return
+		}
+		data, err := yaml.Marshal(settings.Config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(exitError)
+		}
+		os.Stdout.Write(data)
+	case "profiles":
+		settings, err := config.Load(*configFile, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+			os.Exit(exitError)
+		}
+		if len(settings.Profiles) == 0 {
+			fmt.Printf("%s 中没有定义配置方案\n", settings.Path)
+		}
+		for _, name := range settings.Profiles {
+			fmt.Println(name)
+		}
+	case "path":
+		fmt.Println(configPath(*configFile))
+	case "init":
+		path := configPath(*configFile)
+		if _, err := os.Stat(path); err == nil && !*overwrite {
+			fmt.Fprintf(os.Stderr, "错误: 配置文件 %s 已存在，使用 --overwrite 覆盖\n", path)
+			os.Exit(exitError)
+		}
+		if err := config.SaveConfig(config.DefaultConfig(), path); err != nil {
+			fmt.Fprintf(os.Stderr, "生成配置文件失败: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Printf("已生成配置文件 %s\n", path)
+	case "validate":
+		path := configPath(*configFile)
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(exitError)
+		}
+		if err := config.ValidateFile(path); err != nil {
+			// 每个问题一行
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitFound)
+		}
+		fmt.Printf("配置有效: %s\n", path)
+	case "schema":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(config.Schema())
+	default:
+		fmt.Fprintf(os.Stderr, "未知的 config 操作: %s\n", op)
+		fs.Usage()
+		os.Exit(exitError)
+	}
+}
+
+// configPath 返回使用的配置文件路径：--config、DEDUPGO_CONFIG 或默认路径
+func configPath(path string) string {
+	if path == "" {
+		path = os.Getenv(config.EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	return path
+}
+
+// outputEffective 逐项输出配置的值及其来源，值的格式与环境变量相同，列表用逗号分隔
+func outputEffective(settings *config.Settings) {
+	if settings.Profile != "" {
+		fmt.Printf("# 配置方案: %s\n", settings.Profile)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, key := range config.Keys() {
+		value := settings.Value(key)
+		if value == "" {
+			value = `""`
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, settings.Origin(key))
+	}
+	w.Flush()
+}
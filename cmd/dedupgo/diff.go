@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runDiff 执行 dedupgo diff A B：按内容比较两棵目录树
+func runDiff(args []string) {
+	fs := newCommandFlags("diff", "[选项] A B",
+		"按内容比较目录 A 和 B，列出只在 A 中、只在 B 中以及两边都有的文件")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	fs.String("hash", "md5", "哈希算法 (md5/sha256)")
+	fs.String("min-size", "0", "最小文件大小 (例如: 10MB)")
+	outputFormat := fs.String("output", "txt", "输出格式 (txt/json)")
+	fs.Bool("archives", false, "同时比较 zip/tar 归档内的文件")
+	exclude := addExcludeFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	settings, err := loadSettings(fs, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+	// 比较两棵目录树时不需要相似图片、重复目录和参考目录；
+	// 被排除的文件不会参与比较，因此只排除 --exclude 显式指定的模式
+	cfg.SimilarImages = ""
+	cfg.DuplicateDirs = false
+	cfg.ReferenceDirs = nil
+	if cfg.ExcludePatterns, err = explicitExcludes(exclude); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	scanner, err := newScanner(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	result, err := scanner.Diff(context.Background(), fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "比较失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	switch strings.ToLower(*outputFormat) {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "JSON输出失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	default:
+		outputDiffText(result)
+	}
+}
+
+func outputDiffText(result *dedup.DiffResult) {
+	fmt.Printf("仅在 A (%s) 中: %d 个文件，%s\n", result.A, result.Summary.OnlyA.Files, utils.FormatSize(result.Summary.OnlyA.Size))
+	for _, file := range result.OnlyA {
+		fmt.Printf("  - %s (%s)\n", file.Path, utils.FormatSize(file.Size))
+	}
+	fmt.Println()
+
+	fmt.Printf("仅在 B (%s) 中: %d 个文件，%s\n", result.B, result.Summary.OnlyB.Files, utils.FormatSize(result.Summary.OnlyB.Size))
+	for _, file := range result.OnlyB {
+		fmt.Printf("  + %s (%s)\n", file.Path, utils.FormatSize(file.Size))
+	}
+	fmt.Println()
+
+	fmt.Printf("两边都有: %d 份内容，%s\n", result.Summary.Both.Files, utils.FormatSize(result.Summary.Both.Size))
+	for _, match := range result.Both {
+		fmt.Printf("  = %s (%s)\n", match.Hash, utils.FormatSize(match.Size))
+		for _, file := range match.A {
+			fmt.Printf("      A: %s\n", file.Path)
+		}
+		for _, file := range match.B {
+			fmt.Printf("      B: %s\n", file.Path)
+		}
+	}
+	fmt.Println()
+
+	if result.Summary.OnlyA.Files == 0 {
+		fmt.Println("A 中的所有文件在 B 中都有副本")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runImport 执行 dedupgo import FILE：把 fdupes/jdupes/rmlint 的结果作为处理计划执行
+func runImport(args []string) {
+	fs := newCommandFlags("import", "[选项] FILE",
+		"读取 fdupes、jdupes 或 rmlint 的结果文件（- 表示标准输入），重新校验内容后按保留规则生成处理计划")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	format := fs.String("format", "", "结果文件格式 (fdupes/jdupes/rmlint)，留空时自动识别")
+	fs.String("hash", "md5", "重新校验内容使用的哈希算法 (md5/sha256)")
+	keepRule := fs.String("keep", "first", "每组保留哪个文件 (first/oldest/newest/shortest)，rmlint 标记的原始文件优先")
+	force := fs.Bool("force", false, "实际执行删除，默认只预览")
+	useTrash := fs.Bool("trash", true, "使用回收站")
+	outputFormat := fs.String("output", "txt", "输出格式 (txt/json/csv/html/md/fdupes)")
+	fs.Var(new(stringList), "reference", "参考目录，其中的文件永远保留 (可重复指定)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	outFormat := strings.ToLower(*outputFormat)
+	if !validOutputFormat(outFormat) || outFormat == "ndjson" {
+		fmt.Fprintf(os.Stderr, "错误: 未知的输出格式: %s\n", *outputFormat)
+		os.Exit(exitError)
+	}
+
+	settings, err := loadSettings(fs, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+	// 导入的文件不再经过遍历和过滤，只保留与哈希和参考目录相关的设置
+	scanner, err := dedup.New(
+		dedup.WithHashAlgorithm(cfg.HashAlgorithm),
+		dedup.WithReferenceDirs(cfg.ReferenceDirs...),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	groups, err := readForeignFile(fs.Arg(0), *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取结果文件失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	ctx := context.Background()
+	result, err := scanner.Recheck(ctx, groups)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	fmt.Fprintf(os.Stderr, "导入 %d 组，重新校验后确认 %d 组内容相同\n", len(groups), len(result.DuplicateGroups))
+
+	rule, err := dedup.KeepRuleByName(*keepRule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	plan := dedup.NewPlan(result, keepOriginals(groups, rule))
+
+	var report *dedup.ApplyReport
+	if *force {
+		report, err = applyPlan(ctx, plan, *useTrash, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "删除失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	if err := outputReport(os.Stdout, outFormat, newReportView(result, plan, report)); err != nil {
+		fmt.Fprintf(os.Stderr, "输出报告失败: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// readForeignFile 读取其他工具的结果文件，path 为 "-" 时读取标准输入
+func readForeignFile(path, format string) ([]dedup.ForeignGroup, error) {
+	if path == "-" {
+		return dedup.ReadForeign(os.Stdin, format)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dedup.ReadForeign(f, format)
+}
+
+// keepOriginals 返回优先保留原工具标记的原始文件的保留规则，组内没有原始文件时使用 rule
+func keepOriginals(groups []dedup.ForeignGroup, rule dedup.KeepRule) dedup.KeepRule {
+	originals := make(map[string]bool)
+	for _, group := range groups {
+		if group.Original != "" {
+			originals[group.Original] = true
+		}
+	}
+	if len(originals) == 0 {
+		return rule
+	}
+
+	return func(files []dedup.FileInfo) int {
+		for i, file := range files {
+			if originals[file.Path] {
+				return i
+			}
+		}
+		return rule(files)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// errReviewAborted 在交互审阅中按下 Ctrl-C，不执行任何操作
+var errReviewAborted = errors.New("已取消，没有移除任何文件")
+
+// 按键
+const (
+	keyCtrlC  = 3
+	keyEnter  = '\r'
+	keyEscape = 0x1b
+)
+
+// keyReader 逐个读取按键。标准输入是终端时每次读取前切换到原始模式，按键不需要回车；
+// 否则（如管道输入）逐字节读取，换行视为回车
+type keyReader struct {
+	in  *os.File
+	fd  int
+	tty bool
+	r   *bufio.Reader
+
+	// unread 被 unreadKey 退回、下次 readKey 优先返回的按键
+	unread    byte
+	hasUnread bool
+}
+
+func newKeyReader(in *os.File) *keyReader {
+	fd := int(in.Fd())
+	return &keyReader{in: in, fd: fd, tty: term.IsTerminal(fd), r: bufio.NewReader(in)}
+}
+
+// readKey 读取一个按键。方向键等转义序列返回 keyEscape，输入结束时返回 io.EOF
+func (k *keyReader) readKey() (byte, error) {
+	if k.hasUnread {
+		k.hasUnread = false
+		return k.unread, nil
+	}
+	if !k.tty {
+		b, err := k.r.ReadByte()
+		if b == '\n' {
+			b = keyEnter
+		}
+		return b, err
+	}
+
+	state, err := term.MakeRaw(k.fd)
+	if err != nil {
+		return 0, err
+	}
+	defer term.Restore(k.fd, state)
+
+	var buf [16]byte
+	n, err := k.in.Read(buf[:])
+	if err != nil {
+		return 0, err
+	}
+	if n > 1 {
+		return keyEscape, nil
+	}
+	if buf[0] == '\n' {
+		return keyEnter, nil
+	}
+	return buf[0], nil
+}
+
+// unreadKey 退回一个按键，下次 readKey 时返回
+func (k *keyReader) unreadKey(key byte) {
+	k.unread, k.hasUnread = key, true
+}
+
+// reviewGroup 交互审阅中的一组重复文件
+type reviewGroup struct {
+	group dedup.DuplicateGroup
+	// files 可以移除的文件（不在归档内也不在参考目录中），按编号显示
+	files []dedup.FileInfo
+	refs  []dedup.FileInfo
+	// suggest 建议保留的文件在 files 中的下标，有参考文件时为 -1，表示只保留参考文件
+	suggest int
+	// keep 选择保留的文件，-1 表示只保留参考文件
+	keep    int
+	skip    bool
+	decided bool
+}
+
+// newReviewGroups 选出需要审阅的组，与 NewPlan 相同：有参考文件的组移除参考目录之外的副本，
+// 使用参考目录扫描时跳过不含参考文件的组，其他组至少要有两个可以移除的文件
+func newReviewGroups(result *dedup.Result, rule dedup.KeepRule) []*reviewGroup {
+	var groups []*reviewGroup
+	for _, group := range result.DuplicateGroups {
+		g := &reviewGroup{group: group, files: group.Removable(), refs: group.References(), suggest: -1}
+		switch {
+		case len(g.refs) > 0:
+			if len(g.files) == 0 {
+				continue
+			}
+		case len(result.ReferenceDirs) > 0 || len(g.files) < 2:
+			continue
+		default:
+			g.suggest = rule(g.files)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// apply 按保留规则决定该组，只保留参考文件的组不受规则影响
+func (g *reviewGroup) apply(rule dedup.KeepRule) {
+	g.keep, g.skip, g.decided = -1, false, true
+	if len(g.refs) == 0 {
+		g.keep = rule(g.files)
+	}
+}
+
+// planGroup 返回该组的处理方式，跳过的组返回 false
+func (g *reviewGroup) planGroup() (dedup.PlanGroup, bool) {
+	if g.skip || !g.decided {
+		return dedup.PlanGroup{}, false
+	}
+	pg := dedup.PlanGroup{Hash: g.group.Hash, Size: g.group.Size}
+	if g.keep >= 0 {
+		pg.Keep = g.files[g.keep]
+	} else {
+		pg.Keep = g.refs[0]
+	}
+	for i, file := range g.files {
+		if i != g.keep {
+			pg.Remove = append(pg.Remove, file)
+		}
+	}
+	return pg, true
+}
+
+// reviewer 在终端中逐组审阅重复文件
+type reviewer struct {
+	keys *keyReader
+	out  io.Writer
+}
+
+// review 逐组显示重复文件并读取选择，返回选择后的处理计划。
+// 数字键保留对应的文件，回车接受建议，s 跳过，a 按规则处理其余所有组，p 返回上一组，q 结束审阅。
+func (r *reviewer) review(groups []*reviewGroup) (*dedup.Plan, error) {
+	for i := 0; i < len(groups); {
+		g := groups[i]
+		r.printGroup(g, i, len(groups))
+
+		key, n, err := r.readChoice(g)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case key == 'p':
+			if i > 0 {
+				i--
+			}
+			continue
+		case key == 'q':
+			fmt.Fprintln(r.out, "→ 结束审阅，其余的组保持不变")
+			i = len(groups)
+			continue
+		case key == 'a':
+			rule, err := r.readRule()
+			if err != nil {
+				return nil, err
+			}
+			if rule == nil {
+				continue
+			}
+			for _, rest := range groups[i:] {
+				rest.apply(rule)
+			}
+			fmt.Fprintf(r.out, "→ 其余 %d 组按规则处理\n", len(groups)-i)
+			i = len(groups)
+			continue
+		case key == 's':
+			g.skip, g.decided = true, true
+			fmt.Fprintln(r.out, "→ 跳过，全部保留")
+		case key == keyEnter:
+			g.keep, g.skip, g.decided = g.suggest, false, true
+			r.printDecision(g)
+		default:
+			g.keep, g.skip, g.decided = n-1, false, true
+			r.printDecision(g)
+		}
+		i++
+	}
+
+	plan := &dedup.Plan{Groups: []dedup.PlanGroup{}}
+	for _, g := range groups {
+		if pg, ok := g.planGroup(); ok {
+			plan.Groups = append(plan.Groups, pg)
+		}
+	}
+	return plan, nil
+}
+
+func (r *reviewer) printGroup(g *reviewGroup, i, total int) {
+	fmt.Fprintf(r.out, "\n[%d/%d] 哈希 %.12s  每个文件 %s\n", i+1, total, g.group.Hash, utils.FormatSize(g.group.Size))
+	n := 0
+	for _, file := range g.group.Files {
+		label := "    "
+		switch {
+		case file.InArchive():
+			label = "归档"
+		case file.Reference:
+			label = "参考"
+		default:
+			n++
+			label = fmt.Sprintf("%3d)", n)
+			if n-1 == g.suggest {
+				label = fmt.Sprintf("*%2d)", n)
+			}
+		}
+		fmt.Fprintf(r.out, "  %s %10s  %s  %s\n", label, utils.FormatSize(file.Size), file.ModTime.Local().Format("2006-01-02 15:04"), file.Path)
+	}
+}
+
+// readChoice 读取对一组的选择，返回按键；选择了文件时按键为 0，n 为从 1 起的文件编号。
+// 超过 9 个文件时两位编号需要再按一次数字，一位编号再按回车；
+// 第一位之后按下其他键时选择一位编号，该键留给下一次读取
+func (r *reviewer) readChoice(g *reviewGroup) (key byte, n int, err error) {
+	suggest := "只保留参考文件"
+	if g.suggest >= 0 {
+		suggest = fmt.Sprintf("保留 %d", g.suggest+1)
+	}
+	fmt.Fprintf(r.out, "保留哪个? 1-%d 保留该文件 · 回车 %s · s 跳过 · a 其余按规则 · p 上一组 · q 结束 › ", len(g.files), suggest)
+	for {
+		key, err = r.keys.readKey()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errReviewAborted
+			}
+			return 0, 0, err
+		}
+		switch key {
+		case keyCtrlC:
+			fmt.Fprintln(r.out)
+			return 0, 0, errReviewAborted
+		case keyEnter, 's', 'a', 'p', 'q':
+			fmt.Fprintln(r.out)
+			return key, 0, nil
+		}
+		if key < '1' || key > '9' {
+			continue
+		}
+
+		n = int(key - '0')
+		if n > len(g.files) {
+			continue
+		}
+		// 可能是两位编号的第一位
+		if n*10 <= len(g.files) {
+			fmt.Fprintf(r.out, "%d", n)
+			next, err := r.keys.readKey()
+			if err != nil {
+				return 0, 0, err
+			}
+			if next >= '0' && next <= '9' && n*10+int(next-'0') <= len(g.files) {
+				n = n*10 + int(next-'0')
+			} else if next != keyEnter {
+				r.keys.unreadKey(next)
+			}
+			fmt.Fprintln(r.out)
+		} else {
+			fmt.Fprintln(r.out, n)
+		}
+		return 0, n, nil
+	}
+}
+
+// readRule 读取应用到其余所有组的保留规则，按其他键返回 nil
+func (r *reviewer) readRule() (dedup.KeepRule, error) {
+	fmt.Fprint(r.out, "其余所有组按哪个规则保留? f 第一个 · o 最旧 · n 最新 · l 路径最短 · 其他键返回 › ")
+	key, err := r.keys.readKey()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(r.out)
+	switch key {
+	case 'f':
+		return dedup.KeepFirst, nil
+	case 'o':
+		return dedup.KeepOldest, nil
+	case 'n':
+		return dedup.KeepNewest, nil
+	case 'l':
+		return dedup.KeepShortestPath, nil
+	case keyCtrlC:
+		return nil, errReviewAborted
+	}
+	return nil, nil
+}
+
+func (r *reviewer) printDecision(g *reviewGroup) {
+	removed := len(g.files)
+	if g.keep >= 0 {
+		fmt.Fprintf(r.out, "→ 保留 %d，", g.keep+1)
+		removed--
+	} else {
+		fmt.Fprint(r.out, "→ 保留参考文件，")
+	}
+	fmt.Fprintf(r.out, "移除 %d 个文件\n", removed)
+}
+
+// confirm 显示问题并读取 y/n，只有 y 表示确认
+func (r *reviewer) confirm(question string) (bool, error) {
+	fmt.Fprintf(r.out, "%s [y/N] › ", question)
+	key, err := r.keys.readKey()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	fmt.Fprintln(r.out)
+	return key == 'y' || key == 'Y', nil
+}
+
+// runInteractive 在终端中审阅扫描结果，确认后按选择移到回收站或删除并记录操作日志。
+// rule 决定每组建议保留的文件。
+func runInteractive(ctx context.Context, result *dedup.Result, rule dedup.KeepRule, useTrash bool) {
+	groups := newReviewGroups(result, rule)
+	if len(groups) == 0 {
+		fmt.Println("没有需要处理的重复文件")
+		os.Exit(exitOK)
+	}
+	fmt.Printf("发现 %d 组可以处理的重复文件，共可释放 %s\n", len(groups), utils.FormatSize(dedup.NewPlan(result, rule).Reclaimable()))
+
+	r := &reviewer{keys: newKeyReader(os.Stdin), out: os.Stdout}
+	plan, err := r.review(groups)
+	if err == nil && len(plan.Groups) == 0 {
+		fmt.Println("没有选择要移除的文件")
+		os.Exit(exitOK)
+	}
+	var ok bool
+	if err == nil {
+		files := 0
+		for _, group := range plan.Groups {
+			files += len(group.Remove)
+		}
+		verb := "移到回收站"
+		if !useTrash {
+			verb = "永久删除"
+		}
+		ok, err = r.confirm(fmt.Sprintf("\n将%s %d 组中的 %d 个文件，释放 %s，确认执行?", verb, len(plan.Groups), files, utils.FormatSize(plan.Reclaimable())))
+	}
+	if errors.Is(err, errReviewAborted) || (err == nil && !ok) {
+		fmt.Println(errReviewAborted)
+		os.Exit(exitOK)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取输入失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	report, err := applyPlan(ctx, plan, useTrash, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "删除失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("  [失败] %s (%v)\n", f.File.Path, f.Err)
+	}
+	fmt.Printf("已移除 %d 个文件，释放 %s，失败 %d 个\n", len(report.Removed), utils.FormatSize(report.FreedSize), len(report.Failed))
+	if len(report.Failed) > 0 {
+		os.Exit(exitError)
+	}
+	os.Exit(exitOK)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// newTestReviewer 返回从 input 读取按键的 reviewer，换行视为回车
+func newTestReviewer(input string) *reviewer {
+	return &reviewer{keys: &keyReader{r: bufio.NewReader(strings.NewReader(input))}, out: io.Discard}
+}
+
+// testReviewGroup 返回有 n 个可移除文件的组
+func testReviewGroup(n int) *reviewGroup {
+	g := &reviewGroup{suggest: 0}
+	for i := 0; i < n; i++ {
+		g.files = append(g.files, dedup.FileInfo{Path: fmt.Sprintf("/f%d", i+1)})
+	}
+	return g
+}
+
+func TestReadChoice(t *testing.T) {
+	type choice struct {
+		key byte
+		n   int
+	}
+	tests := []struct {
+		name  string
+		files int
+		input string
+		want  []choice
+	}{
+		{"single digit", 3, "2", []choice{{0, 2}}},
+		{"out of range ignored", 3, "72", []choice{{0, 2}}},
+		{"commands", 3, "sap\nq", []choice{{'s', 0}, {'a', 0}, {'p', 0}, {keyEnter, 0}, {'q', 0}}},
+		{"other keys ignored", 3, "x0s", []choice{{'s', 0}}},
+		{"two digits", 12, "12", []choice{{0, 12}}},
+		{"one digit then enter", 12, "1\n3", []choice{{0, 1}, {0, 3}}},
+		// 第一位之后的按键不能丢失
+		{"one digit then command", 12, "1s", []choice{{0, 1}, {'s', 0}}},
+		{"one digit then digit out of range", 12, "152", []choice{{0, 1}, {0, 5}, {0, 2}}},
+		{"one digit then quit", 25, "2q", []choice{{0, 2}, {'q', 0}}},
+		{"digit without second place", 12, "3s", []choice{{0, 3}, {'s', 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReviewer(tt.input)
+			g := testReviewGroup(tt.files)
+			for i, want := range tt.want {
+				key, n, err := r.readChoice(g)
+				if err != nil {
+					t.Fatalf("choice %d: %v", i, err)
+				}
+				if key != want.key || n != want.n {
+					t.Errorf("choice %d = (%q, %d), want (%q, %d)", i, key, n, want.key, want.n)
+				}
+			}
+			if _, _, err := r.readChoice(g); !errors.Is(err, errReviewAborted) {
+				t.Errorf("输入结束时 error = %v, want errReviewAborted", err)
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	groups := []*reviewGroup{testReviewGroup(3), testReviewGroup(11), testReviewGroup(2)}
+	// 跳过第一组；第二组按 1 后紧接着按 p，选择 1 后从第三组返回第二组改为保留 10；第三组回车接受建议
+	plan, err := newTestReviewer("s1p10\n").review(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Groups) != 2 {
+		t.Fatalf("Groups = %+v, want 2", plan.Groups)
+	}
+	if keep := plan.Groups[0].Keep.Path; keep != "/f10" || len(plan.Groups[0].Remove) != 10 {
+		t.Errorf("group 2 keeps %s, removes %d", keep, len(plan.Groups[0].Remove))
+	}
+	if keep := plan.Groups[1].Keep.Path; keep != "/f1" {
+		t.Errorf("group 3 keeps %s, want the suggestion /f1", keep)
+	}
+}
@@ -1,127 +1,450 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/xiaozhe/dedupgo/internal/config"
-	"github.com/xiaozhe/dedupgo/internal/core"
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
 )
 
-var (
-	configFile    string
-	hashAlgorithm string
-	minSize      string
-	force        bool
-	outputFormat string
-	useTrash     bool
+// 退出码，便于在 CI 中判断结果
+const (
+	// exitOK 成功，没有发现重复文件
+	exitOK = 0
+	// exitFound 发现了重复文件（verify 为发现了不一致的文件）
+	exitFound = 1
+	// exitError 参数错误或运行失败
+	exitError = 2
 )
 
-func init() {
-	flag.StringVar(&configFile, "config", "", "配置文件路径")
-	flag.StringVar(&hashAlgorithm, "hash", "md5", "哈希算法 (md5/sha256)")
-	flag.StringVar(&minSize, "min-size", "0", "最小文件大小 (例如: 10MB)")
-	flag.BoolVar(&force, "force", false, "强制删除重复文件")
-	flag.StringVar(&outputFormat, "output", "txt", "输出格式 (txt/json)")
-	flag.BoolVar(&useTrash, "trash", true, "使用回收站")
+// command 一个子命令
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands 所有子命令，按帮助信息中的显示顺序排列
+var commands = []command{
+	{"scan", "扫描目录，查找重复文件", runScan},
+	{"tui", "在全屏终端界面中浏览和处理扫描结果", runTUI},
+	{"plan", "根据保存的扫描结果生成处理计划", runPlan},
+	{"apply", "执行处理计划，将文件移到回收站或删除", runApply},
+	{"report", "将保存的扫描结果输出为各种格式的报告", runReport},
+	{"restore", "根据操作日志恢复被移除的文件", runRestore},
+	{"cache", "查看和清理增量扫描状态", runCache},
+	{"config", "查看配置", runConfig},
+	{"diff", "按内容比较两个目录", runDiff},
+	{"manifest", "生成校验清单", runManifest},
+	{"verify", "按校验清单检查目录", runVerify},
+	{"watch", "持续监视目录中新出现的重复文件", runWatch},
+	{"serve", "启动浏览器界面和 HTTP API 服务", runServe},
+	{"import", "执行 fdupes/jdupes/rmlint 的结果", runImport},
+	{"version", "显示版本信息", runVersion},
 }
 
 func main() {
-	flag.Parse()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitError)
+	}
 
-	// 加载配置
-	cfg, err := config.LoadConfig(configFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
-		os.Exit(1)
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(os.Args) > 2 {
+			cmd := lookupCommand(os.Args[2])
+			if cmd == nil {
+				fmt.Fprintf(os.Stderr, "未知的命令: %s\n", os.Args[2])
+				os.Exit(exitError)
+			}
+			cmd.run([]string{"-h"})
+			return
+		}
+		usage()
+		return
+	case "-v", "-version", "--version":
+		runVersion(nil)
+		return
+	}
+
+	if cmd := lookupCommand(name); cmd != nil {
+		cmd.run(os.Args[2:])
+		return
+	}
+
+	// 兼容旧版本的用法：第一个参数是选项或已存在的路径时按 scan 处理
+	if _, err := os.Stat(name); err == nil || strings.HasPrefix(name, "-") {
+		runScan(os.Args[1:])
+		return
 	}
+	fmt.Fprintf(os.Stderr, "未知的命令: %s\n运行 dedupgo help 查看可用的命令\n", name)
+	os.Exit(exitError)
+}
 
-	// 命令行参数覆盖配置文件
-	if hashAlgorithm != "md5" {
-		cfg.HashAlgorithm = hashAlgorithm
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
 	}
-	if minSize != "0" {
-		cfg.MinSize = minSize
+	return nil
+}
+
+func usage() {
+	out := os.Stderr
+	fmt.Fprintln(out, "DedupGo - 查找和处理重复文件")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "用法: dedupgo <命令> [选项] [参数]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
-	cfg.DryRun = !force
-	if outputFormat != "txt" {
-		cfg.OutputFormat = outputFormat
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "运行 dedupgo help <命令> 或 dedupgo <命令> -h 查看命令的选项。")
+	fmt.Fprintln(out, "退出码: 0 没有发现重复文件，1 发现重复文件，2 出错。")
+}
+
+// commandFlags 子命令的参数集合，可以为长参数注册单字母的短参数，帮助信息中合并显示。
+// 长参数写作 --name 或 -name，短参数写作 -x。
+type commandFlags struct {
+	*flag.FlagSet
+	// shorts 长参数名到短参数名的映射
+	shorts map[string]string
+}
+
+// newCommandFlags 创建子命令的参数集合，usage 为参数格式（如 "[选项] DIR..."），summary 为命令说明
+func newCommandFlags(name, usage, summary string) *commandFlags {
+	f := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ExitOnError), shorts: make(map[string]string)}
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "用法: dedupgo %s %s\n\n%s\n\n选项:\n", name, usage, summary)
+		f.printDefaults()
 	}
-	cfg.UseTrash = useTrash
+	return f
+}
+
+// short 为已定义的长参数 long 注册短参数
+func (f *commandFlags) short(short, long string) {
+	fl := f.Lookup(long)
+	f.Var(fl.Value, short, fl.Usage)
+	f.shorts[long] = short
+}
 
-	// 获取扫描目录
-	dirs := flag.Args()
-	if len(dirs) == 0 {
-		fmt.Fprintln(os.Stderr, "错误: 请指定至少一个扫描目录")
-		flag.Usage()
-		os.Exit(1)
+// printDefaults 与 flag.PrintDefaults 类似，短参数与对应的长参数显示在同一行
+func (f *commandFlags) printDefaults() {
+	aliases := make(map[string]bool)
+	for _, s := range f.shorts {
+		aliases[s] = true
 	}
 
-	// 创建扫描器
-	scanner := core.NewScanner(
-		cfg.HashAlgorithm,
-		0, // minSize 将在 Scanner 中解析
-		cfg.IncludeTypes,
-		cfg.ExcludePatterns,
-	)
+	out := f.Output()
+	f.VisitAll(func(fl *flag.Flag) {
+		if aliases[fl.Name] {
+			return
+		}
+		name := "    --" + fl.Name
+		if s, ok := f.shorts[fl.Name]; ok {
+			name = "-" + s + ", --" + fl.Name
+		}
+		typeName, usage := flag.UnquoteUsage(fl)
+		if typeName != "" {
+			name += " " + typeName
+		}
+		fmt.Fprintf(out, "  %s\n    \t%s", name, usage)
+		switch fl.DefValue {
+		case "", "0", "false":
+		default:
+			fmt.Fprintf(out, " (默认 %s)", fl.DefValue)
+		}
+		fmt.Fprintln(out)
+	})
+}
+
+// stringList 可重复指定的字符串参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// openInput 打开输入文件，path 为 "-" 时使用标准输入
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
 
-	// 执行扫描
-	result, err := scanner.Scan(dirs...)
+// readResultFile 读取 scan --output json 保存的扫描结果
+func readResultFile(path string) (*dedup.Result, error) {
+	f, err := openInput(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
-		os.Exit(1)
+		return nil, err
+	}
+	defer f.Close()
+	return dedup.ReadResult(f)
+}
+
+// validOutputFormat 判断是否为支持的输出格式
+func validOutputFormat(format string) bool {
+	for _, f := range config.OutputFormats {
+		if format == f {
+			return true
+		}
 	}
+	return false
+}
+
+// isTextFormat 判断是否为默认的文本输出格式
+func isTextFormat(format string) bool {
+	return format == "txt" || format == "text"
+}
 
-	// 输出结果
-	switch strings.ToLower(cfg.OutputFormat) {
+// outputReport 按格式输出扫描结果，ndjson 需要边扫描边输出，不在此处理
+func outputReport(w io.Writer, format string, view *reportView) error {
+	switch format {
 	case "json":
-		outputJSON(result)
+		return outputJSON(w, view.Result)
+	case "csv":
+		return outputCSV(w, view)
+	case "html":
+		return outputHTML(w, view)
+	case "md", "markdown":
+		return outputMarkdown(w, view)
+	case "fdupes":
+		return outputFdupes(w, view.Result)
 	default:
-		outputText(result, cfg.DryRun)
+		outputText(w, view)
+		return nil
+	}
+}
+
+// applyPlan 执行计划，移到回收站或直接删除，每个被移除的文件都记录到操作日志中。
+// checkContent 为 true 时移除前重新计算哈希值，用于执行从文件读入的计划。
+func applyPlan(ctx context.Context, plan *dedup.Plan, useTrash, checkContent bool) (*dedup.ApplyReport, error) {
+	var executor dedup.Executor = dedup.DeleteExecutor{}
+	action := dedup.JournalDelete
+	if useTrash {
+		executor = dedup.TrashExecutor{}
+		action = dedup.JournalTrash
+	}
+
+	path, err := config.JournalPath()
+	if err != nil {
+		return nil, err
+	}
+	journal, err := dedup.OpenJournal(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开操作日志失败: %v", err)
+	}
+
+	if checkContent {
+		executor = plan.CheckContent(executor)
 	}
+	report, err := plan.Apply(ctx, journal.Executor(plan, executor, action))
+	if cerr := journal.Close(); cerr != nil {
+		fmt.Fprintf(os.Stderr, "警告: 写入操作日志失败: %v\n", cerr)
+	}
+	return report, err
+}
+
+// openOutput 打开报告的输出位置，path 为空时使用标准输出。
+// 返回的 closeOutput 关闭文件，失败时退出程序，成功时在标准错误中提示报告位置。
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建报告文件失败: %v", err)
+	}
+	return f, func() {
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "写入报告文件失败: %v\n", err)
+			os.Exit(exitError)
+		}
+		fmt.Fprintf(os.Stderr, "报告已写入 %s\n", path)
+	}, nil
 }
 
-func outputJSON(result *core.Result) {
-	encoder := json.NewEncoder(os.Stdout)
+
+// newScanner 根据配置创建扫描器
+func newScanner(cfg *config.Config, extra ...dedup.Option) (*dedup.Scanner, error) {
+	opts, err := cfg.ScanOptions()
+	if err != nil {
+		return nil, err
+	}
+	return dedup.New(append(opts, extra...)...)
+}
+
+func outputJSON(w io.Writer, result *dedup.Result) error {
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(result); err != nil {
+	return encoder.Encode(result)
+}
+
+// ndjsonGroup NDJSON 输出中的重复文件组记录
+type ndjsonGroup struct {
+	Type string `json:"type"`
+	dedup.DuplicateGroup
+}
+
+// ndjsonSummary NDJSON 输出的最后一行，扫描结束后写出
+type ndjsonSummary struct {
+	Type       string `json:"type"`
+	TotalFiles int    `json:"total_files"`
+	TotalSize  int64  `json:"total_size"`
+	SavedSize  int64  `json:"saved_size"`
+	Groups     int    `json:"groups"`
+}
+
+// outputNDJSON 边扫描边输出重复组，返回输出的组数
+func outputNDJSON(ctx context.Context, w io.Writer, scanner *dedup.Scanner, dirs []string) int {
+	encoder := json.NewEncoder(w)
+	groups := 0
+
+	result, err := scanner.Stream(ctx, func(group dedup.DuplicateGroup) error {
+		groups++
+		return encoder.Encode(ndjsonGroup{Type: "group", DuplicateGroup: group})
+	}, dirs...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	err = encoder.Encode(ndjsonSummary{
+		Type:       "summary",
+		TotalFiles: result.TotalFiles,
+		TotalSize:  result.TotalSize,
+		SavedSize:  result.SavedSize,
+		Groups:     groups,
+	})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "JSON输出失败: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+	return groups
 }
 
-func outputText(result *core.Result, isDryRun bool) {
-	fmt.Printf("扫描完成！\n")
-	fmt.Printf("总文件数: %d\n", result.TotalFiles)
-	fmt.Printf("总大小: %.2f MB\n", float64(result.TotalSize)/(1024*1024))
-	fmt.Printf("可节省空间: %.2f MB\n\n", float64(result.SavedSize)/(1024*1024))
+func outputText(w io.Writer, view *reportView) {
+	result := view.Result
+	fmt.Fprintf(w, "扫描完成！\n")
+	fmt.Fprintf(w, "总文件数: %d\n", result.TotalFiles)
+	fmt.Fprintf(w, "总大小: %.2f MB\n", float64(result.TotalSize)/(1024*1024))
+	fmt.Fprintf(w, "可节省空间: %.2f MB\n\n", float64(result.SavedSize)/(1024*1024))
+
+	outputSimilarText(w, result)
+	outputDirsText(w, result)
 
-	if len(result.DuplicateGroups) == 0 {
-		fmt.Println("未发现重复文件")
+	if len(view.Groups) == 0 {
+		fmt.Fprintln(w, "未发现重复文件")
 		return
 	}
 
-	fmt.Printf("发现 %d 组重复文件:\n\n", len(result.DuplicateGroups))
-	for hash, files := range result.DuplicateGroups {
-		fmt.Printf("哈希值: %s\n", hash)
-		for i, file := range files {
-			if i == 0 {
-				fmt.Printf("  [保留] %s\n", file)
-			} else {
-				if isDryRun {
-					fmt.Printf("  [待删除] %s\n", file)
-				} else {
-					fmt.Printf("  [已删除] %s\n", file)
-				}
+	fmt.Fprintf(w, "发现 %d 组重复文件:\n\n", view.Handled())
+	skipped := false
+	for _, group := range view.Groups {
+		if group.Skipped && !skipped {
+			skipped = true
+			fmt.Fprintf(w, "另有 %d 组重复文件不在参考目录中，不会被处理:\n\n", len(view.Groups)-view.Handled())
+		}
+		fmt.Fprintf(w, "哈希值: %s (%s, %s)\n", group.Hash, group.Algorithm, utils.FormatSize(group.Size))
+		for _, file := range group.Files {
+			switch file.Action {
+			case actionSkip:
+				fmt.Fprintf(w, "  %s\n", file.Path)
+			case actionFailed:
+				fmt.Fprintf(w, "  [%s] %s (%v)\n", file.Label(), file.Path, file.Err)
+			default:
+				fmt.Fprintf(w, "  [%s] %s\n", file.Label(), file.Path)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if !view.Applied {
+		fmt.Fprintln(w, "提示: 这是预览模式。使用 --force 参数执行实际删除操作。")
+		return
+	}
+	fmt.Fprintf(w, "已删除 %d 个文件，释放 %s，失败 %d 个\n",
+		view.Removed, utils.FormatSize(view.FreedSize), view.Failed)
+}
+
+func outputSimilarText(w io.Writer, result *dedup.Result) {
+	if len(result.SimilarGroups) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "发现 %d 组相似图片:\n\n", len(result.SimilarGroups))
+	for i, group := range result.SimilarGroups {
+		fmt.Fprintf(w, "相似组 %d (%s)\n", i+1, group.Algorithm)
+		for _, file := range group.Files {
+			fmt.Fprintf(w, "  [距离 %2d] %s (%s)\n", file.Distance, file.Path, utils.FormatSize(file.Size))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func outputDirsText(w io.Writer, result *dedup.Result) {
+	if len(result.DuplicateDirs) > 0 {
+		fmt.Fprintf(w, "发现 %d 组完全相同的目录:\n\n", len(result.DuplicateDirs))
+		for _, group := range result.DuplicateDirs {
+			fmt.Fprintf(w, "%d 个文件，%s，可节省 %s\n", group.Files, utils.FormatSize(group.Size), utils.FormatSize(group.Reclaimable()))
+			for _, dir := range group.Dirs {
+				fmt.Fprintf(w, "  📁 %s\n", dir)
 			}
+			fmt.Fprintln(w)
 		}
-		fmt.Println()
 	}
 
-	if isDryRun {
-		fmt.Println("提示: 这是预览模式。使用 --force 参数执行实际删除操作。")
+	if len(result.DirSubsets) > 0 {
+		fmt.Fprintf(w, "发现 %d 个目录的内容已完全包含在其他目录中:\n\n", len(result.DirSubsets))
+		for _, subset := range result.DirSubsets {
+			fmt.Fprintf(w, "  📁 %s (%d 个文件，%s)\n", subset.Subset, subset.Files, utils.FormatSize(subset.Size))
+			fmt.Fprintf(w, "     ⊆ %s\n", subset.Superset)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func outputChangesText(w io.Writer, result *dedup.Result, changes []dedup.GroupChange) {
+	fmt.Fprintf(w, "\n增量扫描: 复用 %d 个文件的哈希值\n", result.CachedFiles)
+	if changes == nil {
+		fmt.Fprintln(w, "首次使用该状态扫描，下次扫描时将报告新出现的重复")
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "自上次扫描以来没有新出现的重复文件")
+		return
 	}
-} 
\ No newline at end of file
+
+	fmt.Fprintf(w, "自上次扫描以来新出现 %d 组重复:\n\n", len(changes))
+	for _, change := range changes {
+		fmt.Fprintf(w, "哈希值: %s (%s)\n", change.Hash, utils.FormatSize(change.Size))
+		added := make(map[string]bool)
+		for _, p := range change.Added {
+			added[p] = true
+		}
+		for _, file := range change.Files {
+			if added[file.Path] {
+				fmt.Fprintf(w, "  [新] %s\n", file.Path)
+			} else {
+				fmt.Fprintf(w, "       %s\n", file.Path)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runManifest 执行 dedupgo manifest DIR：生成校验清单
+func runManifest(args []string) {
+	fs := newCommandFlags("manifest", "[选项] DIR",
+		"计算 DIR 下所有文件的哈希，生成校验清单，路径相对 DIR")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	fs.String("hash", "sha256", "哈希算法 (md5/sha256)，未指定时使用配置中的 hash_algorithm，都没有设置时为 sha256")
+	fs.String("min-size", "0", "最小文件大小 (例如: 10MB)")
+	format := fs.String("format", "sum", "清单格式 (sum: 与 sha256sum/md5sum 兼容; json: 包含大小和修改时间)")
+	outputFile := fs.String("output-file", "", "写入的清单文件，默认输出到标准输出")
+	exclude := addExcludeFlag(fs)
+	fs.short("o", "output-file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	settings, err := loadSettings(fs, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+	// 清单默认使用 sha256，便于用 sha256sum -c 校验；--hash、配置文件和环境变量中的设置优先
+	if settings.Origin("hash_algorithm").Source == config.SourceDefault {
+		cfg.HashAlgorithm = dedup.SHA256
+	}
+	scanner, err := newManifestScanner(cfg, exclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	root := fs.Arg(0)
+	manifest, err := scanner.Manifest(context.Background(), root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成清单失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	var out io.Writer = os.Stdout
+	var f *os.File
+	if *outputFile != "" {
+		f, err = os.Create(*outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建清单文件失败: %v\n", err)
+			os.Exit(exitError)
+		}
+		out = f
+
+		// 清单写在被校验的目录中时，不记录清单文件自身
+		excludeManifestFile(manifest, root, *outputFile)
+	}
+
+	switch strings.ToLower(*format) {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(manifest)
+	case "sum":
+		err = manifest.WriteChecksums(out)
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 未知的清单格式: %s\n", *format)
+		os.Exit(exitError)
+	}
+	// 关闭时才写入磁盘的数据也可能失败，此时清单不完整
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "写入清单失败: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// runVerify 执行 dedupgo verify MANIFEST [DIR]：按清单校验目录
+func runVerify(args []string) {
+	fs := newCommandFlags("verify", "[选项] MANIFEST [DIR]",
+		"按清单校验 DIR，报告丢失、被修改和新增的文件。\n"+
+			"未指定 DIR 时，JSON 清单使用其中记录的根目录，sha256sum 格式的清单使用清单文件所在目录。")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	fs.String("min-size", "0", "最小文件大小，应与生成清单时一致 (例如: 10MB)")
+	outputFormat := fs.String("output", "txt", "输出格式 (txt/json)")
+	exclude := addExcludeFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	manifestFile := fs.Arg(0)
+	f, err := os.Open(manifestFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开清单失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	manifest, err := dedup.ReadManifest(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取清单失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	root := fs.Arg(1)
+	if root == "" {
+		root = manifest.Root
+	}
+	if root == "" {
+		root = filepath.Dir(manifestFile)
+	}
+
+	settings, err := loadSettings(fs, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+	scanner, err := newManifestScanner(cfg, exclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	result, err := scanner.Verify(context.Background(), root, manifest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	result.New = withoutFile(result.New, root, manifestFile)
+
+	switch strings.ToLower(*outputFormat) {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			fmt.Fprintf(os.Stderr, "JSON输出失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	default:
+		outputVerifyText(result)
+	}
+
+	if !result.Clean() {
+		os.Exit(exitFound)
+	}
+}
+
+// newManifestScanner 创建生成和校验清单用的扫描器，不需要归档、相似图片等附加分析。
+// 清单应包含目录中的全部文件，只排除 --exclude 显式指定的模式。
+func newManifestScanner(cfg *config.Config, exclude *stringList) (*dedup.Scanner, error) {
+	cfg.ScanArchives = false
+	cfg.SimilarImages = ""
+	cfg.DuplicateDirs = false
+	cfg.ReferenceDirs = nil
+	var err error
+	if cfg.ExcludePatterns, err = explicitExcludes(exclude); err != nil {
+		return nil, err
+	}
+	return newScanner(cfg)
+}
+
+// excludeManifestFile 从清单中去掉位于 root 下的清单文件自身
+func excludeManifestFile(manifest *dedup.Manifest, root, manifestFile string) {
+	manifest.Entries = withoutFile(manifest.Entries, root, manifestFile)
+}
+
+// withoutFile 从清单条目中去掉指定文件，条目的路径相对 root
+func withoutFile(entries []dedup.ManifestEntry, root, name string) []dedup.ManifestEntry {
+	rel, ok := manifestPath(root, name)
+	if !ok {
+		return entries
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Path != rel {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// manifestPath 返回 name 在以 root 为根的清单中的路径
+func manifestPath(root, name string) (string, bool) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, abs)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func outputVerifyText(result *dedup.VerifyResult) {
+	fmt.Printf("校验目录: %s (%s)\n", result.Root, result.Algorithm)
+	fmt.Printf("一致: %d 个文件\n\n", result.OK)
+
+	if len(result.Missing) > 0 {
+		fmt.Printf("丢失 %d 个文件:\n", len(result.Missing))
+		for _, entry := range result.Missing {
+			fmt.Printf("  [丢失] %s\n", entry.Path)
+		}
+		fmt.Println()
+	}
+
+	if len(result.Modified) > 0 {
+		fmt.Printf("修改 %d 个文件:\n", len(result.Modified))
+		for _, m := range result.Modified {
+			if m.Corrupt {
+				fmt.Printf("  [损坏] %s (大小和修改时间未变，内容已变化)\n", m.Entry.Path)
+			} else {
+				fmt.Printf("  [修改] %s\n", m.Entry.Path)
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(result.New) > 0 {
+		fmt.Printf("新增 %d 个文件:\n", len(result.New))
+		for _, entry := range result.New {
+			fmt.Printf("  [新增] %s (%s)\n", entry.Path, utils.FormatSize(entry.Size))
+		}
+		fmt.Println()
+	}
+
+	if result.Clean() {
+		fmt.Println("校验通过")
+	} else {
+		fmt.Println("校验未通过")
+	}
+}
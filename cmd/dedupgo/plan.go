@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runPlan 执行 dedupgo plan RESULT：按保留规则为保存的扫描结果生成处理计划
+func runPlan(args []string) {
+	fs := newCommandFlags("plan", "[选项] RESULT",
+		"读取 scan --output json 保存的扫描结果（- 表示标准输入），按保留规则生成处理计划。\n"+
+			"JSON 格式的计划可以检查或修改后交给 dedupgo apply 执行。")
+	keepRule := fs.String("keep", "first", "每组保留哪个文件 (first/oldest/newest/shortest)")
+	outputFormat := fs.String("output", "json", "输出格式 (json/txt)")
+	outputFile := fs.String("output-file", "", "将计划写入文件而不是标准输出")
+	fs.short("k", "keep")
+	fs.short("o", "output")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	result, err := readResultFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取扫描结果失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	rule, err := dedup.KeepRuleByName(*keepRule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	plan := dedup.NewPlan(result, rule)
+
+	out, closeOutput, err := openOutput(*outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	switch strings.ToLower(*outputFormat) {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(plan)
+	case "txt", "text":
+		outputPlanText(out, plan)
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 未知的输出格式: %s\n", *outputFormat)
+		os.Exit(exitError)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "输出计划失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	closeOutput()
+	exitWithGroups(len(plan.Groups))
+}
+
+func outputPlanText(w io.Writer, plan *dedup.Plan) {
+	if len(plan.Groups) == 0 {
+		fmt.Fprintln(w, "没有需要处理的重复文件")
+		return
+	}
+
+	files := 0
+	for _, group := range plan.Groups {
+		fmt.Fprintf(w, "哈希值: %s (%s)\n", group.Hash, utils.FormatSize(group.Size))
+		fmt.Fprintf(w, "  [保留] %s\n", group.Keep.Path)
+		for _, file := range group.Remove {
+			fmt.Fprintf(w, "  [移除] %s\n", file.Path)
+		}
+		fmt.Fprintln(w)
+		files += len(group.Remove)
+	}
+	fmt.Fprintf(w, "共 %d 组，移除 %d 个文件，可释放 %s\n", len(plan.Groups), files, utils.FormatSize(plan.Reclaimable()))
+}
+
+// runApply 执行 dedupgo apply PLAN：执行 dedupgo plan 生成的处理计划
+func runApply(args []string) {
+	fs := newCommandFlags("apply", "[选项] PLAN",
+		"执行 dedupgo plan 生成的处理计划（- 表示标准输入）。执行前会重新检查每个文件并重新计算哈希值，\n"+
+			"扫描之后被修改过的文件不会被移除；同一文件在计划中出现多次时不执行。移除的文件记录在操作日志中，可以用 dedupgo restore 恢复。\n"+
+			"全部成功时退出码为 0，有文件处理失败时为 2。")
+	useTrash := fs.Bool("trash", true, "使用回收站，为 false 时直接删除")
+	dryRun := fs.Bool("dry-run", false, "只检查计划，不移除任何文件")
+	fs.short("n", "dry-run")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	f, err := openInput(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取计划失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	var plan dedup.Plan
+	err = json.NewDecoder(f).Decode(&plan)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取计划失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	ctx := context.Background()
+	var report *dedup.ApplyReport
+	if *dryRun {
+		report, err = plan.Apply(ctx, plan.CheckContent(dedup.DryRunExecutor{}))
+	} else {
+		report, err = applyPlan(ctx, &plan, *useTrash, true)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "执行失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	label, verb := "已移除", "已移除"
+	if *dryRun {
+		label, verb = "将移除", "预览: 将移除"
+	}
+	for _, file := range report.Removed {
+		fmt.Printf("  [%s] %s\n", label, file.Path)
+	}
+	for _, f := range report.Failed {
+		fmt.Printf("  [失败] %s (%v)\n", f.File.Path, f.Err)
+	}
+	fmt.Printf("%s %d 个文件，释放 %s，失败 %d 个\n", verb, len(report.Removed), utils.FormatSize(report.FreedSize), len(report.Failed))
+	if len(report.Failed) > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// runReport 执行 dedupgo report RESULT：将保存的扫描结果输出为报告
+func runReport(args []string) {
+	fs := newCommandFlags("report", "[选项] RESULT",
+		"读取 scan --output json 保存的扫描结果（- 表示标准输入），输出为指定格式的报告，\n"+
+			"报告中的保留和待删除按保留规则标注。")
+	keepRule := fs.String("keep", "first", "每组保留哪个文件 (first/oldest/newest/shortest)")
+	outputFormat := fs.String("output", "txt", "输出格式 (txt/json/csv/html/md/fdupes)")
+	outputFile := fs.String("output-file", "", "将报告写入文件而不是标准输出")
+	fs.short("k", "keep")
+	fs.short("o", "output")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	format := strings.ToLower(*outputFormat)
+	if !validOutputFormat(format) || format == "ndjson" {
+		fmt.Fprintf(os.Stderr, "错误: 未知的输出格式: %s\n", *outputFormat)
+		os.Exit(exitError)
+	}
+
+	result, err := readResultFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取扫描结果失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	rule, err := dedup.KeepRuleByName(*keepRule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	out, closeOutput, err := openOutput(*outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	view := newReportView(result, dedup.NewPlan(result, rule), nil)
+	if err := outputReport(out, format, view); err != nil {
+		fmt.Fprintf(os.Stderr, "输出报告失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	closeOutput()
+	exitWithGroups(len(result.DuplicateGroups))
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// 报告中每个文件的处理方式
+const (
+	actionKeep      = "keep"
+	actionRemove    = "remove"
+	actionRemoved   = "removed"
+	actionFailed    = "failed"
+	actionReference = "reference"
+	actionArchive   = "archive"
+	actionSkip      = "skip"
+)
+
+// actionLabels 各处理方式在文本、HTML 和 Markdown 报告中显示的名称
+var actionLabels = map[string]string{
+	actionKeep:      "保留",
+	actionRemove:    "待删除",
+	actionRemoved:   "已删除",
+	actionFailed:    "删除失败",
+	actionReference: "参考",
+	actionArchive:   "归档内",
+	actionSkip:      "不处理",
+}
+
+// reportFile 报告中的一个文件及其处理方式
+type reportFile struct {
+	dedup.FileInfo
+	Action string
+	Err    error
+}
+
+// Label 返回处理方式的显示名称
+func (f reportFile) Label() string {
+	return actionLabels[f.Action]
+}
+
+// reportGroup 报告中的一组重复文件，ID 从 1 开始
+type reportGroup struct {
+	ID        int
+	Hash      string
+	Algorithm string
+	Size      int64
+	// Reclaimable 该组可释放的空间，与 DuplicateGroup.Reclaimable 相同
+	Reclaimable int64
+	// Skipped 为 true 表示参考目录模式下该组不含参考文件，不会被处理
+	Skipped bool
+	Files   []reportFile
+}
+
+// reportView 将扫描结果、执行计划和执行报告整理为按组、按文件的处理方式，供各种报告格式使用
+type reportView struct {
+	Result *dedup.Result
+	Groups []reportGroup
+	// Applied 为 true 表示已经执行了删除，否则是预览
+	Applied   bool
+	Removed   int
+	Failed    int
+	FreedSize int64
+	Created   time.Time
+}
+
+// newReportView 构造报告视图。参考目录模式下，含参考文件的组排在前面，其余的组标记为不处理
+func newReportView(result *dedup.Result, plan *dedup.Plan, report *dedup.ApplyReport) *reportView {
+	view := &reportView{Result: result, Applied: report != nil, Created: time.Now()}
+
+	toRemove := make(map[string]bool)
+	for _, group := range plan.Groups {
+		for _, file := range group.Remove {
+			toRemove[file.Path] = true
+		}
+	}
+	failed := make(map[string]error)
+	if report != nil {
+		for _, f := range report.Failed {
+			failed[f.File.Path] = f.Err
+		}
+		view.Removed = len(report.Removed)
+		view.Failed = len(report.Failed)
+		view.FreedSize = report.FreedSize
+	}
+
+	var groups, skipped []dedup.DuplicateGroup
+	for _, group := range result.DuplicateGroups {
+		if len(result.ReferenceDirs) > 0 && !group.HasReference() {
+			skipped = append(skipped, group)
+		} else {
+			groups = append(groups, group)
+		}
+	}
+
+	add := func(group dedup.DuplicateGroup, skip bool) {
+		rg := reportGroup{
+			ID:          len(view.Groups) + 1,
+			Hash:        group.Hash,
+			Algorithm:   group.Algorithm,
+			Size:        group.Size,
+			Skipped:     skip,
+			Reclaimable: group.Reclaimable(),
+		}
+		for _, file := range group.Files {
+			rf := reportFile{FileInfo: file}
+			err, isFailed := failed[file.Path]
+			switch {
+			case file.Reference:
+				rf.Action = actionReference
+			case file.InArchive():
+				rf.Action = actionArchive
+			case skip:
+				rf.Action = actionSkip
+			case !toRemove[file.Path]:
+				rf.Action = actionKeep
+			case report == nil:
+				rf.Action = actionRemove
+			case isFailed:
+				rf.Action = actionFailed
+				rf.Err = err
+			default:
+				rf.Action = actionRemoved
+			}
+			rg.Files = append(rg.Files, rf)
+		}
+		view.Groups = append(view.Groups, rg)
+	}
+	for _, group := range groups {
+		add(group, false)
+	}
+	for _, group := range skipped {
+		add(group, true)
+	}
+	return view
+}
+
+// Handled 返回会被处理的组数
+func (v *reportView) Handled() int {
+	n := 0
+	for _, group := range v.Groups {
+		if !group.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// outputCSV 每个文件输出一行，包含组编号、哈希值、大小和处理方式
+func outputCSV(w io.Writer, view *reportView) error {
+	cw := csv.NewWriter(w)
+	header := []string{"group", "hash", "algorithm", "size", "action", "path", "mtime", "archive", "error"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, group := range view.Groups {
+		for _, file := range group.Files {
+			errText := ""
+			if file.Err != nil {
+				errText = file.Err.Error()
+			}
+			record := []string{
+				strconv.Itoa(group.ID),
+				group.Hash,
+				group.Algorithm,
+				strconv.FormatInt(group.Size, 10),
+				file.Action,
+				file.Path,
+				file.ModTime.Format(time.RFC3339),
+				file.Archive,
+				errText,
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// outputFdupes 按 fdupes 的格式输出：每行一个路径，各组之间用空行分隔。
+// 归档内的成员不是真实的文件，不会输出；归档外少于两个文件的组也不会输出。
+func outputFdupes(w io.Writer, result *dedup.Result) error {
+	bw := bufio.NewWriter(w)
+	for _, group := range result.DuplicateGroups {
+		var paths []string
+		for _, file := range group.Files {
+			if !file.InArchive() {
+				paths = append(paths, file.Path)
+			}
+		}
+		if len(paths) < 2 {
+			continue
+		}
+		for _, path := range paths {
+			fmt.Fprintln(bw, path)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"size": utils.FormatSize,
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+}).Parse(reportHTML))
+
+// outputHTML 输出不依赖外部资源的 HTML 报告，重复组可以在浏览器中排序
+func outputHTML(w io.Writer, view *reportView) error {
+	return reportTemplate.Execute(w, view)
+}
+
+// outputMarkdown 输出 Markdown 报告，便于粘贴到工单中
+func outputMarkdown(w io.Writer, view *reportView) error {
+	result := view.Result
+	var b strings.Builder
+
+	b.WriteString("# 重复文件报告\n\n")
+	b.WriteString("| 项目 | 数值 |\n|------|------|\n")
+	fmt.Fprintf(&b, "| 总文件数 | %d |\n", result.TotalFiles)
+	fmt.Fprintf(&b, "| 总大小 | %s |\n", utils.FormatSize(result.TotalSize))
+	fmt.Fprintf(&b, "| 可节省空间 | %s |\n", utils.FormatSize(result.SavedSize))
+	fmt.Fprintf(&b, "| 重复文件组 | %d |\n", len(view.Groups))
+	fmt.Fprintf(&b, "| 哈希算法 | %s |\n", result.Algorithm)
+	if view.Applied {
+		fmt.Fprintf(&b, "| 已删除 | %d 个文件，释放 %s |\n", view.Removed, utils.FormatSize(view.FreedSize))
+		fmt.Fprintf(&b, "| 删除失败 | %d 个文件 |\n", view.Failed)
+	}
+	b.WriteString("\n")
+
+	if len(view.Groups) == 0 {
+		b.WriteString("未发现重复文件\n")
+	}
+	for _, group := range view.Groups {
+		fmt.Fprintf(&b, "## 第 %d 组：%d 个文件，每个 %s\n\n", group.ID, len(group.Files), utils.FormatSize(group.Size))
+		fmt.Fprintf(&b, "哈希值 `%s` (%s)", group.Hash, group.Algorithm)
+		if group.Skipped {
+			b.WriteString("，不在参考目录中，不会被处理")
+		}
+		b.WriteString("\n\n| 处理 | 路径 | 修改时间 |\n|------|------|----------|\n")
+		for _, file := range group.Files {
+			label := file.Label()
+			if file.Err != nil {
+				label += "：" + markdownText(file.Err.Error())
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", label, markdownCode(file.Path), file.ModTime.Format("2006-01-02 15:04:05"))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape 转义会破坏表格的字符
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// markdownText 转义普通文本，尖括号和 & 不会被当作 HTML 标签或实体
+func markdownText(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "<", `\<`, ">", `\>`, "&", `\&`).Replace(s)
+	return markdownEscape(s)
+}
+
+// markdownCode 将 s 放入行内代码中，s 本身含有反引号时使用双反引号包围
+func markdownCode(s string) string {
+	s = markdownEscape(s)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+// testReportView 一个已执行的计划：含需要转义的路径和删除失败的文件，以及一个不处理的组
+func testReportView() *reportView {
+	mtime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	file := func(path string, reference bool) dedup.FileInfo {
+		return dedup.FileInfo{Path: path, Size: 1024, ModTime: mtime, Reference: reference}
+	}
+	result := &dedup.Result{
+		Algorithm:     dedup.MD5,
+		ReferenceDirs: []string{"ref"},
+		TotalFiles:    6,
+		TotalSize:     6 * 1024,
+		SavedSize:     3 * 1024,
+		DuplicateGroups: []dedup.DuplicateGroup{
+			{Hash: "d41d8cd98f00b204e9800998ecf8427e", Algorithm: dedup.MD5, Size: 1024, Files: []dedup.FileInfo{
+				file("photos/a,b.jpg", false),
+				file("photos/<b>&amp;.jpg", false),
+				file("photos/new\nline \"q\".jpg", false),
+				file("ref/a.jpg", true),
+			}},
+			{Hash: "0cc175b9c0f1b6a831c399e269772661", Algorithm: dedup.MD5, Size: 1024, Files: []dedup.FileInfo{
+				file("other/x|y.txt", false),
+				file("other/z.txt", false),
+			}},
+		},
+	}
+	group := result.DuplicateGroups[0]
+	plan := &dedup.Plan{Groups: []dedup.PlanGroup{{
+		Hash:   group.Hash,
+		Size:   group.Size,
+		Keep:   group.Files[3],
+		Remove: group.Files[:3],
+	}}}
+	report := &dedup.ApplyReport{
+		Removed:   []dedup.FileInfo{group.Files[0], group.Files[2]},
+		Failed:    []*dedup.ActionError{{File: group.Files[1], Err: errors.New("permission denied: <locked> & busy")}},
+		FreedSize: 2 * 1024,
+	}
+
+	view := newReportView(result, plan, report)
+	view.Created = time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)
+	return view
+}
+
+func TestReportGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		output func(io.Writer, *reportView) error
+	}{
+		{"report.csv", outputCSV},
+		{"report.html", outputHTML},
+		{"report.md", outputMarkdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.output(&buf, testReportView()); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s 与 %s 不一致，检查后可用 -update 更新:\n%s", tt.name, golden, buf.String())
+			}
+		})
+	}
+}
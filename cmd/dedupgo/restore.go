@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runRestore 执行 dedupgo restore [PATH...]：根据操作日志恢复被移除的文件
+func runRestore(args []string) {
+	fs := newCommandFlags("restore", "[选项] [PATH...]",
+		"根据操作日志恢复被移到回收站或删除的文件。指定 PATH 时只恢复这些文件或目录下的文件。\n"+
+			"优先从回收站移回原位置，找不到时从同组保留的文件复制一份（复制前校验内容）。\n"+
+			"原位置已经存在文件时跳过。有文件恢复失败时退出码为 2。")
+	since := fs.Duration("since", 0, "只恢复这段时间内移除的文件 (例如: 2h)，为 0 时不限")
+	dryRun := fs.Bool("dry-run", false, "只列出将要恢复的文件")
+	journalPath := fs.String("journal", "", "操作日志路径，默认为 ~/.cache/dedupgo/journal.jsonl")
+	fs.short("n", "dry-run")
+	fs.Parse(args)
+
+	if *journalPath == "" {
+		path, err := config.JournalPath()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(exitError)
+		}
+		*journalPath = path
+	}
+	entries, err := dedup.ReadJournal(*journalPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Println("操作日志为空，没有可以恢复的文件")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "读取操作日志失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	var targets []string
+	for _, arg := range fs.Args() {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(exitError)
+		}
+		targets = append(targets, abs)
+	}
+	entries = selectJournalEntries(entries, targets, *since)
+	if len(entries) == 0 {
+		fmt.Println("没有符合条件的记录")
+		return
+	}
+
+	restored, skipped, failed := 0, 0, 0
+	for _, entry := range entries {
+		if *dryRun {
+			if _, err := os.Lstat(entry.Path); err == nil {
+				fmt.Printf("  [已存在] %s\n", entry.Path)
+				skipped++
+			} else {
+				fmt.Printf("  [将恢复] %s\n", entry.Path)
+				restored++
+			}
+			continue
+		}
+
+		method, err := dedup.Restore(entry)
+		switch {
+		case errors.Is(err, dedup.ErrRestoreExists):
+			fmt.Printf("  [已存在] %s\n", entry.Path)
+			skipped++
+		case err != nil:
+			fmt.Printf("  [失败] %s (%v)\n", entry.Path, err)
+			failed++
+		case method == dedup.RestoredFromTrash:
+			fmt.Printf("  [已恢复] %s (从回收站)\n", entry.Path)
+			restored++
+		default:
+			fmt.Printf("  [已恢复] %s (复制自 %s)\n", entry.Path, entry.Kept)
+			restored++
+		}
+	}
+
+	verb := "已恢复"
+	if *dryRun {
+		verb = "预览: 将恢复"
+	}
+	fmt.Printf("%s %d 个文件，跳过 %d 个已存在的文件，失败 %d 个\n", verb, restored, skipped, failed)
+	if failed > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// selectJournalEntries 选出要恢复的记录：位于 targets 之中（为空时不限）、在 since 时间内，
+// 同一路径有多条记录时只保留最后一条，按日志顺序返回
+func selectJournalEntries(entries []dedup.JournalEntry, targets []string, since time.Duration) []dedup.JournalEntry {
+	latest := make(map[string]int)
+	for i, entry := range entries {
+		if since > 0 && time.Since(entry.Time) > since {
+			continue
+		}
+		if len(targets) > 0 && !underAny(entry.Path, targets) {
+			continue
+		}
+		latest[entry.Path] = i
+	}
+
+	var selected []dedup.JournalEntry
+	for i, entry := range entries {
+		if j, ok := latest[entry.Path]; ok && i == j {
+			selected = append(selected, entry)
+		}
+	}
+	return selected
+}
+
+// underAny 判断 path 是否为 targets 中的某个路径或位于其下
+func underAny(path string, targets []string) bool {
+	for _, target := range targets {
+		rel, err := filepath.Rel(target, path)
+		if err == nil && rel != ".." && !filepath.IsAbs(rel) && !startsWithParent(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func startsWithParent(rel string) bool {
+	return len(rel) >= 3 && rel[:3] == ".."+string(filepath.Separator)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runScan 执行 dedupgo scan DIR...：扫描目录查找重复文件，按设置预览或执行删除
+func runScan(args []string) {
+	flags := newCommandFlags("scan", "[选项] DIR...", "扫描 DIR 查找重复文件。默认只预览，使用 --force 时按保留规则移除多余的副本。")
+	configFile := flags.String("config", "", "配置文件路径")
+	profile := flags.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	addSettingFlags(flags)
+	outputFile := flags.String("output-file", "", "将报告写入文件而不是标准输出")
+	keepRule := flags.String("keep", "first", "每组保留哪个文件 (first/oldest/newest/shortest)")
+	stateName := flags.String("state", "", "增量扫描状态名称，只重新计算新增或变化的文件，并报告自上次扫描以来新出现的重复")
+	interactive := flags.Bool("interactive", false, "扫描后在终端中逐组选择保留哪个文件，确认后移到回收站 (--trash=false 时直接删除)")
+	flags.short("c", "config")
+	flags.short("p", "profile")
+	flags.short("k", "keep")
+	flags.short("i", "interactive")
+	flags.Parse(args)
+
+	// 加载配置，显式指定的命令行参数优先于环境变量和配置文件
+	settings, err := loadSettings(flags, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+
+	// 获取扫描目录
+	roots := flags.Args()
+	if len(roots) == 0 && len(cfg.ReferenceDirs) == 0 {
+		fmt.Fprintln(os.Stderr, "错误: 请指定至少一个扫描目录")
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	format := strings.ToLower(cfg.OutputFormat)
+	if !validOutputFormat(format) {
+		fmt.Fprintf(os.Stderr, "错误: 未知的输出格式: %s\n", cfg.OutputFormat)
+		os.Exit(exitError)
+	}
+	// 交互审阅代替报告，不能同时指定报告的格式或输出位置
+	if *interactive && (*outputFile != "" || settings.Origin("output_format").Source == config.SourceFlag) {
+		fmt.Fprintln(os.Stderr, "错误: --interactive 不能与 --output 或 --output-file 同时使用")
+		os.Exit(exitError)
+	}
+	if *interactive && format == "ndjson" {
+		fmt.Fprintln(os.Stderr, "错误: --interactive 不支持 ndjson 输出格式")
+		os.Exit(exitError)
+	}
+
+	// 创建扫描器
+	scanner, err := newScanner(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	ctx := context.Background()
+
+	out, closeOutput, err := openOutput(*outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// NDJSON 模式边扫描边输出，每确认一组重复文件就写出一行
+	if format == "ndjson" {
+		if *stateName != "" {
+			fmt.Fprintln(os.Stderr, "错误: --state 不支持 ndjson 输出格式")
+			os.Exit(exitError)
+		}
+		groups := outputNDJSON(ctx, out, scanner, roots)
+		closeOutput()
+		exitWithGroups(groups)
+	}
+
+	// 执行扫描，指定了状态名称时进行增量扫描
+	var result *dedup.Result
+	var changes []dedup.GroupChange
+	if *stateName != "" {
+		result, changes, err = scanIncremental(ctx, scanner, *stateName, roots)
+	} else {
+		result, err = scanner.Scan(ctx, roots...)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	rule, err := dedup.KeepRuleByName(*keepRule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	// 交互审阅代替报告，由用户逐组选择并确认后执行
+	if *interactive {
+		runInteractive(ctx, result, rule, cfg.UseTrash)
+	}
+	plan := dedup.NewPlan(result, rule)
+
+	// 非预览模式下执行删除
+	var report *dedup.ApplyReport
+	if !cfg.DryRun {
+		report, err = applyPlan(ctx, plan, cfg.UseTrash, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "删除失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	// 输出结果
+	if err := outputReport(out, format, newReportView(result, plan, report)); err != nil {
+		fmt.Fprintf(os.Stderr, "输出报告失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	if *stateName != "" && isTextFormat(format) {
+		outputChangesText(out, result, changes)
+	}
+	closeOutput()
+	exitWithGroups(len(result.DuplicateGroups))
+}
+
+// exitWithGroups 按发现的重复组数退出：没有重复时退出码为 0，否则为 1
+func exitWithGroups(groups int) {
+	if groups > 0 {
+		os.Exit(exitFound)
+	}
+	os.Exit(exitOK)
+}
+
+// scanIncremental 读取命名状态进行增量扫描，完成后保存新状态。
+// 返回的 changes 为自上次扫描以来新出现的重复，首次扫描时为 nil。
+func scanIncremental(ctx context.Context, scanner *dedup.Scanner, name string, roots []string) (*dedup.Result, []dedup.GroupChange, error) {
+	path, err := config.StatePath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	prev, err := dedup.LoadState(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	result, next, err := scanner.ScanIncremental(ctx, prev, roots...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := next.Save(path); err != nil {
+		return nil, nil, fmt.Errorf("保存状态失败: %v", err)
+	}
+	if prev == nil {
+		return result, nil, nil
+	}
+	return result, prev.Changes(result), nil
+}
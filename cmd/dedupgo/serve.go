@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/internal/server"
+)
+
+// runServe 执行 dedupgo serve：启动本地 HTTP JSON API 服务
+func runServe(args []string) {
+	fs := newCommandFlags("serve", "[选项]",
+		"启动浏览器界面和 HTTP JSON API 服务，接口路径以 "+server.APIPrefix+" 开头")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	addr := fs.String("addr", "127.0.0.1:8080", "监听地址")
+	token := fs.String("token", os.Getenv("DEDUPGO_TOKEN"), "访问令牌，设置后请求必须携带 Authorization: Bearer <令牌>，监听非本机地址时必须设置 (默认读取环境变量 DEDUPGO_TOKEN)")
+	noUI := fs.Bool("no-ui", false, "只提供 API，不提供浏览器界面")
+	fs.Parse(args)
+
+	if err := server.CheckListenAddr(*addr, *token); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	cfg, err := config.LoadConfig(*configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	api := server.New(cfg, *token)
+	defer api.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle(server.APIPrefix, api)
+	if !*noUI {
+		mux.Handle("/", server.WebHandler())
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if *noUI {
+		fmt.Printf("API 服务已启动: http://%s%s\n", *addr, server.APIPrefix)
+	} else {
+		fmt.Printf("服务已启动，在浏览器中打开: http://%s/\n", *addr)
+	}
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "服务启动失败: %v\n", err)
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// settingFlags 命令行参数到配置项的映射。只有显式指定的参数才会覆盖配置文件和环境变量，
+// --force 对应 dry_run 取反
+var settingFlags = map[string]string{
+	"hash":              "hash_algorithm",
+	"min-size":          "min_size",
+	"force":             "dry_run",
+	"output":            "output_format",
+	"trash":             "use_trash",
+	"bwlimit":           "max_bytes_per_sec",
+	"files-per-sec":     "max_files_per_sec",
+	"low-io":            "low_io_priority",
+	"archives":          "scan_archives",
+	"similar":           "similar_images",
+	"similar-threshold": "similar_threshold",
+	"dirs":              "duplicate_dirs",
+	"reference":         "reference_dirs",
+}
+
+// addSettingFlags 定义 scan 的扫描设置参数，config show 也使用它们预览参数的效果。
+// 参数的值在 loadSettings 中读取。
+func addSettingFlags(fs *commandFlags) {
+	fs.String("hash", "md5", "哈希算法 (md5/sha256)")
+	fs.String("min-size", "0", "最小文件大小 (例如: 10MB)")
+	fs.Bool("force", false, "强制删除重复文件")
+	fs.String("output", "txt", "输出格式 (txt/json/ndjson/csv/html/md/fdupes)")
+	fs.Bool("trash", true, "使用回收站")
+	fs.String("bwlimit", "0", "读取带宽上限，每秒字节数 (例如: 20MB)")
+	fs.Int("files-per-sec", 0, "每秒最多处理的文件数")
+	fs.Bool("low-io", false, "降低进程的 I/O 优先级 (仅 Linux)")
+	fs.Bool("archives", false, "查找 zip/tar 归档内的重复文件 (只报告，不修改归档)")
+	fs.String("similar", "", "查找相似图片使用的感知哈希算法 (ahash/dhash/phash)")
+	fs.Int("similar-threshold", dedup.DefaultImageThreshold, "相似图片的汉明距离阈值 (0-64)，0 只匹配感知哈希完全相同的图片")
+	fs.Bool("dirs", false, "查找内容相同或被其他目录包含的目录")
+	fs.Var(new(stringList), "reference", "参考目录，其中的文件永远保留，只删除其他位置的副本 (可重复指定)")
+	fs.short("a", "hash")
+	fs.short("s", "min-size")
+	fs.short("f", "force")
+	fs.short("o", "output")
+	fs.short("r", "reference")
+}
+
+// loadSettings 加载默认值、配置文件、配置方案和环境变量，再用 fs 中显式指定的扫描设置参数覆盖对应的配置项，
+// 最后检查合并后的每个配置项
+func loadSettings(fs *commandFlags, configFile, profile string) (*config.Settings, error) {
+	settings, err := config.Load(configFile, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	longs := make(map[string]string)
+	for long, short := range fs.shorts {
+		longs[short] = long
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := fl.Name
+		if long, ok := longs[name]; ok {
+			name = long
+		}
+		key, ok := settingFlags[name]
+		if !ok {
+			return
+		}
+
+		origin := config.Origin{Source: config.SourceFlag, Detail: "--" + name}
+		switch value := fl.Value.(type) {
+		case *stringList:
+			err = settings.SetList(key, *value, origin)
+		default:
+			v := fl.Value.String()
+			if name == "force" {
+				force, _ := strconv.ParseBool(v)
+				v = strconv.FormatBool(!force)
+			}
+			err = settings.Set(key, v, origin)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// addExcludeFlag 定义 --exclude 参数。diff、manifest 和 verify 需要比较目录中的全部文件，
+// 不使用配置中的 exclude_patterns（默认值会跳过 *.tmp、.git 等），只排除命令行中显式指定的模式
+func addExcludeFlag(fs *commandFlags) *stringList {
+	exclude := new(stringList)
+	fs.Var(exclude, "exclude", "排除匹配该模式的文件或目录 (filepath.Match 语法，可重复指定)，默认不排除任何文件")
+	return exclude
+}
+
+// explicitExcludes 检查 --exclude 指定的模式，返回扫描时使用的排除模式
+func explicitExcludes(exclude *stringList) ([]string, error) {
+	for _, pattern := range *exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("--exclude: 无效的模式 %q", pattern)
+		}
+	}
+	return append([]string(nil), *exclude...), nil
+}
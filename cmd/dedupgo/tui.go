@@ -0,0 +1,692 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"golang.org/x/text/width"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// runTUI 执行 dedupgo tui RESULT | DIR...：在全屏终端界面中浏览和处理扫描结果
+func runTUI(args []string) {
+	fs := newCommandFlags("tui", "[选项] RESULT | DIR...",
+		"在全屏终端界面中浏览重复文件，按可释放空间排序，可以搜索、标记、预览文本文件并执行删除。\n"+
+			"参数是 scan --output json 保存的扫描结果文件时直接读取，否则扫描这些目录。")
+	configFile := fs.String("config", "", "配置文件路径")
+	profile := fs.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	fs.String("hash", "md5", "扫描目录时使用的哈希算法 (md5/sha256)")
+	fs.String("min-size", "0", "扫描目录时的最小文件大小 (例如: 10MB)")
+	fs.Bool("trash", true, "使用回收站，为 false 时直接删除")
+	fs.Var(new(stringList), "reference", "参考目录，其中的文件永远保留 (可重复指定)")
+	keepRule := fs.String("keep", "first", "按规则标记时每组保留哪个文件 (first/oldest/newest/shortest)")
+	fs.short("c", "config")
+	fs.short("p", "profile")
+	fs.short("a", "hash")
+	fs.short("s", "min-size")
+	fs.short("r", "reference")
+	fs.short("k", "keep")
+	fs.Parse(args)
+
+	settings, err := loadSettings(fs, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+	rule, err := dedup.KeepRuleByName(*keepRule)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintln(os.Stderr, "错误: tui 需要在终端中运行")
+		os.Exit(exitError)
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 && len(cfg.ReferenceDirs) == 0 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+	ctx := context.Background()
+
+	var result *dedup.Result
+	if info, err := os.Stat(fs.Arg(0)); len(roots) == 1 && err == nil && info.Mode().IsRegular() {
+		result, err = readResultFile(roots[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取扫描结果失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	} else {
+		scanner, err := newScanner(cfg, dedup.WithProgress(func(p dedup.Progress) {
+			fmt.Fprintf(os.Stderr, "\r\x1b[K正在扫描: 发现 %d 个文件，已校验 %d/%d", p.FilesFound, p.FilesHashed, p.FilesToHash)
+		}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(exitError)
+		}
+		result, err = scanner.Scan(ctx, roots...)
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	t := newTUI(result, rule, cfg.UseTrash)
+	if err := t.run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+	if t.failed > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// tuiRow 列表中的一行：组标题（file 为 -1）或组内的一个文件
+type tuiRow struct {
+	group int
+	file  int
+}
+
+// tui 全屏终端界面的状态
+type tui struct {
+	groups   []*reviewGroup
+	rule     dedup.KeepRule
+	useTrash bool
+	// marked 标记为删除的文件路径
+	marked map[string]bool
+
+	rows    []tuiRow
+	cursor  int
+	offset  int
+	filter  string
+	preview bool
+
+	// mode 为 "" 时浏览，"search" 时输入搜索词，"confirm" 时等待确认执行
+	mode   string
+	input  string
+	status string
+
+	width, height int
+	in            *bufio.Reader
+	out           io.Writer
+	// failed 执行失败的文件总数，决定退出码
+	failed int
+}
+
+func newTUI(result *dedup.Result, rule dedup.KeepRule, useTrash bool) *tui {
+	groups := newReviewGroups(result, rule)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].group.Reclaimable() > groups[j].group.Reclaimable()
+	})
+	t := &tui{groups: groups, rule: rule, useTrash: useTrash, marked: make(map[string]bool), preview: true}
+	t.rebuild()
+	return t
+}
+
+// run 进入全屏界面，直到按 q 退出
+func (t *tui) run(ctx context.Context) error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// 使用备用屏幕并隐藏光标，退出时恢复
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	t.in = bufio.NewReader(os.Stdin)
+	t.out = os.Stdout
+	for {
+		// 每次重绘时重新读取窗口大小，调整窗口后按任意键即可适应
+		t.width, t.height, err = term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			t.width, t.height = 80, 24
+		}
+		t.draw()
+
+		key, err := t.readKey()
+		if err != nil {
+			return err
+		}
+		if t.handleKey(ctx, key) {
+			return nil
+		}
+	}
+}
+
+// readKey 读取一个按键，方向键等转义序列转换为名称（up、down、pgup、pgdn、home、end、esc）
+func (t *tui) readKey() (string, error) {
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case keyCtrlC:
+		return "ctrl-c", nil
+	case '\r', '\n':
+		return "enter", nil
+	case 127, 8:
+		return "backspace", nil
+	case keyEscape:
+		if t.in.Buffered() == 0 {
+			return "esc", nil
+		}
+		seq := []byte{}
+		for t.in.Buffered() > 0 {
+			c, _ := t.in.ReadByte()
+			seq = append(seq, c)
+			if len(seq) > 1 && (c >= 'A' && c <= 'Z' || c == '~') {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A", "OA":
+			return "up", nil
+		case "[B", "OB":
+			return "down", nil
+		case "[5~":
+			return "pgup", nil
+		case "[6~":
+			return "pgdn", nil
+		case "[H", "[1~", "OH":
+			return "home", nil
+		case "[F", "[4~", "OF":
+			return "end", nil
+		}
+		return "", nil
+	}
+
+	// 多字节的 UTF-8 字符（搜索中文路径时）
+	if b >= utf8.RuneSelf {
+		buf := []byte{b}
+		for !utf8.FullRune(buf) && t.in.Buffered() > 0 {
+			c, _ := t.in.ReadByte()
+			buf = append(buf, c)
+		}
+		return string(buf), nil
+	}
+	return string(b), nil
+}
+
+// handleKey 处理按键，返回 true 表示退出
+func (t *tui) handleKey(ctx context.Context, key string) bool {
+	switch t.mode {
+	case "search":
+		switch key {
+		case "enter":
+			t.filter, t.mode = t.input, ""
+			t.cursor, t.offset = 0, 0
+			t.rebuild()
+		case "esc", "ctrl-c":
+			t.mode = ""
+		case "backspace":
+			if r := []rune(t.input); len(r) > 0 {
+				t.input = string(r[:len(r)-1])
+			}
+		default:
+			if r, _ := utf8.DecodeRuneInString(key); len(key) > 0 && unicode.IsPrint(r) && utf8.RuneCountInString(key) == 1 {
+				t.input += key
+			}
+		}
+		return false
+	case "confirm":
+		t.mode = ""
+		if key == "y" || key == "Y" {
+			t.apply(ctx)
+		} else {
+			t.status = "已取消"
+		}
+		return false
+	}
+
+	t.status = ""
+	page := t.listHeight()
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "up", "k":
+		t.cursor--
+	case "down", "j":
+		t.cursor++
+	case "pgup":
+		t.cursor -= page
+	case "pgdn":
+		t.cursor += page
+	case "home", "g":
+		t.cursor = 0
+	case "end", "G":
+		t.cursor = len(t.rows) - 1
+	case "n":
+		t.nextGroup(1)
+	case "N":
+		t.nextGroup(-1)
+	case " ":
+		t.toggle()
+	case "a":
+		for _, row := range t.rows {
+			if row.file < 0 {
+				t.markGroup(t.groups[row.group])
+			}
+		}
+		t.status = "已按规则标记列表中的所有组"
+	case "u":
+		t.marked = make(map[string]bool)
+		t.status = "已清除所有标记"
+	case "/":
+		t.mode, t.input = "search", t.filter
+	case "v":
+		t.preview = !t.preview
+	case "d", "x":
+		files, size := t.markedTotal()
+		if files == 0 {
+			t.status = "没有标记的文件，按空格标记"
+			break
+		}
+		t.mode = "confirm"
+		verb := "移到回收站"
+		if !t.useTrash {
+			verb = "永久删除"
+		}
+		t.status = fmt.Sprintf("将%s %d 个文件，释放 %s，确认? (y/N)", verb, files, utils.FormatSize(size))
+	}
+	t.clampCursor()
+	return false
+}
+
+// rebuild 按过滤条件重新生成列表的行
+func (t *tui) rebuild() {
+	t.rows = buildRows(t.groups, t.filter)
+	t.clampCursor()
+}
+
+// buildRows 生成列表的行：每组一个标题行加上组内的每个文件。
+// filter 不为空时只显示有文件路径包含 filter（不区分大小写）的组，包含匹配文件的组整组显示。
+func buildRows(groups []*reviewGroup, filter string) []tuiRow {
+	filter = strings.ToLower(filter)
+	var rows []tuiRow
+	for i, g := range groups {
+		if filter != "" && !groupMatches(g, filter) {
+			continue
+		}
+		rows = append(rows, tuiRow{group: i, file: -1})
+		for j := range g.group.Files {
+			rows = append(rows, tuiRow{group: i, file: j})
+		}
+	}
+	return rows
+}
+
+func groupMatches(g *reviewGroup, filter string) bool {
+	for _, file := range g.group.Files {
+		if strings.Contains(strings.ToLower(file.Path), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tui) clampCursor() {
+	if t.cursor >= len(t.rows) {
+		t.cursor = len(t.rows) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// nextGroup 将光标移到下一个（dir 为 -1 时上一个）组的标题行
+func (t *tui) nextGroup(dir int) {
+	t.cursor = nextGroupRow(t.rows, t.cursor, dir)
+}
+
+// nextGroupRow 返回 cursor 之后（dir 为 -1 时之前）第一个组标题行的下标，没有时返回 cursor
+func nextGroupRow(rows []tuiRow, cursor, dir int) int {
+	for i := cursor + dir; i >= 0 && i < len(rows); i += dir {
+		if rows[i].file < 0 {
+			return i
+		}
+	}
+	return cursor
+}
+
+// markable 判断文件能否标记：归档内和参考目录中的文件永远保留
+func markable(file dedup.FileInfo) bool {
+	return !file.InArchive() && !file.Reference
+}
+
+// toggle 在文件行上切换标记；在组标题行上整组按规则标记，已有标记时清除整组的标记
+func (t *tui) toggle() {
+	if len(t.rows) == 0 {
+		return
+	}
+	row := t.rows[t.cursor]
+	g := t.groups[row.group]
+	if row.file < 0 {
+		if t.groupMarked(g) > 0 {
+			for _, file := range g.files {
+				delete(t.marked, file.Path)
+			}
+		} else {
+			t.markGroup(g)
+		}
+		return
+	}
+
+	file := g.group.Files[row.file]
+	switch {
+	case !markable(file):
+		t.status = "归档内和参考目录中的文件不能删除"
+	case t.marked[file.Path]:
+		delete(t.marked, file.Path)
+	case len(g.refs) == 0 && t.groupMarked(g) == len(g.files)-1:
+		t.status = "每组至少保留一个文件"
+	default:
+		t.marked[file.Path] = true
+	}
+	t.cursor++
+}
+
+// markGroup 按保留规则标记组内除保留文件之外的所有文件
+func (t *tui) markGroup(g *reviewGroup) {
+	for i, file := range g.files {
+		if i == g.suggest {
+			delete(t.marked, file.Path)
+		} else {
+			t.marked[file.Path] = true
+		}
+	}
+}
+
+func (t *tui) groupMarked(g *reviewGroup) int {
+	n := 0
+	for _, file := range g.files {
+		if t.marked[file.Path] {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *tui) markedTotal() (files int, size int64) {
+	for _, g := range t.groups {
+		n := t.groupMarked(g)
+		files += n
+		size += int64(n) * g.group.Size
+	}
+	return files, size
+}
+
+// apply 移除所有标记的文件，记录到操作日志，并从列表中去掉已移除的文件
+func (t *tui) apply(ctx context.Context) {
+	plan := &dedup.Plan{Groups: []dedup.PlanGroup{}}
+	for _, g := range t.groups {
+		pg := dedup.PlanGroup{Hash: g.group.Hash, Size: g.group.Size}
+		keep := -1
+		for i, file := range g.files {
+			if t.marked[file.Path] {
+				pg.Remove = append(pg.Remove, file)
+			} else if keep < 0 {
+				keep = i
+			}
+		}
+		if len(pg.Remove) == 0 {
+			continue
+		}
+		if len(g.refs) > 0 {
+			pg.Keep = g.refs[0]
+		} else {
+			pg.Keep = g.files[keep]
+		}
+		plan.Groups = append(plan.Groups, pg)
+	}
+
+	report, err := applyPlan(ctx, plan, t.useTrash, false)
+	if err != nil {
+		t.status = fmt.Sprintf("执行失败: %v", err)
+		return
+	}
+	removed := make(map[string]bool)
+	for _, file := range report.Removed {
+		removed[file.Path] = true
+	}
+	t.failed += len(report.Failed)
+
+	// 去掉已移除的文件，剩下的文件不足以构成重复的组不再显示
+	var groups []*reviewGroup
+	for _, g := range t.groups {
+		var files []dedup.FileInfo
+		for _, file := range g.group.Files {
+			if !removed[file.Path] {
+				files = append(files, file)
+			}
+		}
+		g.group.Files = files
+		g.files, g.refs = g.group.Removable(), g.group.References()
+		if len(g.files) == 0 || len(g.refs) == 0 && len(g.files) < 2 {
+			continue
+		}
+		g.suggest = -1
+		if len(g.refs) == 0 {
+			g.suggest = t.rule(g.files)
+		}
+		groups = append(groups, g)
+	}
+	t.groups = groups
+	t.marked = make(map[string]bool)
+	t.rebuild()
+
+	t.status = fmt.Sprintf("已移除 %d 个文件，释放 %s", len(report.Removed), utils.FormatSize(report.FreedSize))
+	if len(report.Failed) > 0 {
+		f := report.Failed[0]
+		t.status += fmt.Sprintf("，失败 %d 个 (%s: %v)", len(report.Failed), f.File.Path, f.Err)
+	}
+}
+
+// previewLines 文本预览占用的行数
+const previewLines = 8
+
+func (t *tui) listHeight() int {
+	h := t.height - 4
+	if t.preview {
+		h -= previewLines + 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+// draw 重绘整个屏幕：标题、列表、预览和状态栏
+func (t *tui) draw() {
+	var buf bytes.Buffer
+	line := func(s string) {
+		buf.WriteString(s)
+		buf.WriteString("\x1b[K\r\n")
+	}
+	buf.WriteString("\x1b[H")
+
+	var reclaimable int64
+	for _, g := range t.groups {
+		reclaimable += g.group.Reclaimable()
+	}
+	files, size := t.markedTotal()
+	header := fmt.Sprintf(" DedupGo  %d 组重复，可释放 %s  已标记 %d 个文件 (%s)", len(t.groups), utils.FormatSize(reclaimable), files, utils.FormatSize(size))
+	if t.filter != "" {
+		header += fmt.Sprintf("  过滤: %s", t.filter)
+	}
+	line("\x1b[1m" + fitWidth(header, t.width) + "\x1b[0m")
+	line(strings.Repeat("─", t.width))
+
+	height := t.listHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+height {
+		t.offset = t.cursor - height + 1
+	}
+	for i := t.offset; i < t.offset+height; i++ {
+		if i >= len(t.rows) {
+			if i == 0 {
+				line(" 没有重复文件")
+			} else {
+				line("")
+			}
+			continue
+		}
+		text := fitWidth(t.rowText(t.rows[i]), t.width)
+		if i == t.cursor {
+			text = "\x1b[7m" + text + "\x1b[0m"
+		} else if t.rows[i].file < 0 {
+			text = "\x1b[1m" + text + "\x1b[0m"
+		}
+		line(text)
+	}
+
+	if t.preview {
+		line(strings.Repeat("─", t.width))
+		lines := t.previewText()
+		for i := 0; i < previewLines; i++ {
+			if i < len(lines) {
+				line("\x1b[2m" + fitWidth(lines[i], t.width) + "\x1b[0m")
+			} else {
+				line("")
+			}
+		}
+	}
+
+	line(strings.Repeat("─", t.width))
+	footer := t.status
+	switch {
+	case t.mode == "search":
+		footer = "搜索路径: " + t.input + "▏ (回车确认，Esc 取消，留空显示全部)"
+	case footer == "":
+		footer = "↑↓ 移动 · n/N 下一组 · 空格 标记 · a 全部按规则标记 · u 清除 · / 搜索 · v 预览 · d 执行 · q 退出"
+	}
+	buf.WriteString(fitWidth(footer, t.width) + "\x1b[K\x1b[J")
+	t.out.Write(buf.Bytes())
+}
+
+func (t *tui) rowText(row tuiRow) string {
+	g := t.groups[row.group]
+	if row.file < 0 {
+		return fmt.Sprintf("▸ %s × %d 个文件，可释放 %s  %.12s", utils.FormatSize(g.group.Size), len(g.group.Files), utils.FormatSize(g.group.Reclaimable()), g.group.Hash)
+	}
+
+	file := g.group.Files[row.file]
+	mark := "[ ] "
+	switch {
+	case file.InArchive():
+		mark = "归档"
+	case file.Reference:
+		mark = "参考"
+	case t.marked[file.Path]:
+		mark = "[x] "
+	}
+	return fmt.Sprintf("   %s %s  %s", mark, file.ModTime.Local().Format("2006-01-02 15:04"), file.Path)
+}
+
+// previewText 返回光标所在文件开头的几行，二进制文件和归档成员不预览
+func (t *tui) previewText() []string {
+	if len(t.rows) == 0 {
+		return nil
+	}
+	row := t.rows[t.cursor]
+	if row.file < 0 {
+		row.file = 0
+	}
+	file := t.groups[row.group].group.Files[row.file]
+	if file.InArchive() {
+		return []string{"归档内的文件不能预览"}
+	}
+
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return []string{fmt.Sprintf("无法打开: %v", err)}
+	}
+	defer f.Close()
+	head := make([]byte, 4096)
+	n, _ := io.ReadFull(f, head)
+	lines, ok := textPreview(head[:n], n == len(head))
+	if !ok {
+		return []string{fmt.Sprintf("二进制文件 (%s)，不能预览", utils.FormatSize(file.Size))}
+	}
+	return lines
+}
+
+// textPreview 返回文件开头 head 中的前几行，去掉控制字符。head 含有零字节或不是合法的 UTF-8 时
+// 视为二进制文件，返回 false。truncated 表示 head 只是文件的一部分，末尾被截断的字符不算错误。
+func textPreview(head []byte, truncated bool) ([]string, bool) {
+	if truncated {
+		head = trimPartialRune(head)
+	}
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(head) {
+		return nil, false
+	}
+
+	lines := strings.Split(string(head), "\n")
+	if len(lines) > previewLines {
+		lines = lines[:previewLines]
+	}
+	for i, l := range lines {
+		l = strings.ReplaceAll(l, "\t", "    ")
+		lines[i] = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, l)
+	}
+	return lines, true
+}
+
+// trimPartialRune 去掉末尾不完整的 UTF-8 字符。末尾是完整但不合法的字节时保持不变，
+// 以免把 Latin-1 等其他编码的文本误判为 UTF-8。
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// runeWidth 返回字符在终端中占用的列数，中日韩等全角字符占两列
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// fitWidth 截断 s 使其在终端中不超过 w 列
+func fitWidth(s string, w int) string {
+	used := 0
+	for i, r := range s {
+		rw := runeWidth(r)
+		if used+rw > w {
+			return s[:i]
+		}
+		used += rw
+	}
+	return s
+}
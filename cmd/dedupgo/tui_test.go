@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// newTestTUI 两组重复文件：大文件组 big/1..3 排在前面，小文件组 small/a、small/b 在后面
+func newTestTUI() *tui {
+	result := &dedup.Result{DuplicateGroups: []dedup.DuplicateGroup{
+		{Hash: "s", Size: 10, Files: []dedup.FileInfo{{Path: "small/a", Size: 10}, {Path: "small/B", Size: 10}}},
+		{Hash: "b", Size: 100, Files: []dedup.FileInfo{
+			{Path: "big/1", Size: 100}, {Path: "big/2", Size: 100}, {Path: "big/3", Size: 100},
+		}},
+	}}
+	t := newTUI(result, dedup.KeepFirst, false)
+	t.height = 40
+	return t
+}
+
+func TestBuildRows(t *testing.T) {
+	tt := newTestTUI()
+	all := []tuiRow{{0, -1}, {0, 0}, {0, 1}, {0, 2}, {1, -1}, {1, 0}, {1, 1}}
+	if !reflect.DeepEqual(tt.rows, all) {
+		t.Errorf("rows = %v, want %v", tt.rows, all)
+	}
+	if got, want := buildRows(tt.groups, "SMALL/b"), all[4:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("buildRows(SMALL/b) = %v, want %v", got, want)
+	}
+	if got := buildRows(tt.groups, "none"); len(got) != 0 {
+		t.Errorf("buildRows(none) = %v, want no rows", got)
+	}
+}
+
+func TestNextGroupRow(t *testing.T) {
+	rows := newTestTUI().rows
+	tests := []struct {
+		cursor, dir, want int
+	}{
+		{0, 1, 4},
+		{2, 1, 4},
+		{4, 1, 4}, // 已是最后一组
+		{6, -1, 4},
+		{4, -1, 0},
+		{0, -1, 0},
+	}
+	for _, tt := range tests {
+		if got := nextGroupRow(rows, tt.cursor, tt.dir); got != tt.want {
+			t.Errorf("nextGroupRow(%d, %d) = %d, want %d", tt.cursor, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestTUINavigationAndMarks(t *testing.T) {
+	tt := newTestTUI()
+	press := func(keys ...string) {
+		for _, key := range keys {
+			if tt.handleKey(context.Background(), key) {
+				t.Fatalf("key %q quit", key)
+			}
+		}
+	}
+
+	press("G", "down")
+	if tt.cursor != len(tt.rows)-1 {
+		t.Errorf("cursor = %d, want clamped to %d", tt.cursor, len(tt.rows)-1)
+	}
+	press("g", "up")
+	if tt.cursor != 0 {
+		t.Errorf("cursor = %d, want 0", tt.cursor)
+	}
+
+	// 在组标题上按空格按规则标记整组，再按一次清除
+	press(" ")
+	if !tt.marked["big/2"] || !tt.marked["big/3"] || tt.marked["big/1"] {
+		t.Errorf("marked = %v, want big/2 and big/3", tt.marked)
+	}
+	press("g", " ")
+	if len(tt.marked) != 0 {
+		t.Errorf("marked = %v, want none", tt.marked)
+	}
+
+	// 每组至少保留一个文件
+	press("n", "down", " ", " ")
+	if !tt.marked["small/a"] || tt.marked["small/B"] {
+		t.Errorf("marked = %v, want only small/a", tt.marked)
+	}
+	if tt.status == "" {
+		t.Error("marking the last file gave no status")
+	}
+	if files, size := tt.markedTotal(); files != 1 || size != 10 {
+		t.Errorf("markedTotal = %d, %d, want 1, 10", files, size)
+	}
+
+	// 搜索后只显示匹配的组
+	press("/", "b", "i", "g", "enter")
+	if tt.filter != "big" || len(tt.rows) != 4 || tt.cursor != 0 {
+		t.Errorf("filter = %q, rows = %v, cursor = %d", tt.filter, tt.rows, tt.cursor)
+	}
+	if !tt.handleKey(context.Background(), "q") {
+		t.Error("q did not quit")
+	}
+}
+
+func TestTextPreview(t *testing.T) {
+	tests := []struct {
+		name      string
+		head      string
+		truncated bool
+		want      []string
+		ok        bool
+	}{
+		{"text", "a\tb\nc\r\n", false, []string{"a    b", "c", ""}, true},
+		{"control characters", "x\x1b[2Jy", false, []string{"x[2Jy"}, true},
+		{"line limit", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10", false, []string{"1", "2", "3", "4", "5", "6", "7", "8"}, true},
+		{"zero byte", "ab\x00cd", false, nil, false},
+		{"latin-1", "caf\xe9", false, nil, false},
+		{"latin-1 truncated", "caf\xe9s", true, nil, false},
+		{"invalid in middle", "a\xffb", true, nil, false},
+		{"cut UTF-8 at end of buffer", "中文\xe6\x96", true, []string{"中文"}, true},
+		{"cut UTF-8 at end of file", "中文\xe6\x96", false, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := textPreview([]byte(tt.head), tt.truncated)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("textPreview(%q, %v) = %q, %v, want %q, %v", tt.head, tt.truncated, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		w    int
+		want string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"中文路径", 5, "中文"},
+		{"a中", 2, "a"},
+	}
+	for _, tt := range tests {
+		if got := fitWidth(tt.s, tt.w); got != tt.want {
+			t.Errorf("fitWidth(%q, %d) = %q, want %q", tt.s, tt.w, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version 发布版本号，构建时通过 -ldflags "-X main.version=v1.2.3" 设置
+var version = "dev"
+
+// runVersion 执行 dedupgo version：显示版本信息
+func runVersion(args []string) {
+	fs := newCommandFlags("version", "", "显示版本、提交和构建环境信息。")
+	fs.Parse(args)
+
+	v, revision := version, ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+				revision = setting.Value[:12]
+			}
+		}
+	}
+
+	fmt.Printf("dedupgo %s", v)
+	if revision != "" {
+		fmt.Printf(" (%s)", revision)
+	}
+	fmt.Printf(" %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// watchEvent watch 命令 NDJSON 输出中的一条记录
+type watchEvent struct {
+	Type   string                `json:"type"`
+	Time   time.Time             `json:"time"`
+	File   string                `json:"file"`
+	Group  *dedup.DuplicateGroup `json:"group,omitempty"`
+	Action string                `json:"action,omitempty"`
+	Error  string                `json:"error,omitempty"`
+}
+
+// watcher 监视目录并在文件稳定后检查是否与已有文件重复
+type watcher struct {
+	index    *dedup.Index
+	fsw      *fsnotify.Watcher
+	excludes []string
+	debounce time.Duration
+	action   string
+	ndjson   bool
+	encoder  *json.Encoder
+
+	// pending 等待稳定的文件及其最后一次变化的时间
+	pending map[string]time.Time
+	// created 初始扫描之后才出现的文件，trash/delete 只处理这些文件，已有文件被修改时只报告
+	created map[string]bool
+}
+
+// runWatch 执行 dedupgo watch DIR...：初始扫描后持续监视新出现的重复文件
+func runWatch(args []string) {
+	flags := newCommandFlags("watch", "[选项] DIR...",
+		"扫描 DIR 后持续监视其中新建或修改的文件，发现与已有文件重复时立即报告。\n"+
+			"使用 trash/delete 操作时只处理开始监视后新出现的文件，已有文件始终保留，被修改后成为副本时只报告。")
+	configFile := flags.String("config", "", "配置文件路径")
+	profile := flags.String("profile", "", "使用配置文件中的配置方案 (默认读取环境变量 DEDUPGO_PROFILE)")
+	flags.String("hash", "md5", "哈希算法 (md5/sha256)")
+	flags.String("min-size", "0", "最小文件大小 (例如: 10MB)")
+	debounce := flags.Duration("debounce", 2*time.Second, "文件停止变化多久后才计算哈希")
+	action := flags.String("action", "report", "发现新的重复文件时的操作 (report: 只报告; trash: 移到回收站; delete: 永久删除)")
+	outputFormat := flags.String("output", "txt", "输出格式 (txt/ndjson)")
+	flags.Var(new(stringList), "reference", "参考目录，其中的文件永远保留 (可重复指定)")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+	switch *action {
+	case "report", "trash", "delete":
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 未知的操作: %s\n", *action)
+		os.Exit(exitError)
+	}
+	if *debounce <= 0 {
+		fmt.Fprintln(os.Stderr, "错误: --debounce 必须大于 0")
+		os.Exit(exitError)
+	}
+
+	settings, err := loadSettings(flags, *configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg := settings.Config
+	// 监视模式只关心完全相同的文件
+	cfg.ScanArchives = false
+	cfg.SimilarImages = ""
+	cfg.DuplicateDirs = false
+
+	scanner, err := newScanner(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	roots := flags.Args()
+	index, result, err := scanner.NewIndex(ctx, roots...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建文件监视失败: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer fsw.Close()
+
+	w := &watcher{
+		index:    index,
+		fsw:      fsw,
+		excludes: cfg.ExcludePatterns,
+		debounce: *debounce,
+		action:   *action,
+		ndjson:   strings.ToLower(*outputFormat) == "ndjson",
+		encoder:  json.NewEncoder(os.Stdout),
+		pending:  make(map[string]time.Time),
+		created:  make(map[string]bool),
+	}
+	for _, root := range append(roots, cfg.ReferenceDirs...) {
+		if _, err := w.addDir(root); err != nil {
+			fmt.Fprintf(os.Stderr, "监视目录失败: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	if !w.ndjson {
+		fmt.Printf("初始扫描完成: %d 个文件，%d 组已有的重复文件，可节省 %s\n",
+			result.TotalFiles, len(result.DuplicateGroups), utils.FormatSize(result.SavedSize))
+		fmt.Println("正在监视新的重复文件，按 Ctrl+C 退出...")
+	}
+	w.run(ctx)
+}
+
+// addDir 递归监视目录，返回其中已经存在的文件，新建的目录中可能在开始监视前就已有文件
+func (w *watcher) addDir(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && w.excluded(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return w.fsw.Add(path)
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (w *watcher) excluded(path string) bool {
+	for _, pattern := range w.excludes {
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// run 处理文件事件，直到 ctx 被取消
+func (w *watcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.debounce / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "监视出错: %v\n", err)
+
+		case now := <-ticker.C:
+			// 文件在 debounce 时间内没有再变化才认为已经写完
+			for path, changed := range w.pending {
+				if now.Sub(changed) >= w.debounce {
+					delete(w.pending, path)
+					w.check(ctx, path)
+				}
+			}
+		}
+	}
+}
+
+func (w *watcher) handleEvent(event fsnotify.Event) {
+	path := event.Name
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Lstat(path)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if w.excluded(path) {
+				return
+			}
+			files, err := w.addDir(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "监视目录失败: %v\n", err)
+			}
+			for _, file := range files {
+				w.added(file)
+			}
+			return
+		}
+		w.added(path)
+
+	case event.Has(fsnotify.Write):
+		w.pending[path] = time.Now()
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// 重命名后的新路径会收到单独的 Create 事件；path 为目录时清除其下所有文件的记录
+		prefix := path + string(filepath.Separator)
+		for file := range w.pending {
+			if file == path || strings.HasPrefix(file, prefix) {
+				delete(w.pending, file)
+			}
+		}
+		for file := range w.created {
+			if file == path || strings.HasPrefix(file, prefix) {
+				delete(w.created, file)
+			}
+		}
+		w.index.Remove(path)
+	}
+}
+
+// added 记录新出现的文件。覆盖已有文件（例如编辑器保存时重命名到原路径）也会产生 Create 事件，
+// 这种情况下路径仍在索引中，不算新文件。
+func (w *watcher) added(path string) {
+	if !w.index.Has(path) {
+		w.created[path] = true
+	}
+	w.pending[path] = time.Now()
+}
+
+// check 将稳定下来的文件加入索引，发现重复时报告并按设置处理
+func (w *watcher) check(ctx context.Context, path string) {
+	group, err := w.index.Add(path)
+	if err != nil {
+		if !errors.Is(err, dedup.ErrNotIndexed) && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "检查文件失败: %s: %v\n", path, err)
+		}
+		return
+	}
+	if group == nil {
+		return
+	}
+
+	event := watchEvent{Type: "duplicate", Time: time.Now(), File: path, Group: group}
+	if w.action != "report" && w.created[path] {
+		event.Action, err = w.act(ctx, group, path)
+		if err != nil {
+			event.Error = err.Error()
+		}
+	}
+
+	if w.ndjson {
+		if err := w.encoder.Encode(event); err != nil {
+			fmt.Fprintf(os.Stderr, "JSON输出失败: %v\n", err)
+		}
+		return
+	}
+
+	fmt.Printf("[%s] 新的重复文件: %s (%s)\n", event.Time.Format("15:04:05"), path, utils.FormatSize(group.Size))
+	for _, file := range group.Files {
+		if file.Path != path {
+			fmt.Printf("  与已有文件相同: %s\n", file.Path)
+		}
+	}
+	switch {
+	case event.Error != "":
+		fmt.Printf("  处理失败: %s\n", event.Error)
+	case event.Action == "trashed":
+		fmt.Println("  已移到回收站")
+	case event.Action == "deleted":
+		fmt.Println("  已删除")
+	case event.Action == "kept":
+		fmt.Println("  位于参考目录中，已保留")
+	case w.action != "report":
+		fmt.Println("  开始监视前已存在的文件，只报告")
+	}
+}
+
+// act 移除新出现的重复文件，保留已有的文件（优先保留参考目录中的文件）
+func (w *watcher) act(ctx context.Context, group *dedup.DuplicateGroup, path string) (string, error) {
+	var added, keep *dedup.FileInfo
+	for i := range group.Files {
+		file := &group.Files[i]
+		switch {
+		case file.Path == path:
+			added = file
+		case keep == nil || (file.Reference && !keep.Reference):
+			keep = file
+		}
+	}
+	if added.Reference {
+		return "kept", nil
+	}
+
+	plan := &dedup.Plan{Groups: []dedup.PlanGroup{{
+		Hash:   group.Hash,
+		Size:   group.Size,
+		Keep:   *keep,
+		Remove: []dedup.FileInfo{*added},
+	}}}
+	done := "deleted"
+	if w.action == "trash" {
+		done = "trashed"
+	}
+
+	report, err := applyPlan(ctx, plan, w.action == "trash", false)
+	if err != nil {
+		return "", err
+	}
+	if len(report.Failed) > 0 {
+		return "", report.Failed[0]
+	}
+	w.index.Remove(path)
+	delete(w.created, path)
+	return done, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// newTestWatcher 索引 dir 并返回使用 action 处理副本的 watcher，事件以 NDJSON 写入返回的缓冲区
+func newTestWatcher(t *testing.T, dir, action string) (*watcher, *bytes.Buffer) {
+	t.Helper()
+	// 操作日志写入临时目录
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	scanner, err := dedup.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, _, err := scanner.NewIndex(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	return &watcher{
+		index:   index,
+		action:  action,
+		ndjson:  true,
+		encoder: json.NewEncoder(&out),
+		pending: make(map[string]time.Time),
+		created: make(map[string]bool),
+	}, &out
+}
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decodeEvents 读出 watcher 输出的所有事件
+func decodeEvents(t *testing.T, out *bytes.Buffer) []watchEvent {
+	t.Helper()
+	var events []watchEvent
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var event watchEvent
+		if err := dec.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestWatchRemovesOnlyNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeTestFile(t, a, "same")
+	writeTestFile(t, b, "diff")
+	w, out := newTestWatcher(t, dir, "delete")
+	ctx := context.Background()
+
+	// 已有文件被修改成与其他文件相同：只重新索引并报告
+	writeTestFile(t, b, "same")
+	w.handleEvent(fsnotify.Event{Name: b, Op: fsnotify.Write})
+	if _, ok := w.pending[b]; !ok || w.created[b] {
+		t.Fatalf("Write(b): pending %v, created %v", w.pending, w.created)
+	}
+	w.check(ctx, b)
+
+	// 覆盖已有文件产生的 Create 事件同样不算新文件
+	w.handleEvent(fsnotify.Event{Name: a, Op: fsnotify.Create})
+	if w.created[a] {
+		t.Error("覆盖已有文件被当作新文件")
+	}
+	w.check(ctx, a)
+
+	// 开始监视后新建的文件成为副本时被删除
+	c := filepath.Join(dir, "c.txt")
+	writeTestFile(t, c, "same")
+	w.handleEvent(fsnotify.Event{Name: c, Op: fsnotify.Create})
+	if !w.created[c] {
+		t.Fatal("新建的文件没有记录为新文件")
+	}
+	w.handleEvent(fsnotify.Event{Name: c, Op: fsnotify.Write})
+	w.check(ctx, c)
+
+	for _, path := range []string{a, b} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("已有文件 %s 被移除: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(c); !os.IsNotExist(err) {
+		t.Errorf("新文件 c 没有被删除: %v", err)
+	}
+	if w.created[c] || w.index.Has(c) {
+		t.Error("删除后 c 仍记录在 created 或索引中")
+	}
+
+	events := decodeEvents(t, out)
+	want := []struct{ file, action string }{{b, ""}, {a, ""}, {c, "deleted"}}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %d", events, len(want))
+	}
+	for i, w := range want {
+		if events[i].File != w.file || events[i].Action != w.action || events[i].Error != "" {
+			t.Errorf("event %d = %s %q %q, want %s %q", i, events[i].File, events[i].Action, events[i].Error, w.file, w.action)
+		}
+	}
+}
+
+func TestWatchRemoveEvent(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	writeTestFile(t, a, "same")
+	w, out := newTestWatcher(t, dir, "report")
+
+	sub := filepath.Join(dir, "sub")
+	b := filepath.Join(sub, "b.txt")
+	w.created[b] = true
+	w.pending[b] = time.Now()
+
+	// 删除目录时清除其下文件的记录，前缀相同的兄弟路径不受影响
+	sibling := filepath.Join(dir, "subway.txt")
+	w.created[sibling] = true
+	w.handleEvent(fsnotify.Event{Name: sub, Op: fsnotify.Remove})
+	if _, ok := w.pending[b]; ok || w.created[b] || !w.created[sibling] {
+		t.Errorf("pending = %v, created = %v after removing sub", w.pending, w.created)
+	}
+
+	// 被删除的已有文件不再作为副本报告
+	w.handleEvent(fsnotify.Event{Name: a, Op: fsnotify.Remove})
+	if w.index.Has(a) {
+		t.Fatal("删除后 a 仍在索引中")
+	}
+	c := filepath.Join(dir, "c.txt")
+	writeTestFile(t, c, "same")
+	w.handleEvent(fsnotify.Event{Name: c, Op: fsnotify.Create})
+	w.check(context.Background(), c)
+	if events := decodeEvents(t, out); len(events) != 0 {
+		t.Errorf("events = %+v, want none", events)
+	}
+}
@@ -1,10 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
 )
 
 // Config 应用配置结构
@@ -16,6 +22,19 @@ type Config struct {
 	DryRun          bool     `yaml:"dry_run"`
 	OutputFormat     string   `yaml:"output_format"`
 	UseTrash        bool     `yaml:"use_trash"`
+	// I/O 限速，避免后台扫描占满磁盘带宽
+	MaxBytesPerSec   string   `yaml:"max_bytes_per_sec"`
+	MaxFilesPerSec   int      `yaml:"max_files_per_sec"`
+	LowIOPriority    bool     `yaml:"low_io_priority"`
+	// ScanArchives 展开 zip/tar 归档查找其中的重复文件（只报告，不修改归档）
+	ScanArchives     bool     `yaml:"scan_archives"`
+	// 相似图片检测：感知哈希算法 (ahash/dhash/phash，留空不检测) 与汉明距离阈值
+	SimilarImages    string   `yaml:"similar_images"`
+	SimilarThreshold int      `yaml:"similar_threshold"`
+	// DuplicateDirs 查找内容完全相同或被其他目录包含的目录
+	DuplicateDirs    bool     `yaml:"duplicate_dirs"`
+	// ReferenceDirs 参考目录（主库），其中的文件永远保留，只删除参考目录之外的副本
+	ReferenceDirs    []string `yaml:"reference_dirs"`
 }
 
 // DefaultConfig 返回默认配置
@@ -32,55 +51,129 @@ func DefaultConfig() *Config {
 		DryRun:      true,
 		OutputFormat: "txt",
 		UseTrash:    true,
+		MaxBytesPerSec: "0",
+		SimilarThreshold: dedup.DefaultImageThreshold,
 	}
 }
 
-// LoadConfig 从文件加载配置
-func LoadConfig(path string) (*Config, error) {
-	config := DefaultConfig()
-
-	if path == "" {
-		// 尝试从默认位置加载
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return config, nil
-		}
-		path = filepath.Join(homeDir, ".config", "dedupgo", "config.yaml")
+// DefaultPath 返回默认的配置文件路径：~/.config/dedupgo/config.yaml
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(homeDir, ".config", "dedupgo", "config.yaml"), nil
+}
 
-	data, err := os.ReadFile(path)
+// LoadConfig 加载默认值、配置文件、配置方案 profile 和环境变量合并后的配置并检查每个配置项，详见 Load
+func LoadConfig(path, profile string) (*Config, error) {
+	settings, err := Load(path, profile)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return config, nil
-		}
 		return nil, err
 	}
-
-	if err := yaml.Unmarshal(data, config); err != nil {
+	if err := settings.Validate(); err != nil {
 		return nil, err
 	}
-
-	return config, nil
+	return settings.Config, nil
 }
 
-// SaveConfig 保存配置到文件
+// configHeader 保存的配置文件开头的说明
+const configHeader = `DedupGo 配置文件
+命令行参数和 DEDUPGO_* 环境变量优先于这里的设置，dedupgo config show --effective 查看最终生效的配置。`
+
+// profilesExample 保存的配置文件末尾的配置方案示例
+const profilesExample = `配置方案在上面的基础配置之上覆盖其中写出的配置项，使用 --profile NAME 选择:
+profiles:
+  photos:
+    similar_images: phash
+    min_size: 100KB
+  backup:
+    reference_dirs: [/mnt/library]`
+
+// SaveConfig 保存配置到文件，每个配置项前写有说明
 func SaveConfig(config *Config, path string) error {
 	if path == "" {
-		homeDir, err := os.UserHomeDir()
+		var err error
+		path, err = DefaultPath()
 		if err != nil {
 			return err
 		}
-		configDir := filepath.Join(homeDir, ".config", "dedupgo")
-		if err := os.MkdirAll(configDir, 0755); err != nil {
-			return err
-		}
-		path = filepath.Join(configDir, "config.yaml")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
 
-	data, err := yaml.Marshal(config)
-	if err != nil {
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
 		return err
 	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		node.Content[i].HeadComment = fieldInfos[node.Content[i].Value].comment
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}, HeadComment: configHeader, FootComment: profilesExample}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// StatePath 返回命名的增量扫描状态文件路径：~/.cache/dedupgo/states/<name>.json
+func StatePath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("无效的状态名称: %q", name)
+	}
+
+	dir, err := StatesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// StatesDir 返回保存增量扫描状态的目录：~/.cache/dedupgo/states
+func StatesDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "dedupgo", "states"), nil
+}
 
-	return os.WriteFile(path, data, 0644)
-} 
\ No newline at end of file
+// JournalPath 返回操作日志的路径：~/.cache/dedupgo/journal.jsonl，每个被移除的文件记录一行
+func JournalPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "dedupgo", "journal.jsonl"), nil
+}
+
+// ScanOptions 将配置转换为扫描器选项
+func (c *Config) ScanOptions() ([]dedup.Option, error) {
+	minSize, err := utils.ParseSize(c.MinSize)
+	if err != nil {
+		return nil, fmt.Errorf("最小文件大小无效: %v", err)
+	}
+	maxBytesPerSec, err := utils.ParseSize(c.MaxBytesPerSec)
+	if err != nil {
+		return nil, fmt.Errorf("带宽上限无效: %v", err)
+	}
+
+	return []dedup.Option{
+		dedup.WithHashAlgorithm(c.HashAlgorithm),
+		dedup.WithMinSize(minSize),
+		dedup.WithFileTypes(c.IncludeTypes...),
+		dedup.WithExcludePatterns(c.ExcludePatterns...),
+		dedup.WithBandwidthLimit(maxBytesPerSec),
+		dedup.WithFileRateLimit(c.MaxFilesPerSec),
+		dedup.WithLowIOPriority(c.LowIOPriority),
+		dedup.WithArchives(c.ScanArchives),
+		dedup.WithSimilarImages(c.SimilarImages, c.SimilarThreshold),
+		dedup.WithDirectories(c.DuplicateDirs),
+		dedup.WithReferenceDirs(c.ReferenceDirs...),
+	}, nil
+}
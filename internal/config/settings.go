@@ -0,0 +1,376 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source 配置项的来源，按优先级从低到高排列
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceProfile
+	SourceEnv
+	SourceFlag
+)
+
+// EnvPrefix 环境变量前缀，配置项 hash_algorithm 对应 DEDUPGO_HASH_ALGORITHM，
+// 列表类型的配置项用逗号分隔多个值。DEDUPGO_CONFIG 指定配置文件路径，DEDUPGO_PROFILE 指定配置方案。
+const EnvPrefix = "DEDUPGO_"
+
+// Origin 配置项的值来自哪里
+type Origin struct {
+	Source Source
+	// Detail 配置方案名称、环境变量名或命令行参数名
+	Detail string
+	// File 和 Line 为配置项在配置文件中的位置，只用于来自配置文件或配置方案的配置项
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	switch o.Source {
+	case SourceFile:
+		return "配置文件 " + o.position()
+	case SourceProfile:
+		return "配置方案 " + o.Detail + " (" + o.position() + ")"
+	case SourceEnv:
+		return "环境变量 " + o.Detail
+	case SourceFlag:
+		return "命令行参数 " + o.Detail
+	default:
+		return "默认值"
+	}
+}
+
+// position 返回 "文件:行号" 形式的位置
+func (o Origin) position() string {
+	return fmt.Sprintf("%s:%d", o.File, o.Line)
+}
+
+// location 返回错误信息中使用的位置，来自配置文件时为 "文件:行号"
+func (o Origin) location() string {
+	if o.File != "" {
+		return o.position()
+	}
+	return o.String()
+}
+
+// Settings 依次由默认值、配置文件、配置方案、环境变量和命令行参数合并而成的配置，
+// 后一层只覆盖其中显式设置的配置项，并记录每一项的来源
+type Settings struct {
+	*Config
+	// Path 配置文件路径，文件不存在时为查找过的路径
+	Path string
+	// Profile 使用的配置方案，为空时只使用基础配置
+	Profile string
+	// Profiles 配置文件中定义的所有配置方案名称，按名称排序
+	Profiles []string
+
+	origins map[string]Origin
+}
+
+// Load 加载默认值、配置文件、配置方案和环境变量四层配置。path 为空时使用 DEDUPGO_CONFIG，
+// 仍为空时使用默认路径；配置文件不存在时跳过这一层。profile 为空时使用 DEDUPGO_PROFILE，
+// 仍为空时只使用基础配置。配置方案在基础配置之上覆盖其中出现的配置项，列表整体替换。
+func Load(path, profile string) (*Settings, error) {
+	return load(path, profile, true)
+}
+
+// ValidateFile 检查配置文件中的基础配置和每个配置方案，不受环境变量影响。
+// 返回的错误包含所有问题，每个问题一行，以 "文件:行号" 开头。
+func ValidateFile(path string) error {
+	s, err := load(path, "", false)
+	if err != nil {
+		return err
+	}
+	all := []*Settings{s}
+	for _, name := range s.Profiles {
+		p, err := load(path, name, false)
+		if err != nil {
+			return err
+		}
+		all = append(all, p)
+	}
+
+	// 基础配置中的错误在每个配置方案中都会出现，只报告一次
+	var errs []error
+	seen := make(map[string]bool)
+	for _, settings := range all {
+		for _, err := range settings.errors() {
+			if !seen[err.Error()] {
+				seen[err.Error()] = true
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func load(path, profile string, withEnv bool) (*Settings, error) {
+	s := &Settings{Config: DefaultConfig(), origins: make(map[string]Origin)}
+	for _, key := range Keys() {
+		s.origins[key] = Origin{Source: SourceDefault}
+	}
+
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		path, _ = DefaultPath()
+	}
+	if profile == "" && withEnv {
+		profile = os.Getenv(EnvPrefix + "PROFILE")
+	}
+	s.Path = path
+	s.Profile = profile
+	if path != "" {
+		if err := s.loadFile(path); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("未知的配置方案: %s", profile)
+	}
+
+	if !withEnv {
+		return s, nil
+	}
+	for _, key := range Keys() {
+		name := EnvPrefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := s.Set(key, value, Origin{Source: SourceEnv, Detail: name}); err != nil {
+			return nil, fmt.Errorf("环境变量 %s 无效: %v", name, err)
+		}
+	}
+	return s, nil
+}
+
+// loadFile 读取配置文件和其中选中的配置方案，出现的配置项记为来自配置文件或配置方案。
+// 未知的配置项和类型错误的值都会报告所在的行号；未选中的配置方案也会检查。
+func (s *Settings) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if s.Profile != "" {
+				return fmt.Errorf("未知的配置方案: %s (配置文件 %s 不存在)", s.Profile, path)
+			}
+			return nil
+		}
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	var errs []error
+	profiles := make(map[string]*yaml.Node)
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s:%d: 配置文件的顶层必须是键值映射", path, root.Line)
+		}
+		errs = s.decodeMapping(root, Origin{Source: SourceFile, File: path}, profiles)
+	}
+
+	for name := range profiles {
+		s.Profiles = append(s.Profiles, name)
+	}
+	sort.Strings(s.Profiles)
+	for _, name := range s.Profiles {
+		target := s
+		if name != s.Profile {
+			// 未选中的配置方案解码到副本中，只检查其中的错误
+			base := *s.Config
+			target = &Settings{Config: &base, origins: make(map[string]Origin)}
+		}
+		errs = append(errs, target.decodeMapping(profiles[name], Origin{Source: SourceProfile, Detail: name, File: path}, nil)...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if _, ok := profiles[s.Profile]; s.Profile != "" && !ok {
+		if len(s.Profiles) == 0 {
+			return fmt.Errorf("未知的配置方案: %s (配置文件中没有定义 profiles)", s.Profile)
+		}
+		return fmt.Errorf("未知的配置方案: %s (可用: %s)", s.Profile, strings.Join(s.Profiles, ", "))
+	}
+	return nil
+}
+
+// decodeMapping 将 YAML 映射中的配置项解码到配置中，并记录来源和行号。
+// profiles 不为 nil 时允许 profiles 键，其中的配置方案保存到 profiles 中。
+func (s *Settings) decodeMapping(node *yaml.Node, origin Origin, profiles map[string]*yaml.Node) []error {
+	if node.Kind != yaml.MappingNode {
+		return []error{fmt.Errorf("%s:%d: 配置方案 %s 必须是键值映射", origin.File, node.Line, origin.Detail)}
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if seen[key.Value] {
+			errs = append(errs, fmt.Errorf("%s:%d: 重复的配置项 %s", origin.File, key.Line, key.Value))
+			continue
+		}
+		seen[key.Value] = true
+
+		if key.Value == "profiles" && profiles != nil {
+			if value.Kind != yaml.MappingNode {
+				errs = append(errs, fmt.Errorf("%s:%d: profiles 必须是配置方案名称到配置的映射", origin.File, value.Line))
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				profiles[value.Content[j].Value] = value.Content[j+1]
+			}
+			continue
+		}
+
+		f, err := s.field(key.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: 未知的配置项 %s", origin.File, key.Line, key.Value))
+			continue
+		}
+		if err := value.Decode(f.Addr().Interface()); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s 需要%s", origin.File, value.Line, key.Value, kindName(f.Kind())))
+			continue
+		}
+		origin.Line = key.Line
+		s.origins[key.Value] = origin
+	}
+	return errs
+}
+
+// kindName 返回配置项类型的中文名称，用于错误信息
+func kindName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Bool:
+		return "布尔值 (true/false)"
+	case reflect.Int:
+		return "整数"
+	case reflect.Slice:
+		return "字符串列表"
+	default:
+		return "字符串"
+	}
+}
+
+// Validate 检查合并后的每个配置项，错误信息指出无效的值来自哪里
+func (s *Settings) Validate() error {
+	return errors.Join(s.errors()...)
+}
+
+func (s *Settings) errors() []error {
+	var errs []error
+	for _, err := range s.Config.validate() {
+		errs = append(errs, fmt.Errorf("%s: %w", s.origins[err.Key].location(), err))
+	}
+	return errs
+}
+
+// Keys 返回所有配置项的名称，按 Config 中字段的顺序排列
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, yamlKey(t.Field(i)))
+	}
+	return keys
+}
+
+func yamlKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return key
+}
+
+// field 返回配置项 key 对应的字段
+func (s *Settings) field(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(s.Config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if yamlKey(v.Type().Field(i)) == key {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("未知的配置项: %s", key)
+}
+
+// Set 按字符串设置配置项并记录来源，列表类型的值用逗号分隔
+func (s *Settings) Set(key, value string, origin Origin) error {
+	f, err := s.field(key)
+	if err != nil {
+		return err
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s 需要 true 或 false: %q", key, value)
+		}
+		f.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s 需要整数: %q", key, value)
+		}
+		f.SetInt(int64(n))
+	case reflect.Slice:
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		f.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("不支持的配置项类型: %s", key)
+	}
+	s.origins[key] = origin
+	return nil
+}
+
+// SetList 设置列表类型的配置项并记录来源，用于可能包含逗号的路径列表
+func (s *Settings) SetList(key string, values []string, origin Origin) error {
+	f, err := s.field(key)
+	if err != nil {
+		return err
+	}
+	if f.Kind() != reflect.Slice {
+		return fmt.Errorf("%s 不是列表类型的配置项", key)
+	}
+	f.Set(reflect.ValueOf(append([]string(nil), values...)))
+	s.origins[key] = origin
+	return nil
+}
+
+// Value 返回配置项当前值的字符串形式，格式与 Set 接受的相同
+func (s *Settings) Value(key string) string {
+	f, err := s.field(key)
+	if err != nil {
+		return ""
+	}
+	if f.Kind() == reflect.Slice {
+		return strings.Join(f.Interface().([]string), ",")
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// Origin 返回配置项的来源
+func (s *Settings) Origin(key string) Origin {
+	return s.origins[key]
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig 在临时目录中写入配置文件，并清除可能影响结果的 DEDUPGO_ 环境变量
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	for _, name := range append(Keys(), "config", "profile") {
+		name = EnvPrefix + strings.ToUpper(name)
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type originCase struct {
+	key, value string
+	source     Source
+	line       int
+}
+
+func checkOrigins(t *testing.T, s *Settings, tests []originCase) {
+	t.Helper()
+	for _, tt := range tests {
+		origin := s.Origin(tt.key)
+		if got := s.Value(tt.key); got != tt.value {
+			t.Errorf("%s = %q, want %q", tt.key, got, tt.value)
+		}
+		if origin.Source != tt.source || origin.Line != tt.line {
+			t.Errorf("%s origin = %v (line %d), want source %d line %d", tt.key, origin, origin.Line, tt.source, tt.line)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `hash_algorithm: sha256
+min_size: 1KB
+output_format: json
+`)
+	t.Setenv("DEDUPGO_OUTPUT_FORMAT", "html")
+
+	s, err := Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrigins(t, s, []originCase{
+		{"dry_run", "true", SourceDefault, 0},
+		{"hash_algorithm", "sha256", SourceFile, 1},
+		{"min_size", "1KB", SourceFile, 2},
+		{"output_format", "html", SourceEnv, 0},
+	})
+
+	// 命令行参数覆盖环境变量
+	if err := s.Set("output_format", "csv", Origin{Source: SourceFlag, Detail: "--output"}); err != nil {
+		t.Fatal(err)
+	}
+	checkOrigins(t, s, []originCase{{"output_format", "csv", SourceFlag, 0}})
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := writeConfig(t, `hash_algorithm: sha256
+min_size: 1KB
+output_format: json
+profiles:
+  big:
+    min_size: 1MB
+    output_format: csv
+    exclude_patterns: ["*.iso"]
+`)
+	t.Setenv("DEDUPGO_OUTPUT_FORMAT", "html")
+
+	s, err := Load(path, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrigins(t, s, []originCase{
+		{"hash_algorithm", "sha256", SourceFile, 1},
+		{"min_size", "1MB", SourceProfile, 6},
+		{"exclude_patterns", "*.iso", SourceProfile, 8},
+		{"output_format", "html", SourceEnv, 0},
+	})
+	if strings.Join(s.Profiles, ",") != "big" {
+		t.Errorf("Profiles = %v", s.Profiles)
+	}
+
+	// 不选择配置方案时只使用基础配置
+	s, err = Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MinSize != "1KB" || len(s.ExcludePatterns) != len(DefaultConfig().ExcludePatterns) {
+		t.Errorf("MinSize = %q, ExcludePatterns = %v", s.MinSize, s.ExcludePatterns)
+	}
+
+	if _, err := Load(path, "small"); err == nil || !strings.Contains(err.Error(), "可用: big") {
+		t.Errorf("Load(small) error = %v, want unknown profile", err)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{"unknown key", "hash_algorithm: md5\nmin_sise: 1KB\n", []string{":2: 未知的配置项 min_sise"}},
+		{"wrong type", "dry_run: [true]\nmax_files_per_sec: fast\n", []string{":1: dry_run 需要", ":2: max_files_per_sec 需要"}},
+		{"duplicate key", "min_size: 1KB\nmin_size: 2KB\n", []string{":2: 重复的配置项 min_size"}},
+		{"unselected profile", "profiles:\n  other:\n    colour: red\n", []string{":3: 未知的配置项 colour"}},
+		{"not a mapping", "- a\n- b\n", []string{":1: 配置文件的顶层必须是键值映射"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			_, err := Load(path, "")
+			if err == nil {
+				t.Fatal("Load succeeded, want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), path+want) {
+					t.Errorf("error = %v, want it to contain %q", err, path+want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFileLocations(t *testing.T) {
+	path := writeConfig(t, `hash_algorithm: crc32
+include_types:
+  - image
+  - .jpg
+profiles:
+  fast:
+    similar_threshold: 100
+`)
+	err := ValidateFile(path)
+	if err == nil {
+		t.Fatal("ValidateFile succeeded, want error")
+	}
+	for _, want := range []string{
+		path + ":1: hash_algorithm: 无效的值 \"crc32\"",
+		path + ":2: include_types: 无效的值 \".jpg\"",
+		path + ":7: similar_threshold:",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v, want it to contain %q", err, want)
+		}
+	}
+	if n := strings.Count(err.Error(), "crc32"); n != 1 {
+		t.Errorf("基础配置的错误报告了 %d 次，want 1", n)
+	}
+}
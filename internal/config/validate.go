@@ -0,0 +1,194 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/xiaozhe/dedupgo/internal/utils"
+	"github.com/xiaozhe/dedupgo/internal/utils/fileutil"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// OutputFormats 支持的输出格式，text 与 markdown 分别是 txt 与 md 的别名
+var OutputFormats = []string{"txt", "text", "json", "ndjson", "csv", "html", "md", "markdown", "fdupes"}
+
+// fieldInfo 配置项的说明和可选值，用于校验、生成带注释的配置文件和 JSON Schema。
+// 列表类型的配置项中，enum 限制的是列表中的每一项。
+type fieldInfo struct {
+	comment string
+	enum    []string
+}
+
+var fieldInfos = map[string]fieldInfo{
+	"hash_algorithm":    {comment: "哈希算法: md5 (快) 或 sha256 (更安全)", enum: []string{dedup.MD5, dedup.SHA256}},
+	"min_size":          {comment: "忽略小于该大小的文件，支持 B/KB/MB/GB/TB，0 表示不限"},
+	"exclude_patterns":  {comment: "排除的文件或目录名模式 (filepath.Match 语法)"},
+	"include_types":     {comment: "只扫描这些类型的文件 (按文件内容识别): " + strings.Join(fileutil.FileTypes, "/") + "，为空时不限", enum: fileutil.FileTypes},
+	"dry_run":           {comment: "只预览不删除，scan --force 相当于 false"},
+	"output_format":     {comment: "输出格式: " + strings.Join(OutputFormats, "/"), enum: OutputFormats},
+	"use_trash":         {comment: "删除时移到回收站，false 时直接删除"},
+	"max_bytes_per_sec": {comment: "读取带宽上限，每秒字节数 (如 20MB)，0 表示不限"},
+	"max_files_per_sec": {comment: "每秒最多处理的文件数，0 表示不限"},
+	"low_io_priority":   {comment: "降低进程的 I/O 优先级 (仅 Linux)"},
+	"scan_archives":     {comment: "查找 zip/tar 归档内的重复文件 (只报告，不修改归档)"},
+	"similar_images":    {comment: "查找相似图片的感知哈希算法: ahash/dhash/phash，留空不检测", enum: []string{"", dedup.AHash, dedup.DHash, dedup.PHash}},
+	"similar_threshold": {comment: "相似图片的汉明距离阈值 (0-64)，越大越宽松，0 只匹配感知哈希完全相同的图片"},
+	"duplicate_dirs":    {comment: "查找内容相同或被其他目录包含的目录"},
+	"reference_dirs":    {comment: "参考目录，其中的文件永远保留，只删除其他位置的副本"},
+}
+
+// FieldError 配置项的值无效
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validate 检查每个配置项的值，返回的错误包含所有无效的配置项，每一项为一个 *FieldError
+func (c *Config) Validate() error {
+	var errs []error
+	for _, err := range c.validate() {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validate() []*FieldError {
+	var errs []*FieldError
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, &FieldError{Key: key, Err: err})
+		}
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := yamlKey(v.Type().Field(i))
+		enum := fieldInfos[key].enum
+		if len(enum) == 0 {
+			continue
+		}
+		switch value := v.Field(i).Interface().(type) {
+		case string:
+			check(key, checkEnum(value, enum))
+		case []string:
+			for _, item := range value {
+				check(key, checkEnum(item, enum))
+			}
+		}
+	}
+	check("min_size", checkSize(c.MinSize))
+	check("max_bytes_per_sec", checkSize(c.MaxBytesPerSec))
+	for _, pattern := range c.ExcludePatterns {
+		if pattern == "" {
+			check("exclude_patterns", errors.New("包含空的模式"))
+		} else if _, err := filepath.Match(pattern, ""); err != nil {
+			check("exclude_patterns", fmt.Errorf("无效的模式 %q", pattern))
+		}
+	}
+	if c.MaxFilesPerSec < 0 {
+		check("max_files_per_sec", fmt.Errorf("不能为负数: %d", c.MaxFilesPerSec))
+	}
+	if c.SimilarThreshold < 0 || c.SimilarThreshold > 64 {
+		check("similar_threshold", fmt.Errorf("需要在 0 到 64 之间: %d", c.SimilarThreshold))
+	}
+	for _, dir := range c.ReferenceDirs {
+		if strings.TrimSpace(dir) == "" {
+			check("reference_dirs", errors.New("包含空的路径"))
+		}
+	}
+	return errs
+}
+
+// checkEnum 检查 value 是否为 enum 中的一个，不区分大小写
+func checkEnum(value string, enum []string) error {
+	for _, v := range enum {
+		if strings.EqualFold(value, v) {
+			return nil
+		}
+	}
+	var names []string
+	for _, v := range enum {
+		if v != "" {
+			names = append(names, v)
+		}
+	}
+	return fmt.Errorf("无效的值 %q，可选: %s", value, strings.Join(names, "/"))
+}
+
+// checkSize 检查大小的格式，不允许负数
+func checkSize(size string) error {
+	n, err := utils.ParseSize(size)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("不能为负数: %s", size)
+	}
+	return nil
+}
+
+// Schema 返回配置文件的 JSON Schema，供编辑器补全和检查 config.yaml
+func Schema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		key := yamlKey(t.Field(i))
+		info := fieldInfos[key]
+		prop := map[string]interface{}{"description": info.comment}
+		switch t.Field(i).Type.Kind() {
+		case reflect.Bool:
+			prop["type"] = "boolean"
+		case reflect.Int:
+			prop["type"] = "integer"
+			prop["minimum"] = 0
+		case reflect.Slice:
+			prop["type"] = "array"
+			items := map[string]interface{}{"type": "string"}
+			if len(info.enum) > 0 {
+				items["enum"] = info.enum
+			}
+			prop["items"] = items
+		default:
+			prop["type"] = "string"
+			if len(info.enum) > 0 {
+				prop["enum"] = info.enum
+			}
+		}
+		properties[key] = prop
+	}
+	properties["similar_threshold"].(map[string]interface{})["maximum"] = 64
+
+	profile := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	base := make(map[string]interface{}, len(properties)+1)
+	for key, prop := range properties {
+		base[key] = prop
+	}
+	base["profiles"] = map[string]interface{}{
+		"description":          "命名的配置方案，在基础配置之上覆盖其中写出的配置项",
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"$ref": "#/$defs/profile"},
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "DedupGo 配置文件",
+		"type":                 "object",
+		"properties":           base,
+		"additionalProperties": false,
+		"$defs":                map[string]interface{}{"profile": profile},
+	}
+}
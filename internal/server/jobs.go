@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// 扫描任务状态
+const (
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// ScanRequest 创建扫描任务的请求，未设置的字段使用服务端配置
+type ScanRequest struct {
+	Roots           []string `json:"roots"`
+	HashAlgorithm   string   `json:"hash_algorithm,omitempty"`
+	MinSize         string   `json:"min_size,omitempty"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
+	IncludeTypes    []string `json:"include_types,omitempty"`
+	ReferenceDirs   []string `json:"reference_dirs,omitempty"`
+	ScanArchives    bool     `json:"scan_archives,omitempty"`
+	SimilarImages   string   `json:"similar_images,omitempty"`
+	DuplicateDirs   bool     `json:"duplicate_dirs,omitempty"`
+}
+
+// Summary 扫描完成后的统计信息
+type Summary struct {
+	TotalFiles    int   `json:"total_files"`
+	TotalSize     int64 `json:"total_size"`
+	SavedSize     int64 `json:"saved_size"`
+	Groups        int   `json:"groups"`
+	SimilarGroups int   `json:"similar_groups"`
+	DuplicateDirs int   `json:"duplicate_dirs"`
+}
+
+// Job 扫描任务的状态快照
+type Job struct {
+	ID         string         `json:"id"`
+	Request    ScanRequest    `json:"request"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Progress   dedup.Progress `json:"progress"`
+	Summary    *Summary       `json:"summary,omitempty"`
+}
+
+// job 服务端保存的扫描任务
+type job struct {
+	mu     sync.Mutex
+	view   Job
+	result *dedup.Result
+	cancel context.CancelFunc
+
+	// actionMu 保证同一任务的执行请求依次处理
+	actionMu sync.Mutex
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.view
+}
+
+func (j *job) setProgress(p dedup.Progress) {
+	j.mu.Lock()
+	j.view.Progress = p
+	j.mu.Unlock()
+}
+
+// finish 记录扫描结果。任务被取消时状态为 StatusCanceled。
+func (j *job) finish(ctx context.Context, result *dedup.Result, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	j.view.FinishedAt = &now
+	switch {
+	case err == nil:
+		j.view.Status = StatusDone
+		j.result = result
+		j.view.Summary = &Summary{
+			TotalFiles:    result.TotalFiles,
+			TotalSize:     result.TotalSize,
+			SavedSize:     result.SavedSize,
+			Groups:        len(result.DuplicateGroups),
+			SimilarGroups: len(result.SimilarGroups),
+			DuplicateDirs: len(result.DuplicateDirs),
+		}
+	case ctx.Err() != nil:
+		j.view.Status = StatusCanceled
+	default:
+		j.view.Status = StatusFailed
+		j.view.Error = err.Error()
+	}
+}
+
+// finishedResult 返回已完成任务的扫描结果，任务未完成时返回 nil
+func (j *job) finishedResult() *dedup.Result {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.result
+}
+
+// ActionFailure 执行计划时处理失败的文件
+type ActionFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ActionRecord 一次执行计划的记录
+type ActionRecord struct {
+	ID        string          `json:"id"`
+	ScanID    string          `json:"scan_id"`
+	Time      time.Time       `json:"time"`
+	Executor  string          `json:"executor"`
+	Removed   []string        `json:"removed"`
+	Failed    []ActionFailure `json:"failed"`
+	FreedSize int64           `json:"freed_size"`
+}
+
+// newID 生成随机的任务 ID
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000000")))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,659 @@
+// Package server 提供 dedupgo serve 使用的 HTTP JSON API：
+// 创建和取消扫描任务、查询进度、分页获取重复组、生成并执行处理计划、查询历史记录，
+// 以及通过这些接口工作的浏览器界面（WebHandler）。
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// APIPrefix 所有 API 路径的前缀
+const APIPrefix = "/api/v1/"
+
+// 分页参数的默认值和上限
+const (
+	defaultPerPage = 50
+	maxPerPage     = 1000
+)
+
+// 已结束任务的保留时间和数量上限，超出后任务连同扫描结果一起被删除，运行中的任务不受影响
+const (
+	jobTTL          = 24 * time.Hour
+	maxFinishedJobs = 50
+)
+
+// Server dedupgo 的 HTTP API 服务，实现 http.Handler
+type Server struct {
+	cfg   *config.Config
+	token string
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	order   []string
+	actions []ActionRecord
+}
+
+// New 创建 API 服务。cfg 为扫描任务的默认配置；token 非空时每个请求都必须携带
+// “Authorization: Bearer <token>” 请求头，为空时只接受 Host 为本机地址的请求。
+// 带有 Origin 请求头的跨站请求总是被拒绝。
+// 已结束的任务最多保留 maxFinishedJobs 个、每个最多 jobTTL，创建新任务时清理。
+func New(cfg *config.Config, token string) *Server {
+	return &Server{
+		cfg:   cfg,
+		token: token,
+		jobs:  make(map[string]*job),
+	}
+}
+
+// Close 取消所有仍在运行的扫描任务
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		j.cancel()
+	}
+}
+
+// ServeHTTP 分发 API 请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkHost(r); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("未授权"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "scans":
+		switch r.Method {
+		case http.MethodGet:
+			s.listScans(w, r)
+		case http.MethodPost:
+			s.createScan(w, r)
+		default:
+			methodNotAllowed(w)
+		}
+
+	case len(parts) == 2 && parts[0] == "scans":
+		switch r.Method {
+		case http.MethodGet:
+			s.getScan(w, r, parts[1])
+		case http.MethodDelete:
+			s.deleteScan(w, r, parts[1])
+		default:
+			methodNotAllowed(w)
+		}
+
+	case len(parts) == 3 && parts[0] == "scans":
+		switch {
+		case parts[2] == "cancel" && r.Method == http.MethodPost:
+			s.cancelScan(w, r, parts[1])
+		case parts[2] == "groups" && r.Method == http.MethodGet:
+			s.listGroups(w, r, parts[1])
+		case parts[2] == "result" && r.Method == http.MethodGet:
+			s.getResult(w, r, parts[1])
+		case parts[2] == "plan" && r.Method == http.MethodGet:
+			s.getPlan(w, r, parts[1])
+		case parts[2] == "actions" && r.Method == http.MethodPost:
+			s.applyPlan(w, r, parts[1])
+		default:
+			writeError(w, http.StatusNotFound, errors.New("未知的接口"))
+		}
+
+	case len(parts) == 1 && parts[0] == "dirs":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		s.listDirs(w, r)
+
+	case len(parts) == 1 && parts[0] == "history":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		s.history(w, r)
+
+	default:
+		writeError(w, http.StatusNotFound, errors.New("未知的接口"))
+	}
+}
+
+// CheckListenAddr 检查监听地址。没有令牌时任何能连上端口的人都可以删除文件，
+// 因此 token 为空时只允许监听本机地址。
+func CheckListenAddr(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("无效的监听地址 %q: %v", addr, err)
+	}
+	if !IsLoopback(host) {
+		return fmt.Errorf("监听非本机地址 %s 时必须用 --token 或环境变量 DEDUPGO_TOKEN 设置访问令牌", addr)
+	}
+	return nil
+}
+
+// checkHost 拒绝来自其他网站的请求。未设置令牌时还要求 Host 是本机地址，
+// 防止恶意网页通过 DNS 重绑定以本机服务的身份访问接口。
+func (s *Server) checkHost(r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			return errors.New("拒绝跨站请求")
+		}
+	}
+	if s.token == "" {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !IsLoopback(host) {
+			return errors.New("未设置访问令牌时只接受通过本机地址的访问")
+		}
+	}
+	return nil
+}
+
+// IsLoopback 判断主机名是否为 localhost 或回环地址
+func IsLoopback(host string) bool {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+// createScan POST /scans：按请求创建扫描任务并在后台运行，立即返回任务
+func (s *Server) createScan(w http.ResponseWriter, r *http.Request) {
+	var req ScanRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, decodeStatus(err), err)
+		return
+	}
+	if len(req.Roots) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("roots 不能为空"))
+		return
+	}
+
+	j := &job{view: Job{
+		ID:        newID(),
+		Request:   req,
+		Status:    StatusRunning,
+		CreatedAt: time.Now(),
+	}}
+	scanner, err := s.newScanner(req, j.setProgress)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	s.mu.Lock()
+	s.evict(time.Now())
+	s.jobs[j.view.ID] = j
+	s.order = append(s.order, j.view.ID)
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := scanner.Scan(ctx, req.Roots...)
+		j.finish(ctx, result, err)
+	}()
+
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+// newScanner 在服务端配置的基础上应用请求中的设置
+func (s *Server) newScanner(req ScanRequest, progress dedup.ProgressFunc) (*dedup.Scanner, error) {
+	cfg := *s.cfg
+	if req.HashAlgorithm != "" {
+		cfg.HashAlgorithm = req.HashAlgorithm
+	}
+	if req.MinSize != "" {
+		cfg.MinSize = req.MinSize
+	}
+	if req.ExcludePatterns != nil {
+		cfg.ExcludePatterns = req.ExcludePatterns
+	}
+	if req.IncludeTypes != nil {
+		cfg.IncludeTypes = req.IncludeTypes
+	}
+	if req.ReferenceDirs != nil {
+		cfg.ReferenceDirs = req.ReferenceDirs
+	}
+	if req.ScanArchives {
+		cfg.ScanArchives = true
+	}
+	if req.SimilarImages != "" {
+		cfg.SimilarImages = req.SimilarImages
+	}
+	if req.DuplicateDirs {
+		cfg.DuplicateDirs = true
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	opts, err := cfg.ScanOptions()
+	if err != nil {
+		return nil, err
+	}
+	return dedup.New(append(opts, dedup.WithProgress(progress))...)
+}
+
+// evict 删除结束超过 jobTTL 的任务，已结束的任务多于 maxFinishedJobs 个时删除最早创建的。
+// 调用者必须持有 s.mu。
+func (s *Server) evict(now time.Time) {
+	finished := 0
+	for i := len(s.order) - 1; i >= 0; i-- {
+		id := s.order[i]
+		view := s.jobs[id].snapshot()
+		if view.FinishedAt == nil {
+			continue
+		}
+		if finished >= maxFinishedJobs || now.Sub(*view.FinishedAt) > jobTTL {
+			delete(s.jobs, id)
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			continue
+		}
+		finished++
+	}
+}
+
+// listScans GET /scans：按创建时间列出所有任务
+func (s *Server) listScans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"scans": s.snapshots(false)})
+}
+
+func (s *Server) snapshots(finishedOnly bool) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []Job{}
+	for _, id := range s.order {
+		view := s.jobs[id].snapshot()
+		if !finishedOnly || view.Status != StatusRunning {
+			jobs = append(jobs, view)
+		}
+	}
+	return jobs
+}
+
+func (s *Server) lookup(w http.ResponseWriter, id string) *job {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("扫描任务不存在: %s", id))
+		return nil
+	}
+	return j
+}
+
+// lookupResult 查找已完成的任务，任务不存在或未完成时写出错误并返回 nil
+func (s *Server) lookupResult(w http.ResponseWriter, id string) *dedup.Result {
+	j := s.lookup(w, id)
+	if j == nil {
+		return nil
+	}
+	return requireResult(w, j)
+}
+
+// requireResult 返回任务的扫描结果，任务未成功完成时写出错误并返回 nil
+func requireResult(w http.ResponseWriter, j *job) *dedup.Result {
+	result := j.finishedResult()
+	if result == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("扫描任务尚未成功完成: %s", j.snapshot().Status))
+	}
+	return result
+}
+
+// getScan GET /scans/{id}：任务状态和进度
+func (s *Server) getScan(w http.ResponseWriter, r *http.Request, id string) {
+	if j := s.lookup(w, id); j != nil {
+		writeJSON(w, http.StatusOK, j.snapshot())
+	}
+}
+
+// cancelScan POST /scans/{id}/cancel：取消正在运行的任务
+func (s *Server) cancelScan(w http.ResponseWriter, r *http.Request, id string) {
+	j := s.lookup(w, id)
+	if j == nil {
+		return
+	}
+	j.cancel()
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+// deleteScan DELETE /scans/{id}：取消任务并删除其结果
+func (s *Server) deleteScan(w http.ResponseWriter, r *http.Request, id string) {
+	j := s.lookup(w, id)
+	if j == nil {
+		return
+	}
+	j.cancel()
+
+	s.mu.Lock()
+	delete(s.jobs, id)
+	for i, other := range s.order {
+		if other == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listGroups GET /scans/{id}/groups?page=1&per_page=50：分页获取重复组，顺序与扫描结果相同
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request, id string) {
+	result := s.lookupResult(w, id)
+	if result == nil {
+		return
+	}
+
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		writeError(w, http.StatusBadRequest, errors.New("page 必须是正整数"))
+		return
+	}
+	perPage, err := queryInt(r, "per_page", defaultPerPage)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("per_page 必须在 1 到 %d 之间", maxPerPage))
+		return
+	}
+
+	total := len(result.DuplicateGroups)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"groups":   result.DuplicateGroups[start:end],
+	})
+}
+
+// getResult GET /scans/{id}/result：完整的扫描结果，格式与 dedupgo --output json 相同
+func (s *Server) getResult(w http.ResponseWriter, r *http.Request, id string) {
+	if result := s.lookupResult(w, id); result != nil {
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// getPlan GET /scans/{id}/plan?keep=oldest：按保留规则生成的处理计划，只预览不执行
+func (s *Server) getPlan(w http.ResponseWriter, r *http.Request, id string) {
+	result := s.lookupResult(w, id)
+	if result == nil {
+		return
+	}
+	rule, err := dedup.KeepRuleByName(r.URL.Query().Get("keep"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dedup.NewPlan(result, rule))
+}
+
+// ActionRequest 执行计划的请求。Plan 为空时按 Keep 规则为所有重复组生成计划；
+// 提供 Plan 时只能引用该扫描结果中的文件。Executor 为 trash（默认）、delete 或 dry-run。
+type ActionRequest struct {
+	Keep     string      `json:"keep,omitempty"`
+	Plan     *dedup.Plan `json:"plan,omitempty"`
+	Executor string      `json:"executor,omitempty"`
+}
+
+// applyPlan POST /scans/{id}/actions：执行处理计划并记录到历史中。
+// 同一扫描任务的执行请求依次处理，避免两个请求按同一份结果同时移除文件。
+func (s *Server) applyPlan(w http.ResponseWriter, r *http.Request, id string) {
+	j := s.lookup(w, id)
+	if j == nil {
+		return
+	}
+	j.actionMu.Lock()
+	defer j.actionMu.Unlock()
+	result := requireResult(w, j)
+	if result == nil {
+		return
+	}
+
+	var req ActionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, decodeStatus(err), err)
+		return
+	}
+
+	var plan *dedup.Plan
+	if req.Plan != nil {
+		var err error
+		if plan, err = checkPlan(result, req.Plan); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		rule, err := dedup.KeepRuleByName(req.Keep)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		plan = dedup.NewPlan(result, rule)
+	}
+
+	var executor dedup.Executor
+	switch req.Executor {
+	case "", "trash":
+		req.Executor = "trash"
+		executor = dedup.TrashExecutor{}
+	case "delete":
+		executor = dedup.DeleteExecutor{}
+	case "dry-run":
+		executor = dedup.DryRunExecutor{}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("未知的执行方式: %s", req.Executor))
+		return
+	}
+
+	// 实际移除文件时同时记录到操作日志，与命令行共用同一个日志文件
+	var journal *dedup.Journal
+	if req.Executor != "dry-run" {
+		path, err := config.JournalPath()
+		if err == nil {
+			journal, err = dedup.OpenJournal(path)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("打开操作日志失败: %v", err))
+			return
+		}
+		executor = journal.Executor(plan, executor, req.Executor)
+	}
+
+	report, err := plan.Apply(r.Context(), executor)
+	if journal != nil {
+		journal.Close()
+	}
+	if errors.Is(err, dedup.ErrInvalidPlan) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	record := ActionRecord{
+		ID:        newID(),
+		ScanID:    id,
+		Time:      time.Now(),
+		Executor:  req.Executor,
+		Removed:   []string{},
+		Failed:    []ActionFailure{},
+		FreedSize: report.FreedSize,
+	}
+	for _, file := range report.Removed {
+		record.Removed = append(record.Removed, file.Path)
+	}
+	for _, f := range report.Failed {
+		record.Failed = append(record.Failed, ActionFailure{Path: f.File.Path, Error: f.Err.Error()})
+	}
+	s.mu.Lock()
+	s.actions = append(s.actions, record)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, record)
+}
+
+// checkPlan 检查客户端提交的计划只引用扫描结果中同一重复组的文件，
+// 并用扫描时记录的文件信息替换客户端提供的信息，使执行前的检查以扫描结果为准
+func checkPlan(result *dedup.Result, submitted *dedup.Plan) (*dedup.Plan, error) {
+	groups := make(map[string]map[string]dedup.FileInfo)
+	for _, group := range result.DuplicateGroups {
+		files := make(map[string]dedup.FileInfo)
+		for _, file := range group.Files {
+			files[file.Path] = file
+		}
+		groups[group.Hash] = files
+	}
+
+	plan := &dedup.Plan{Groups: []dedup.PlanGroup{}}
+	for _, pg := range submitted.Groups {
+		files, ok := groups[pg.Hash]
+		if !ok {
+			return nil, fmt.Errorf("扫描结果中没有哈希值为 %s 的重复组", pg.Hash)
+		}
+		keep, ok := files[pg.Keep.Path]
+		if !ok {
+			return nil, fmt.Errorf("保留的文件不在重复组 %s 中: %s", pg.Hash, pg.Keep.Path)
+		}
+
+		checked := dedup.PlanGroup{Hash: pg.Hash, Size: keep.Size, Keep: keep}
+		for _, f := range pg.Remove {
+			file, ok := files[f.Path]
+			if !ok {
+				return nil, fmt.Errorf("待删除的文件不在重复组 %s 中: %s", pg.Hash, f.Path)
+			}
+			if file.Path == keep.Path {
+				return nil, fmt.Errorf("同一文件不能既保留又删除: %s", f.Path)
+			}
+			checked.Remove = append(checked.Remove, file)
+		}
+		plan.Groups = append(plan.Groups, checked)
+	}
+	return plan, nil
+}
+
+// history GET /history：已结束的扫描任务和所有执行记录
+func (s *Server) history(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	actions := append([]ActionRecord{}, s.actions...)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"scans":   s.snapshots(true),
+		"actions": actions,
+	})
+}
+
+// listDirs GET /dirs?path=/srv：列出服务器上的子目录，供浏览器界面选择扫描目录
+func (s *Server) listDirs(w http.ResponseWriter, r *http.Request) {
+	dir := r.URL.Query().Get("path")
+	if dir == "" {
+		dir = "/"
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	dirs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"path": dir, "dirs": dirs})
+}
+
+// errContentType 请求体不是 JSON。要求 application/json 使浏览器在跨站提交前必须先发送预检请求。
+var errContentType = errors.New("请求的 Content-Type 必须是 application/json")
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errContentType
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 16<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("无效的请求内容: %v", err)
+	}
+	return nil
+}
+
+// decodeStatus decodeJSON 出错时的响应状态码
+func decodeStatus(err error) int {
+	if errors.Is(err, errContentType) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, errors.New("不支持的请求方法"))
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiaozhe/dedupgo/internal/config"
+	"github.com/xiaozhe/dedupgo/pkg/dedup"
+)
+
+// newRequest 创建发往本机地址的请求，body 非空时作为 JSON 请求体
+func newRequest(method, path, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Host = "127.0.0.1:8080"
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// serve 发送请求并返回响应
+func serve(s *Server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// addJob 加入一个已完成的扫描任务
+func addJob(s *Server, id string, result *dedup.Result, finished time.Time) *job {
+	j := &job{
+		view:   Job{ID: id, Status: StatusDone, CreatedAt: finished, FinishedAt: &finished},
+		result: result,
+		cancel: func() {},
+	}
+	s.mu.Lock()
+	s.jobs[id] = j
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+	return j
+}
+
+func TestCheckHostAndToken(t *testing.T) {
+	tests := []struct {
+		name, token, host, origin, auth string
+		want                            int
+	}{
+		{"loopback", "", "127.0.0.1:8080", "", "", http.StatusOK},
+		{"localhost", "", "localhost:8080", "", "", http.StatusOK},
+		{"ipv6 loopback", "", "[::1]:8080", "", "", http.StatusOK},
+		{"same origin", "", "127.0.0.1:8080", "http://127.0.0.1:8080", "", http.StatusOK},
+		{"cross origin", "", "127.0.0.1:8080", "http://evil.example", "", http.StatusForbidden},
+		{"dns rebinding", "", "evil.example:8080", "", "", http.StatusForbidden},
+		{"token", "secret", "nas.lan:8080", "", "Bearer secret", http.StatusOK},
+		{"wrong token", "secret", "nas.lan:8080", "", "Bearer guess", http.StatusUnauthorized},
+		{"missing token", "secret", "127.0.0.1:8080", "", "", http.StatusUnauthorized},
+		{"token cross origin", "secret", "nas.lan:8080", "http://evil.example", "Bearer secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(config.DefaultConfig(), tt.token)
+			r := httptest.NewRequest(http.MethodGet, APIPrefix+"scans", nil)
+			r.Host = tt.host
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			if w := serve(s, r); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCheckListenAddr(t *testing.T) {
+	tests := []struct {
+		addr, token string
+		ok          bool
+	}{
+		{"127.0.0.1:8080", "", true},
+		{"localhost:8080", "", true},
+		{"[::1]:8080", "", true},
+		{"0.0.0.0:8080", "", false},
+		{":8080", "", false},
+		{"192.168.1.2:8080", "", false},
+		{"0.0.0.0:8080", "secret", true},
+		{"no-port", "", false},
+	}
+	for _, tt := range tests {
+		if err := CheckListenAddr(tt.addr, tt.token); (err == nil) != tt.ok {
+			t.Errorf("CheckListenAddr(%q, %q) = %v, want ok %v", tt.addr, tt.token, err, tt.ok)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+		want                    int
+	}{
+		{"valid", "application/json", `{"keep":"oldest"}`, 0},
+		{"charset", "application/json; charset=utf-8", `{}`, 0},
+		{"form", "application/x-www-form-urlencoded", `{}`, http.StatusUnsupportedMediaType},
+		{"missing type", "", `{}`, http.StatusUnsupportedMediaType},
+		{"unknown field", "application/json", `{"keep":"first","force":true}`, http.StatusBadRequest},
+		{"malformed", "application/json", `{"keep":`, http.StatusBadRequest},
+		{"too large", "application/json", `{"keep":"` + strings.Repeat("x", 16<<20) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			var req ActionRequest
+			err := decodeJSON(r, &req)
+			switch {
+			case tt.want == 0 && err != nil:
+				t.Errorf("decodeJSON: %v", err)
+			case tt.want != 0 && err == nil:
+				t.Error("decodeJSON succeeded, want error")
+			case tt.want != 0 && decodeStatus(err) != tt.want:
+				t.Errorf("decodeStatus(%v) = %d, want %d", err, decodeStatus(err), tt.want)
+			}
+		})
+	}
+}
+
+func testResult() *dedup.Result {
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &dedup.Result{DuplicateGroups: []dedup.DuplicateGroup{
+		{Hash: "h1", Size: 4, Files: []dedup.FileInfo{
+			{Path: "/a/1", Size: 4, ModTime: mtime},
+			{Path: "/b/1", Size: 4, ModTime: mtime},
+			{Path: "/c/1", Size: 4, ModTime: mtime},
+		}},
+		{Hash: "h2", Size: 2, Files: []dedup.FileInfo{
+			{Path: "/a/2", Size: 2, ModTime: mtime},
+			{Path: "/b/2", Size: 2, ModTime: mtime},
+		}},
+	}}
+}
+
+func TestCheckPlan(t *testing.T) {
+	result := testResult()
+	file := func(path string) dedup.FileInfo {
+		// 客户端提交的文件信息不可信，会被扫描结果中的信息替换
+		return dedup.FileInfo{Path: path, Size: 999}
+	}
+	tests := []struct {
+		name  string
+		group dedup.PlanGroup
+		err   string
+	}{
+		{"valid", dedup.PlanGroup{Hash: "h1", Keep: file("/a/1"), Remove: []dedup.FileInfo{file("/b/1"), file("/c/1")}}, ""},
+		{"unknown group", dedup.PlanGroup{Hash: "h3", Keep: file("/a/1")}, "没有哈希值为 h3"},
+		{"keep outside group", dedup.PlanGroup{Hash: "h1", Keep: file("/a/2")}, "保留的文件不在重复组"},
+		{"remove outside group", dedup.PlanGroup{Hash: "h1", Keep: file("/a/1"), Remove: []dedup.FileInfo{file("/a/2")}}, "待删除的文件不在重复组"},
+		{"keep removed", dedup.PlanGroup{Hash: "h1", Keep: file("/a/1"), Remove: []dedup.FileInfo{file("/a/1")}}, "既保留又删除"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := checkPlan(result, &dedup.Plan{Groups: []dedup.PlanGroup{tt.group}})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("checkPlan error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := plan.Groups[0]
+			if g.Size != 4 || g.Keep.Size != 4 || g.Remove[0].Size != 4 || g.Keep.ModTime.IsZero() {
+				t.Errorf("文件信息没有替换为扫描结果: %+v", g)
+			}
+		})
+	}
+}
+
+func TestListGroupsPagination(t *testing.T) {
+	s := New(config.DefaultConfig(), "")
+	result := &dedup.Result{}
+	for i := 0; i < 5; i++ {
+		result.DuplicateGroups = append(result.DuplicateGroups, dedup.DuplicateGroup{Hash: fmt.Sprint(i)})
+	}
+	addJob(s, "scan", result, time.Now())
+
+	tests := []struct {
+		query  string
+		status int
+		hashes []string
+	}{
+		{"", http.StatusOK, []string{"0", "1", "2", "3", "4"}},
+		{"?page=1&per_page=2", http.StatusOK, []string{"0", "1"}},
+		{"?page=3&per_page=2", http.StatusOK, []string{"4"}},
+		{"?page=4&per_page=2", http.StatusOK, []string{}},
+		{"?page=0", http.StatusBadRequest, nil},
+		{"?per_page=1001", http.StatusBadRequest, nil},
+		{"?per_page=x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		w := serve(s, newRequest(http.MethodGet, APIPrefix+"scans/scan/groups"+tt.query, ""))
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if tt.hashes == nil {
+			continue
+		}
+		var body struct {
+			Total  int                    `json:"total"`
+			Groups []dedup.DuplicateGroup `json:"groups"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		hashes := []string{}
+		for _, g := range body.Groups {
+			hashes = append(hashes, g.Hash)
+		}
+		if body.Total != 5 || strings.Join(hashes, ",") != strings.Join(tt.hashes, ",") {
+			t.Errorf("%s: total %d, groups %v, want %v", tt.query, body.Total, hashes, tt.hashes)
+		}
+	}
+}
+
+func TestCreateScanValidatesConfig(t *testing.T) {
+	s := New(config.DefaultConfig(), "")
+	for _, body := range []string{
+		`{"roots":["/tmp"],"hash_algorithm":"crc32"}`,
+		`{"roots":["/tmp"],"include_types":[".jpg"]}`,
+	} {
+		if w := serve(s, newRequest(http.MethodPost, APIPrefix+"scans", body)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+	if len(s.jobs) != 0 {
+		t.Errorf("无效的请求创建了 %d 个任务", len(s.jobs))
+	}
+}
+
+func TestEvictJobs(t *testing.T) {
+	s := New(config.DefaultConfig(), "")
+	now := time.Now()
+	addJob(s, "expired", &dedup.Result{}, now.Add(-jobTTL-time.Minute))
+	running := &job{view: Job{ID: "running", Status: StatusRunning}, cancel: func() {}}
+	s.jobs["running"] = running
+	s.order = append(s.order, "running")
+	for i := 0; i < maxFinishedJobs+2; i++ {
+		addJob(s, fmt.Sprintf("job%d", i), &dedup.Result{}, now)
+	}
+
+	s.mu.Lock()
+	s.evict(now)
+	s.mu.Unlock()
+
+	if _, ok := s.jobs["expired"]; ok {
+		t.Error("过期的任务没有被删除")
+	}
+	if _, ok := s.jobs["running"]; !ok {
+		t.Error("运行中的任务被删除")
+	}
+	for _, id := range []string{"job0", "job1"} {
+		if _, ok := s.jobs[id]; ok {
+			t.Errorf("超出上限时最早的任务 %s 没有被删除", id)
+		}
+	}
+	if len(s.jobs) != maxFinishedJobs+1 || len(s.order) != len(s.jobs) {
+		t.Errorf("jobs = %d, order = %d, want %d", len(s.jobs), len(s.order), maxFinishedJobs+1)
+	}
+}
+
+func TestApplyPlanSerialized(t *testing.T) {
+	s := New(config.DefaultConfig(), "")
+	j := addJob(s, "scan", testResult(), time.Now())
+
+	post := func() *httptest.ResponseRecorder {
+		return serve(s, newRequest(http.MethodPost, APIPrefix+"scans/scan/actions", `{"executor":"dry-run"}`))
+	}
+
+	// 同一任务正在执行计划时，新的请求等待其完成
+	j.actionMu.Lock()
+	var wg sync.WaitGroup
+	done := make(chan *httptest.ResponseRecorder, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		done <- post()
+	}()
+	select {
+	case <-done:
+		t.Fatal("请求没有等待正在进行的执行")
+	case <-time.After(50 * time.Millisecond):
+	}
+	j.actionMu.Unlock()
+	wg.Wait()
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("status = %d: %s", w.Code, w.Body)
+	}
+
+	// 同一文件出现在多个组中的计划在执行前被拒绝
+	r := newRequest(http.MethodPost, APIPrefix+"scans/scan/actions", `{"executor":"dry-run","plan":{"groups":[
+		{"hash":"h1","keep":{"path":"/a/1"},"remove":[{"path":"/b/1"}]},
+		{"hash":"h1","keep":{"path":"/c/1"},"remove":[{"path":"/b/1"}]}]}}`)
+	if w := serve(s, r); w.Code != http.StatusBadRequest {
+		t.Errorf("repeated file: status = %d, want 400: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles 浏览器界面的静态文件，编译时嵌入二进制
+//
+//go:embed web
+var webFiles embed.FS
+
+// WebHandler 返回浏览器界面的静态文件服务，界面通过 APIPrefix 下的接口与服务端交互
+func WebHandler() http.Handler {
+	sub, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,394 @@
+// DedupGo 浏览器界面：通过 /api/v1 接口创建扫描任务、查看进度、勾选并处理重复文件
+"use strict";
+
+const API = "/api/v1";
+const PER_PAGE = 50;
+
+const state = {
+  dirs: [],           // {path, reference}
+  scanID: null,
+  referenceMode: false,
+  groups: [],         // 已加载的重复组
+  total: 0,
+  selected: new Map(), // 组哈希 -> 选中待删除的路径集合
+  pollTimer: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// api 发送请求，服务端要求令牌时提示输入并保存在本地
+async function api(method, path, body) {
+  const headers = { "Content-Type": "application/json" };
+  const token = localStorage.getItem("dedupgo-token");
+  if (token) {
+    headers["Authorization"] = "Bearer " + token;
+  }
+  const resp = await fetch(API + path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (resp.status === 401) {
+    const input = prompt("请输入访问令牌");
+    if (input) {
+      localStorage.setItem("dedupgo-token", input);
+      return api(method, path, body);
+    }
+  }
+  if (resp.status === 204) {
+    return null;
+  }
+  const data = await resp.json();
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+function formatSize(bytes) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let size = bytes;
+  let i = 0;
+  while (size >= 1024 && i < units.length - 1) {
+    size /= 1024;
+    i++;
+  }
+  return (i === 0 ? size : size.toFixed(1)) + " " + units[i];
+}
+
+function formatDuration(ns) {
+  const seconds = Math.round(ns / 1e9);
+  if (seconds < 60) {
+    return seconds + " 秒";
+  }
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 60) {
+    return minutes + " 分 " + (seconds % 60) + " 秒";
+  }
+  return Math.floor(minutes / 60) + " 小时 " + (minutes % 60) + " 分";
+}
+
+function splitList(text) {
+  return text.split(",").map((s) => s.trim()).filter((s) => s !== "");
+}
+
+function element(tag, props, ...children) {
+  const el = document.createElement(tag);
+  Object.assign(el, props);
+  for (const child of children) {
+    el.append(child);
+  }
+  return el;
+}
+
+// ---- 目录列表 ----
+
+function renderDirs() {
+  const list = $("dir-list");
+  list.replaceChildren();
+  if (state.dirs.length === 0) {
+    list.append(element("li", { className: "hint", textContent: "等待添加扫描目录..." }));
+    return;
+  }
+  state.dirs.forEach((dir, i) => {
+    const reference = element("input", { type: "checkbox", checked: dir.reference });
+    reference.addEventListener("change", () => { dir.reference = reference.checked; });
+    const remove = element("button", { textContent: "移除" });
+    remove.addEventListener("click", () => {
+      state.dirs.splice(i, 1);
+      renderDirs();
+    });
+    list.append(element("li", {},
+      element("span", { textContent: "📁 " + dir.path }),
+      element("label", { title: "参考目录中的文件永远保留" }, reference, " 参考"),
+      remove));
+  });
+}
+
+$("dir-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const path = $("dir-input").value.trim();
+  if (path !== "" && !state.dirs.some((d) => d.path === path)) {
+    state.dirs.push({ path, reference: false });
+    renderDirs();
+  }
+  $("dir-input").value = "";
+});
+
+// 输入路径时列出服务器上的子目录作为候选
+let suggestTimer = null;
+$("dir-input").addEventListener("input", () => {
+  clearTimeout(suggestTimer);
+  suggestTimer = setTimeout(async () => {
+    const value = $("dir-input").value;
+    const parent = value.endsWith("/") ? value : value.slice(0, value.lastIndexOf("/") + 1);
+    if (parent === "") {
+      return;
+    }
+    try {
+      const data = await api("GET", "/dirs?path=" + encodeURIComponent(parent));
+      $("dir-suggestions").replaceChildren(...data.dirs.map((d) => element("option", { value: d })));
+    } catch (err) {
+      $("dir-suggestions").replaceChildren();
+    }
+  }, 200);
+});
+
+$("clear-button").addEventListener("click", () => {
+  state.dirs = [];
+  renderDirs();
+  $("results").hidden = true;
+});
+
+// ---- 扫描与进度 ----
+
+$("scan-button").addEventListener("click", async () => {
+  if (state.dirs.length === 0) {
+    alert("请先添加要扫描的目录");
+    return;
+  }
+  // 参考目录中的文件永远保留，只有参考目录时没有可以处理的文件
+  if (state.dirs.every((d) => d.reference)) {
+    alert("所有目录都标记为参考目录，请至少保留一个非参考目录作为扫描目录");
+    return;
+  }
+  const request = {
+    roots: state.dirs.filter((d) => !d.reference).map((d) => d.path),
+    reference_dirs: state.dirs.filter((d) => d.reference).map((d) => d.path),
+    hash_algorithm: $("hash").value,
+    min_size: $("min-size").value.trim(),
+  };
+  const exclude = splitList($("exclude").value);
+  if (exclude.length > 0) {
+    request.exclude_patterns = exclude;
+  }
+  const types = splitList($("types").value);
+  if (types.length > 0) {
+    request.include_types = types;
+  }
+
+  try {
+    const job = await api("POST", "/scans", request);
+    state.scanID = job.id;
+    state.referenceMode = request.reference_dirs.length > 0;
+    $("scan-button").disabled = true;
+    $("results").hidden = true;
+    $("progress").hidden = false;
+    showProgress(job);
+    state.pollTimer = setInterval(poll, 500);
+  } catch (err) {
+    alert("创建扫描任务失败: " + err.message);
+  }
+});
+
+$("cancel-button").addEventListener("click", async () => {
+  if (state.scanID) {
+    await api("POST", "/scans/" + state.scanID + "/cancel");
+  }
+});
+
+async function poll() {
+  let job;
+  try {
+    job = await api("GET", "/scans/" + state.scanID);
+  } catch (err) {
+    return;
+  }
+  showProgress(job);
+  if (job.status === "running") {
+    return;
+  }
+
+  clearInterval(state.pollTimer);
+  $("scan-button").disabled = false;
+  $("progress").hidden = true;
+  if (job.status === "done") {
+    showSummary(job.summary);
+    loadGroups(true);
+  } else if (job.status === "failed") {
+    alert("扫描失败: " + job.error);
+  }
+}
+
+function showProgress(job) {
+  const p = job.progress;
+  const bar = $("progress-bar");
+  let text;
+  if (p.phase === "walking" || p.phase === "") {
+    bar.removeAttribute("value");
+    text = `正在遍历目录：已发现 ${p.files_found} 个文件，${formatSize(p.bytes_found)}`;
+  } else if (p.phase === "hashing") {
+    bar.max = Math.max(p.bytes_to_hash, 1);
+    bar.value = p.bytes_hashed;
+    text = `正在计算哈希：${p.files_hashed} / ${p.files_to_hash} 个文件，` +
+      `${formatSize(p.bytes_hashed)} / ${formatSize(p.bytes_to_hash)}，已发现 ${p.groups} 组重复`;
+    if (p.remaining > 0) {
+      text += `，预计剩余 ${formatDuration(p.remaining)}`;
+    }
+  } else {
+    bar.removeAttribute("value");
+    text = "正在分析结果...";
+  }
+  $("progress-text").textContent = text + `（已用时 ${formatDuration(p.elapsed)}）`;
+}
+
+function showSummary(summary) {
+  const item = (label, value) => element("div", {}, label, element("b", { textContent: value }));
+  $("summary").replaceChildren(
+    item("📁 总文件数", summary.total_files),
+    item("💾 总大小", formatSize(summary.total_size)),
+    item("🗑️ 可节省空间", formatSize(summary.saved_size)),
+    item("🔍 重复文件组", summary.groups));
+  $("results").hidden = false;
+}
+
+// ---- 重复组 ----
+
+async function loadGroups(reset) {
+  if (reset) {
+    state.groups = [];
+    state.selected = new Map();
+    $("groups").replaceChildren();
+  }
+  const page = Math.floor(state.groups.length / PER_PAGE) + 1;
+  const data = await api("GET", `/scans/${state.scanID}/groups?page=${page}&per_page=${PER_PAGE}`);
+  state.total = data.total;
+  for (const group of data.groups) {
+    state.groups.push(group);
+    state.selected.set(group.hash, defaultSelection(group));
+    $("groups").append(renderGroup(group, state.groups.length));
+  }
+  if (state.total === 0) {
+    $("groups").append(element("p", { textContent: "✨ 恭喜！未发现重复文件" }));
+  }
+  $("more-button").hidden = state.groups.length >= state.total;
+  updateSelection();
+}
+
+$("more-button").addEventListener("click", () => loadGroups(false));
+
+// defaultSelection 与命令行的默认计划相同：有参考文件时删除参考目录之外的所有副本，
+// 否则保留第一个文件；归档内的成员不能删除
+function defaultSelection(group) {
+  const removable = group.files.filter((f) => !f.archive && !f.reference);
+  const hasReference = group.files.some((f) => f.reference && !f.archive);
+  const selected = new Set();
+  if (hasReference) {
+    removable.forEach((f) => selected.add(f.path));
+  } else if (!state.referenceMode) {
+    removable.slice(1).forEach((f) => selected.add(f.path));
+  }
+  return selected;
+}
+
+function renderGroup(group, number) {
+  const selected = state.selected.get(group.hash);
+  const warning = element("div", { className: "warning", hidden: true,
+    textContent: "组内所有文件都被选中，至少需要保留一个文件，该组不会被处理" });
+  const table = element("table");
+
+  for (const file of group.files) {
+    const locked = Boolean(file.archive || file.reference);
+    const check = element("input", { type: "checkbox", checked: selected.has(file.path), disabled: locked });
+    const tag = element("span", { className: "tag" });
+    const refresh = () => {
+      if (file.reference) {
+        tag.className = "tag reference";
+        tag.textContent = "参考";
+      } else if (file.archive) {
+        tag.className = "tag";
+        tag.textContent = "归档内";
+      } else if (selected.has(file.path)) {
+        tag.className = "tag remove";
+        tag.textContent = "待删除";
+      } else {
+        tag.className = "tag keep";
+        tag.textContent = "保留";
+      }
+    };
+    check.addEventListener("change", () => {
+      if (check.checked) {
+        selected.add(file.path);
+      } else {
+        selected.delete(file.path);
+      }
+      refresh();
+      warning.hidden = keptFile(group) !== null || selected.size === 0;
+      updateSelection();
+    });
+    refresh();
+
+    const row = element("tr", {},
+      element("td", {}, check),
+      element("td", {}, tag),
+      element("td", { className: "path", textContent: file.path }),
+      element("td", { textContent: new Date(file.mtime).toLocaleString() }));
+    table.append(row);
+  }
+
+  return element("div", { className: "group" },
+    element("h3", { textContent:
+      `第 ${number} 组 · ${group.files.length} 个文件 · 每个 ${formatSize(group.size)} · ${group.hash}` }),
+    table, warning);
+}
+
+// keptFile 返回组内保留的文件：优先参考文件，其次第一个未选中的归档外文件
+function keptFile(group) {
+  const selected = state.selected.get(group.hash);
+  const loose = group.files.filter((f) => !f.archive);
+  return loose.find((f) => f.reference) || loose.find((f) => !selected.has(f.path)) || null;
+}
+
+// buildPlan 将勾选结果转换为处理计划，全部选中的组被跳过
+function buildPlan() {
+  const plan = { groups: [] };
+  for (const group of state.groups) {
+    const selected = state.selected.get(group.hash);
+    const keep = keptFile(group);
+    if (selected.size === 0 || keep === null) {
+      continue;
+    }
+    plan.groups.push({
+      hash: group.hash,
+      size: group.size,
+      keep: { path: keep.path },
+      remove: group.files.filter((f) => selected.has(f.path)).map((f) => ({ path: f.path })),
+    });
+  }
+  return plan;
+}
+
+function updateSelection() {
+  let files = 0;
+  let size = 0;
+  for (const pg of buildPlan().groups) {
+    files += pg.remove.length;
+    size += pg.remove.length * pg.size;
+  }
+  $("selection-text").textContent = `已选中 ${files} 个文件，共 ${formatSize(size)}`;
+  $("trash-button").disabled = files === 0;
+}
+
+$("trash-button").addEventListener("click", async () => {
+  const plan = buildPlan();
+  const files = plan.groups.reduce((n, pg) => n + pg.remove.length, 0);
+  if (!confirm(`确定要将 ${files} 个文件移到回收站吗？`)) {
+    return;
+  }
+  $("trash-button").disabled = true;
+  try {
+    const record = await api("POST", `/scans/${state.scanID}/actions`, { plan, executor: "trash" });
+    let message = `✅ 成功移到回收站: ${record.removed.length} 个文件，释放 ${formatSize(record.freed_size)}`;
+    if (record.failed.length > 0) {
+      message += `\n❌ 失败: ${record.failed.length} 个文件\n` +
+        record.failed.slice(0, 10).map((f) => `${f.path}: ${f.error}`).join("\n");
+    }
+    alert(message);
+    $("results").hidden = true;
+  } catch (err) {
+    alert("处理失败: " + err.message);
+    updateSelection();
+  }
+});
+
+renderDirs();
@@ -2,6 +2,7 @@ package fileutil
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -77,15 +78,23 @@ func GetFileType(path string) (string, error) {
 	}
 	defer file.Close()
 
+	return DetectFileType(file)
+}
+
+// FileTypes DetectFileType 可能返回的全部文件类型
+var FileTypes = []string{"image", "video", "audio", "text", "pdf", "archive", "other"}
+
+// DetectFileType 根据 r 开头的内容判断文件类型，结果为 FileTypes 中的一个
+func DetectFileType(r io.Reader) (string, error) {
 	// 读取文件头部字节来判断文件类型
 	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
-	if err != nil {
+	n, err := io.ReadFull(r, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		return "", err
 	}
 
 	// 使用 MIME 类型判断
-	mimeType := http.DetectContentType(buffer)
+	mimeType := http.DetectContentType(buffer[:n])
 	
 	// 简化 MIME 类型
 	switch {
@@ -13,25 +13,29 @@ func ParseSize(size string) (int64, error) {
 		return 0, nil
 	}
 
-	units := map[string]int64{
-		"B":  1,
-		"KB": 1024,
-		"MB": 1024 * 1024,
-		"GB": 1024 * 1024 * 1024,
-		"TB": 1024 * 1024 * 1024 * 1024,
+	// 按后缀长度从长到短匹配，避免 "10MB" 被当作以 "B" 结尾
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KB", 1024},
+		{"MB", 1024 * 1024},
+		{"GB", 1024 * 1024 * 1024},
+		{"TB", 1024 * 1024 * 1024 * 1024},
+		{"B", 1},
 	}
 
 	var value float64
 
-	for suffix, multiplier := range units {
-		if strings.HasSuffix(size, suffix) {
-			numberStr := strings.TrimSuffix(size, suffix)
+	for _, unit := range units {
+		if strings.HasSuffix(size, unit.suffix) {
+			numberStr := strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
 			var err error
 			value, err = strconv.ParseFloat(numberStr, 64)
 			if err != nil {
 				return 0, fmt.Errorf("无效的大小值: %s", size)
 			}
-			return int64(value * float64(multiplier)), nil
+			return int64(value * float64(unit.multiplier)), nil
 		}
 	}
 
@@ -0,0 +1,391 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrKeepMissing 表示计划中要保留的文件已不存在或已被修改，整组都不会被处理
+	ErrKeepMissing = errors.New("要保留的文件已不存在或已被修改")
+	// ErrFileChanged 表示待移除的文件在扫描之后被修改过，该文件不会被处理
+	ErrFileChanged = errors.New("文件在扫描后已被修改")
+	// ErrReferenceFile 表示试图移除参考目录中的文件，参考文件永远保留
+	ErrReferenceFile = errors.New("参考目录中的文件不能被删除")
+	// ErrInvalidPlan 表示计划中同一个文件出现了多次，例如既要保留又要移除，整个计划都不会执行
+	ErrInvalidPlan = errors.New("无效的计划")
+)
+
+// KeepRule 从一组重复文件中选出要保留的文件，返回其在 files 中的下标
+type KeepRule func(files []FileInfo) int
+
+// KeepFirst 保留路径按字典序最小的文件
+func KeepFirst(files []FileInfo) int {
+	return 0
+}
+
+// KeepOldest 保留修改时间最早的文件
+func KeepOldest(files []FileInfo) int {
+	keep := 0
+	for i, file := range files {
+		if file.ModTime.Before(files[keep].ModTime) {
+			keep = i
+		}
+	}
+	return keep
+}
+
+// KeepNewest 保留修改时间最晚的文件
+func KeepNewest(files []FileInfo) int {
+	keep := 0
+	for i, file := range files {
+		if file.ModTime.After(files[keep].ModTime) {
+			keep = i
+		}
+	}
+	return keep
+}
+
+// KeepShortestPath 保留路径最短的文件
+func KeepShortestPath(files []FileInfo) int {
+	keep := 0
+	for i, file := range files {
+		if len(file.Path) < len(files[keep].Path) {
+			keep = i
+		}
+	}
+	return keep
+}
+
+// KeepRuleByName 按名称返回保留规则：first、oldest、newest、shortest
+func KeepRuleByName(name string) (KeepRule, error) {
+	switch strings.ToLower(name) {
+	case "", "first":
+		return KeepFirst, nil
+	case "oldest":
+		return KeepOldest, nil
+	case "newest":
+		return KeepNewest, nil
+	case "shortest":
+		return KeepShortestPath, nil
+	default:
+		return nil, fmt.Errorf("未知的保留规则: %s", name)
+	}
+}
+
+// PlanGroup 一组重复文件的处理方式：保留 Keep，移除 Remove 中的文件
+type PlanGroup struct {
+	Hash   string     `json:"hash"`
+	Size   int64      `json:"size"`
+	Keep   FileInfo   `json:"keep"`
+	Remove []FileInfo `json:"remove"`
+}
+
+// Plan 处理重复文件的执行计划
+type Plan struct {
+	Groups []PlanGroup `json:"groups"`
+}
+
+// NewPlan 按保留规则为扫描结果中的每组重复文件生成执行计划，每组只保留一个文件。
+//
+// 归档内的成员既不会被保留也不会被移除，保留规则只在归档外的文件中选择；
+// 归档外少于两个文件的组不会出现在计划中。
+//
+// 组内有参考目录中的文件时，保留规则在参考文件中选择 Keep，参考目录之外的文件全部移除，
+// 其他参考文件不出现在计划中。使用参考目录扫描时，不含参考文件的组不会出现在计划中。
+func NewPlan(result *Result, rule KeepRule) *Plan {
+	if rule == nil {
+		rule = KeepFirst
+	}
+
+	plan := &Plan{Groups: []PlanGroup{}}
+	for _, group := range result.DuplicateGroups {
+		files := group.Removable()
+		pg := PlanGroup{
+			Hash: group.Hash,
+			Size: group.Size,
+		}
+
+		if refs := group.References(); len(refs) > 0 {
+			if len(files) == 0 {
+				continue
+			}
+			pg.Keep = refs[rule(refs)]
+			pg.Remove = files
+			plan.Groups = append(plan.Groups, pg)
+			continue
+		}
+		if len(result.ReferenceDirs) > 0 || len(files) < 2 {
+			continue
+		}
+
+		keep := rule(files)
+		pg.Keep = files[keep]
+		for i, file := range files {
+			if i != keep {
+				pg.Remove = append(pg.Remove, file)
+			}
+		}
+		plan.Groups = append(plan.Groups, pg)
+	}
+	return plan
+}
+
+// Reclaimable 返回计划完全执行后可释放的字节数
+func (p *Plan) Reclaimable() int64 {
+	var size int64
+	for _, group := range p.Groups {
+		size += group.Size * int64(len(group.Remove))
+	}
+	return size
+}
+
+// Executor 对计划中要移除的文件执行实际操作
+type Executor interface {
+	Remove(file FileInfo) error
+}
+
+// ExecutorFunc 将普通函数适配为 Executor
+type ExecutorFunc func(file FileInfo) error
+
+// Remove 调用 f(file)
+func (f ExecutorFunc) Remove(file FileInfo) error {
+	return f(file)
+}
+
+// TrashExecutor 将文件移动到系统回收站
+type TrashExecutor struct{}
+
+// Remove 将文件移动到回收站
+func (TrashExecutor) Remove(file FileInfo) error {
+	return MoveToTrash(file.Path)
+}
+
+// DeleteExecutor 直接永久删除文件，无法恢复
+type DeleteExecutor struct {
+	// FS 文件所在的文件系统，为 nil 时使用本地磁盘 Local
+	FS RemoveFS
+}
+
+// Remove 删除文件
+func (e DeleteExecutor) Remove(file FileInfo) error {
+	return e.fileSystem().Remove(file.Path)
+}
+
+func (e DeleteExecutor) fileSystem() RemoveFS {
+	if e.FS == nil {
+		return Local
+	}
+	return e.FS
+}
+
+// DryRunExecutor 不做任何修改，用于预览计划的执行结果
+type DryRunExecutor struct{}
+
+// Remove 什么也不做
+func (DryRunExecutor) Remove(file FileInfo) error {
+	return nil
+}
+
+// ActionError 单个文件处理失败的原因
+type ActionError struct {
+	File FileInfo
+	Err  error
+}
+
+func (e *ActionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.File.Path, e.Err)
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyReport 计划的执行结果
+type ApplyReport struct {
+	Removed   []FileInfo
+	Failed    []*ActionError
+	FreedSize int64
+}
+
+// Apply 使用 exec 执行计划。
+//
+// 执行前先检查计划本身：同一个文件（按规范化后的路径或设备号和 inode 判断）在计划中出现多次，
+// 例如移除列表中包含保留的文件，或同一文件出现在多个组中，则不处理任何文件并返回 ErrInvalidPlan。
+//
+// 文件状态默认在本地磁盘上检查；使用 DeleteExecutor 且指定了 FS 时在该文件系统上检查。
+// 执行前会重新检查文件状态以防止误删：保留文件不存在或大小、修改时间与扫描时不同，
+// 则整组跳过并记录 ErrKeepMissing；待移除文件的大小或修改时间发生变化，
+// 则该文件跳过并记录 ErrFileChanged；归档内的成员永远不会被处理，记录 ErrArchiveMember；
+// 参考目录中的文件同样不会被处理，记录 ErrReferenceFile。
+// 待移除文件在磁盘上与保留文件是同一个文件（例如硬链接）时同样记录 ErrFileChanged。
+// 单个文件失败不会中断后续处理。
+// ctx 取消时停止处理剩余文件，已完成的部分记录在返回的报告中。
+func (p *Plan) Apply(ctx context.Context, exec Executor) (*ApplyReport, error) {
+	report := &ApplyReport{}
+
+	var fsys FileSystem = Local
+	if e, ok := exec.(interface{ fileSystem() RemoveFS }); ok {
+		fsys = e.fileSystem()
+	}
+	if err := p.check(fsys); err != nil {
+		return report, err
+	}
+
+	for _, group := range p.Groups {
+		if !unchanged(fsys, group.Keep) {
+			for _, file := range group.Remove {
+				report.Failed = append(report.Failed, &ActionError{File: file, Err: ErrKeepMissing})
+			}
+			continue
+		}
+		keep, _ := fsys.Stat(group.Keep.Path)
+
+		for _, file := range group.Remove {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if file.InArchive() {
+				report.Failed = append(report.Failed, &ActionError{File: file, Err: ErrArchiveMember})
+				continue
+			}
+			if file.Reference {
+				report.Failed = append(report.Failed, &ActionError{File: file, Err: ErrReferenceFile})
+				continue
+			}
+			if !unchanged(fsys, file) || sameFile(fsys, keep, file) {
+				report.Failed = append(report.Failed, &ActionError{File: file, Err: ErrFileChanged})
+				continue
+			}
+			if err := exec.Remove(file); err != nil {
+				report.Failed = append(report.Failed, &ActionError{File: file, Err: err})
+				continue
+			}
+			report.Removed = append(report.Removed, file)
+			report.FreedSize += file.Size
+		}
+	}
+
+	return report, nil
+}
+
+// unchanged 判断文件是否仍然存在且大小和修改时间与扫描时一致
+func unchanged(fsys FileSystem, file FileInfo) bool {
+	info, err := fsys.Stat(file.Path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Size() == file.Size && info.ModTime().Equal(file.ModTime)
+}
+
+// sameFile 判断 file 在磁盘上是否与已 Stat 过的保留文件是同一个文件
+func sameFile(fsys FileSystem, keep os.FileInfo, file FileInfo) bool {
+	info, err := fsys.Stat(file.Path)
+	return err == nil && keep != nil && os.SameFile(keep, info)
+}
+
+// check 检查计划中的每个文件只出现一次。路径在本地磁盘上按绝对路径比较，
+// 记录了 inode 的文件还按设备号和 inode 比较，因此同一文件换一种写法也能被发现。
+func (p *Plan) check(fsys FileSystem) error {
+	seen := make(map[string]string)
+	add := func(file FileInfo) error {
+		keys := []string{"path:" + fileKey(fsys, FileInfo{Path: file.Path})}
+		if file.Inode != 0 && !file.InArchive() {
+			keys = append(keys, "inode:"+fileKey(fsys, file))
+		}
+		for _, key := range keys {
+			if prev, ok := seen[key]; ok {
+				return fmt.Errorf("%w: %s 与 %s 是同一个文件", ErrInvalidPlan, file.Path, prev)
+			}
+			seen[key] = file.Path
+		}
+		return nil
+	}
+
+	for _, group := range p.Groups {
+		if err := add(group.Keep); err != nil {
+			return err
+		}
+		for _, file := range group.Remove {
+			if err := add(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckContent 返回包装了 exec 的执行器：移除文件之前重新计算该文件和同组保留文件的哈希值，
+// 与计划中记录的不一致时不移除，分别返回 ErrFileChanged 和 ErrKeepMissing。
+// 哈希算法按记录的哈希值长度判断。
+//
+// 大小和修改时间不变时内容仍可能被改过，从文件读入的计划也可能被手工编辑，
+// 执行这类计划时应使用 CheckContent。每组保留文件的哈希值只计算一次。
+func (p *Plan) CheckContent(exec Executor) Executor {
+	groups := make(map[string]PlanGroup)
+	for _, group := range p.Groups {
+		for _, file := range group.Remove {
+			groups[file.Path] = group
+		}
+	}
+	return &contentExecutor{exec: exec, groups: groups, kept: make(map[string]error)}
+}
+
+// contentExecutor 在移除文件之前校验文件内容，见 Plan.CheckContent
+type contentExecutor struct {
+	exec   Executor
+	groups map[string]PlanGroup
+	kept   map[string]error
+}
+
+func (e *contentExecutor) Remove(file FileInfo) error {
+	group, ok := e.groups[file.Path]
+	if !ok {
+		return ErrFileChanged
+	}
+	fsys := e.fileSystem()
+
+	err, ok := e.kept[group.Keep.Path]
+	if !ok {
+		err = checkHash(fsys, group.Keep.Path, group.Hash, ErrKeepMissing)
+		e.kept[group.Keep.Path] = err
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkHash(fsys, file.Path, group.Hash, ErrFileChanged); err != nil {
+		return err
+	}
+	return e.exec.Remove(file)
+}
+
+func (e *contentExecutor) fileSystem() RemoveFS {
+	if inner, ok := e.exec.(interface{ fileSystem() RemoveFS }); ok {
+		return inner.fileSystem()
+	}
+	return Local
+}
+
+// checkHash 计算文件的哈希值并与 sum 比较，无法读取或不一致时返回 changed
+func checkHash(fsys FileSystem, path, sum string, changed error) error {
+	hasher, err := hasherFor(sum)
+	if err != nil {
+		return err
+	}
+	f, err := fsys.Open(path)
+	if err != nil {
+		return changed
+	}
+	defer f.Close()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return changed
+	}
+	if fmt.Sprintf("%x", hasher.Sum(nil)) != sum {
+		return changed
+	}
+	return nil
+}
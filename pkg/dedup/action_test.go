@@ -0,0 +1,175 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func planPaths(pg PlanGroup) (keep string, remove []string) {
+	for _, file := range pg.Remove {
+		remove = append(remove, file.Path)
+	}
+	return pg.Keep.Path, remove
+}
+
+func TestNewPlanKeepRule(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/long.txt": {Data: []byte("same")},
+		"s.txt":        {Data: []byte("same")},
+		"c/mid.txt":    {Data: []byte("same")},
+	}
+	result := scan(t, FromFS(fsys), nil, ".")
+	plan := NewPlan(result, KeepShortestPath)
+	if len(plan.Groups) != 1 {
+		t.Fatalf("Groups = %+v, want 1", plan.Groups)
+	}
+	keep, remove := planPaths(plan.Groups[0])
+	if keep != "s.txt" || !equalStrings(remove, []string{"a/b/long.txt", "c/mid.txt"}) {
+		t.Errorf("keep %s, remove %v", keep, remove)
+	}
+	if got := plan.Reclaimable(); got != 8 {
+		t.Errorf("Reclaimable = %d, want 8", got)
+	}
+}
+
+func TestNewPlanReferenceDirs(t *testing.T) {
+	fsys := fstest.MapFS{
+		// 参考目录中的两个副本都保留，只有一个作为 Keep 出现在计划中
+		"ref/a.txt":   {Data: []byte("photo")},
+		"ref/z/a.txt": {Data: []byte("photo")},
+		"x/a.txt":     {Data: []byte("photo")},
+		"y/a.txt":     {Data: []byte("photo")},
+		// 没有参考文件的组不处理
+		"x/b.txt": {Data: []byte("other")},
+		"y/b.txt": {Data: []byte("other")},
+		// 只在参考目录中重复的组没有可移除的文件
+		"ref/c.txt":   {Data: []byte("refonly")},
+		"ref/z/c.txt": {Data: []byte("refonly")},
+	}
+	// 参考目录不在扫描根目录之下时也会被扫描
+	result := scan(t, FromFS(fsys), []Option{WithReferenceDirs("ref")}, "x", "y")
+	if len(result.DuplicateGroups) != 3 {
+		t.Fatalf("DuplicateGroups = %+v, want 3", result.DuplicateGroups)
+	}
+
+	plan := NewPlan(result, KeepFirst)
+	if len(plan.Groups) != 1 {
+		t.Fatalf("Groups = %+v, want 1", plan.Groups)
+	}
+	keep, remove := planPaths(plan.Groups[0])
+	if keep != "ref/a.txt" || !equalStrings(remove, []string{"x/a.txt", "y/a.txt"}) {
+		t.Errorf("keep %s, remove %v", keep, remove)
+	}
+	if !plan.Groups[0].Keep.Reference {
+		t.Error("Keep.Reference = false, want true")
+	}
+	for _, file := range plan.Groups[0].Remove {
+		if file.Reference {
+			t.Errorf("参考文件 %s 出现在移除列表中", file.Path)
+		}
+	}
+}
+
+func TestApplyRejectsRepeatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result := scan(t, Local, nil, dir)
+	if len(result.DuplicateGroups) != 1 {
+		t.Fatalf("DuplicateGroups = %+v, want 1", result.DuplicateGroups)
+	}
+	files := result.DuplicateGroups[0].Files
+	a, b, c := files[0], files[1], files[2]
+	// 换一种写法的同一路径，不带 inode 时只能按规范化后的路径发现
+	aliasA := a
+	aliasA.Path = dir + string(filepath.Separator) + "." + string(filepath.Separator) + "a.txt"
+	aliasA.Inode = 0
+
+	tests := []struct {
+		name   string
+		groups []PlanGroup
+	}{
+		{"keep in remove", []PlanGroup{{Keep: a, Remove: []FileInfo{b, a}}}},
+		{"keep alias in remove", []PlanGroup{{Keep: a, Remove: []FileInfo{aliasA}}}},
+		{"remove twice", []PlanGroup{{Keep: a, Remove: []FileInfo{b, b}}}},
+		{"across groups", []PlanGroup{{Keep: a, Remove: []FileInfo{b}}, {Keep: c, Remove: []FileInfo{b}}}},
+		{"keep removed by another group", []PlanGroup{{Keep: a, Remove: []FileInfo{b}}, {Keep: c, Remove: []FileInfo{a}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{Groups: tt.groups}
+			var removed []string
+			report, err := plan.Apply(context.Background(), ExecutorFunc(func(file FileInfo) error {
+				removed = append(removed, file.Path)
+				return nil
+			}))
+			if !errors.Is(err, ErrInvalidPlan) {
+				t.Errorf("Apply error = %v, want ErrInvalidPlan", err)
+			}
+			if len(removed) != 0 || report == nil || len(report.Removed) != 0 {
+				t.Errorf("removed %v, want none", removed)
+			}
+		})
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		info, statErr := os.Stat(path)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// 内容改变但大小和修改时间不变，只有重新计算哈希值才能发现
+		if statErr == nil {
+			if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, name := range []string{"keep1", "same1", "edited1", "keep2", "other2"} {
+		write(name, "same")
+	}
+	result := scan(t, Local, nil, dir)
+	group := result.DuplicateGroups[0]
+	byName := make(map[string]FileInfo)
+	for _, file := range group.Files {
+		byName[filepath.Base(file.Path)] = file
+	}
+	plan := &Plan{Groups: []PlanGroup{
+		{Hash: group.Hash, Size: group.Size, Keep: byName["keep1"], Remove: []FileInfo{byName["same1"], byName["edited1"]}},
+		{Hash: group.Hash, Size: group.Size, Keep: byName["keep2"], Remove: []FileInfo{byName["other2"]}},
+	}}
+	write("edited1", "diff")
+	write("keep2", "diff")
+
+	var removed []string
+	report, err := plan.Apply(context.Background(), plan.CheckContent(ExecutorFunc(func(file FileInfo) error {
+		removed = append(removed, filepath.Base(file.Path))
+		return nil
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(removed, []string{"same1"}) {
+		t.Errorf("removed %v, want [same1]", removed)
+	}
+	want := map[string]error{"edited1": ErrFileChanged, "other2": ErrKeepMissing}
+	if len(report.Failed) != len(want) {
+		t.Fatalf("Failed = %v, want %d", report.Failed, len(want))
+	}
+	for _, f := range report.Failed {
+		if name := filepath.Base(f.File.Path); !errors.Is(f.Err, want[name]) {
+			t.Errorf("%s: %v, want %v", name, f.Err, want[name])
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package dedup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/xiaozhe/dedupgo/internal/utils/fileutil"
+)
+
+// ArchiveSeparator 归档成员虚拟路径中归档路径与成员路径之间的分隔符，
+// 例如 backup.zip!/photos/a.jpg
+const ArchiveSeparator = "!/"
+
+// ErrArchiveMember 表示试图修改归档内的成员，归档成员只用于报告
+var ErrArchiveMember = errors.New("归档内的文件只用于报告，不能被修改")
+
+// errMemberSize 归档成员解压后的实际大小超过了记录的大小
+var errMemberSize = errors.New("归档成员的实际大小与记录不符")
+
+// maxMemberSize 归档成员解压后的大小上限，记录的大小超过上限的成员不参与扫描，
+// 防止解压炸弹占用大量时间
+const maxMemberSize = 4 << 30
+
+// 支持的归档格式
+const (
+	formatZip    = "zip"
+	formatTar    = "tar"
+	formatTarGz  = "tar.gz"
+	formatTarBz2 = "tar.bz2"
+)
+
+// archiveFormat 根据扩展名判断归档格式，不是支持的归档时返回空字符串
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return formatZip
+	case strings.HasSuffix(lower, ".tar"):
+		return formatTar
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return formatTarGz
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"), strings.HasSuffix(lower, ".tbz"):
+		return formatTarBz2
+	default:
+		return ""
+	}
+}
+
+// SplitArchivePath 将归档成员的虚拟路径拆分为归档路径和成员路径
+func SplitArchivePath(p string) (archive, member string, ok bool) {
+	i := strings.Index(p, ArchiveSeparator)
+	if i < 0 {
+		return p, "", false
+	}
+	return p[:i], p[i+len(ArchiveSeparator):], true
+}
+
+// collectArchive 列出归档中的成员并交给 add。
+//
+// tar 只能顺序读取，因此遍历时顺带计算所有成员的哈希；
+// zip 支持随机访问，成员在需要时才由 hashZipMember 单独读取。
+// 两者都通过 hashMember 计算，与普通文件一样受限速控制，读取量以记录的大小为限。
+// 无法解析的归档会被忽略，嵌套的归档不会被展开。
+func (r *scanRun) collectArchive(root, archivePath string, info fs.FileInfo, add func(FileInfo)) {
+	member := func(name string, fi fs.FileInfo) (FileInfo, bool) {
+		name = path.Clean(strings.TrimPrefix(name, "/"))
+		if !fi.Mode().IsRegular() || fi.Size() < r.opts.MinSize || fi.Size() > maxMemberSize ||
+			!fs.ValidPath(name) || r.memberExcluded(name) {
+			return FileInfo{}, false
+		}
+		return FileInfo{
+			Path:    archivePath + ArchiveSeparator + name,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Mode:    fi.Mode(),
+			UID:     -1,
+			Root:    root,
+			Archive: archivePath,
+		}, true
+	}
+
+	format := archiveFormat(archivePath)
+	if format == formatZip {
+		zr, closer, err := r.openZip(archivePath, info)
+		if err != nil {
+			return
+		}
+		defer closer.Close()
+
+		for _, zf := range zr.File {
+			file, ok := member(zf.Name, zf.FileInfo())
+			if !ok {
+				continue
+			}
+			if len(r.opts.FileTypes) > 0 {
+				fileType, err := detectZipMemberType(zf)
+				if err != nil || !containsFold(r.opts.FileTypes, fileType) {
+					continue
+				}
+				file.FileType = fileType
+			}
+			add(file)
+		}
+		return
+	}
+
+	f, err := r.fs.Open(archivePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// 限速按解压后的成员内容计算，与 zip 成员和普通文件相同，这里只响应取消
+	var reader io.Reader = &contextReader{ctx: r.ctx, r: f}
+	switch format {
+	case formatTarGz:
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return
+		}
+		defer gz.Close()
+		reader = gz
+	case formatTarBz2:
+		reader = bzip2.NewReader(reader)
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return
+		}
+		file, ok := member(hdr.Name, hdr.FileInfo())
+		if !ok {
+			continue
+		}
+
+		br := bufio.NewReader(tr)
+		if len(r.opts.FileTypes) > 0 {
+			head, _ := br.Peek(512)
+			fileType, err := fileutil.DetectFileType(bytes.NewReader(head))
+			if err != nil || !containsFold(r.opts.FileTypes, fileType) {
+				continue
+			}
+			file.FileType = fileType
+		}
+
+		r.fileLimiter.wait(1)
+		r.progress.hashing(file)
+		hash, err := r.hashMember(file, br)
+		if err != nil {
+			return
+		}
+		file.Hash = hash
+		add(file)
+	}
+}
+
+// openZip 打开 zip 归档。文件不支持随机读取时（部分 fs.FS 实现）先整体读入内存。
+func (r *scanRun) openZip(archivePath string, info fs.FileInfo) (*zip.Reader, io.Closer, error) {
+	f, err := r.fs.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readerAt, ok := f.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		readerAt = bytes.NewReader(data)
+	}
+
+	zr, err := zip.NewReader(readerAt, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return zr, f, nil
+}
+
+// hashMember 计算归档成员的哈希值。最多读取记录的大小加一个字节，
+// 实际内容更长时返回 errMemberSize，记录的大小不可信时也不会无限读取。
+func (r *scanRun) hashMember(file FileInfo, rd io.Reader) (string, error) {
+	lr := &io.LimitedReader{R: rd, N: file.Size + 1}
+	hash, err := r.hashReader(lr)
+	if err == nil && lr.N == 0 {
+		return "", errMemberSize
+	}
+	return hash, err
+}
+
+// hashZipMember 计算 zip 归档中单个成员的哈希值
+func (r *scanRun) hashZipMember(file FileInfo) (string, error) {
+	archivePath := file.Archive
+	name := strings.TrimPrefix(file.Path, archivePath+ArchiveSeparator)
+
+	zr, release, err := r.zipReader(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	rc, err := zr.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	return r.hashMember(file, rc)
+}
+
+// zipReader 返回归档的 zip.Reader，用完后调用 release。
+// 哈希阶段同一归档的所有成员共用一个 Reader（见 zipCache），其他情况下每次单独打开。
+func (r *scanRun) zipReader(archivePath string) (*zip.Reader, func(), error) {
+	if e := r.zips.entry(archivePath); e != nil {
+		e.once.Do(func() {
+			e.zr, e.closer, e.err = r.openZipPath(archivePath)
+		})
+		return e.zr, func() { r.zips.release(archivePath, e) }, e.err
+	}
+	zr, closer, err := r.openZipPath(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zr, func() { closer.Close() }, nil
+}
+
+func (r *scanRun) openZipPath(archivePath string) (*zip.Reader, io.Closer, error) {
+	info, err := r.fs.Stat(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.openZip(archivePath, info)
+}
+
+// zipCache 哈希阶段打开的 zip 归档。按桶分批计算哈希时同一归档的成员分散在多个桶中，
+// 逐个打开归档需要反复解析中央目录，成员多时耗时与成员数的平方成正比。
+// 每个归档只打开一次，最后一个待计算的成员完成后关闭。
+type zipCache struct {
+	mu      sync.Mutex
+	entries map[string]*zipEntry
+}
+
+type zipEntry struct {
+	once      sync.Once
+	zr        *zip.Reader
+	closer    io.Closer
+	err       error
+	remaining int
+}
+
+func newZipCache() *zipCache {
+	return &zipCache{entries: make(map[string]*zipEntry)}
+}
+
+// add 记录一个需要计算哈希的文件，只统计哈希尚未算出的归档成员（即 zip 成员）
+func (c *zipCache) add(file FileInfo) {
+	if file.Archive == "" || file.Hash != "" {
+		return
+	}
+	e, ok := c.entries[file.Archive]
+	if !ok {
+		e = &zipEntry{}
+		c.entries[file.Archive] = e
+	}
+	e.remaining++
+}
+
+// entry 返回归档的缓存项，c 为 nil 或归档不在缓存中时返回 nil
+func (c *zipCache) entry(archivePath string) *zipEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[archivePath]
+}
+
+// release 记录一个成员已计算完成，归档的所有成员都完成后关闭归档
+func (c *zipCache) release(archivePath string, e *zipEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.remaining--
+	if e.remaining > 0 {
+		return
+	}
+	if e.closer != nil {
+		e.closer.Close()
+	}
+	delete(c.entries, archivePath)
+}
+
+// close 关闭仍然打开的归档，扫描被取消时部分成员不会被计算
+func (c *zipCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for archivePath, e := range c.entries {
+		// 等待可能仍在进行的打开操作完成
+		e.once.Do(func() {})
+		if e.closer != nil {
+			e.closer.Close()
+		}
+		delete(c.entries, archivePath)
+	}
+}
+
+// detectZipMemberType 根据 zip 成员开头的内容识别文件类型
+func detectZipMemberType(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return fileutil.DetectFileType(rc)
+}
+
+// memberExcluded 判断归档成员路径中是否有任意一级匹配排除模式
+func (r *scanRun) memberExcluded(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		for _, pattern := range r.opts.ExcludePatterns {
+			if matched, _ := path.Match(pattern, elem); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package dedup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func zipData(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarData(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(data))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScanArchiveMembers(t *testing.T) {
+	fsys := fstest.MapFS{
+		"photos/a.jpg":   {Data: []byte("zip member")},
+		"docs/b.txt":     {Data: []byte("tar member")},
+		"old/backup.zip": {Data: zipData(t, map[string]string{"photos/a.jpg": "zip member", "/abs.txt": "only here"})},
+		"old/docs.tar":   {Data: tarData(t, map[string]string{"./docs/b.txt": "tar member"})},
+	}
+	result := scan(t, FromFS(fsys), []Option{WithArchives(true)}, ".")
+
+	want := map[string][]string{
+		"photos/a.jpg": {"old/backup.zip!/photos/a.jpg", "photos/a.jpg"},
+		"docs/b.txt":   {"docs/b.txt", "old/docs.tar!/docs/b.txt"},
+	}
+	if len(result.DuplicateGroups) != len(want) {
+		t.Fatalf("DuplicateGroups = %+v, want %d groups", result.DuplicateGroups, len(want))
+	}
+	for _, group := range result.DuplicateGroups {
+		var loose string
+		for _, file := range group.Files {
+			archive, member, ok := SplitArchivePath(file.Path)
+			if ok != file.InArchive() || (ok && (archive != file.Archive || member == "")) {
+				t.Errorf("SplitArchivePath(%q) = %q, %q, %v; Archive = %q", file.Path, archive, member, ok, file.Archive)
+			}
+			if !ok {
+				loose = file.Path
+			}
+		}
+		if got := groupPaths(group); !equalStrings(got, want[loose]) {
+			t.Errorf("group = %v, want %v", got, want[loose])
+		}
+	}
+
+	// 归档成员只用于报告，组内只有一个归档外的文件时没有可处理的内容
+	if plan := NewPlan(result, KeepFirst); len(plan.Groups) != 0 {
+		t.Errorf("plan = %+v, want no groups", plan.Groups)
+	}
+}
+
+// countingFS 记录每个文件被打开的次数
+type countingFS struct {
+	fstest.MapFS
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mu.Lock()
+	c.opens[name]++
+	c.mu.Unlock()
+	return c.MapFS.Open(name)
+}
+
+func TestScanZipOpenedOnce(t *testing.T) {
+	// 成员大小各不相同，分散在多个桶中
+	members := make(map[string]string)
+	fsys := fstest.MapFS{}
+	for i := 1; i <= 30; i++ {
+		data := strings.Repeat("x", i)
+		members[fmt.Sprintf("m%d.txt", i)] = data
+		fsys[fmt.Sprintf("loose/m%d.txt", i)] = &fstest.MapFile{Data: []byte(data)}
+	}
+	fsys["backup.zip"] = &fstest.MapFile{Data: zipData(t, members)}
+	cfs := &countingFS{MapFS: fsys, opens: make(map[string]int)}
+
+	result := scan(t, FromFS(cfs), []Option{WithArchives(true)}, ".")
+	if len(result.DuplicateGroups) != 30 {
+		t.Fatalf("DuplicateGroups = %d, want 30", len(result.DuplicateGroups))
+	}
+	// 遍历时打开一次，哈希阶段所有成员共用一次
+	if n := cfs.opens["backup.zip"]; n != 2 {
+		t.Errorf("backup.zip opened %d times, want 2", n)
+	}
+}
+
+func TestArchiveMemberSizeLimit(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "huge", Mode: 0o644, Size: maxMemberSize + 1})
+	fsys := fstest.MapFS{"huge.tar": {Data: buf.Bytes()}}
+	scanner, err := New(WithFS(FromFS(fsys)), WithArchives(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := scanner.newRun(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 记录的大小超过上限的成员不参与扫描
+	info, _ := fsys.Stat("huge.tar")
+	run.collectArchive(".", "huge.tar", info, func(file FileInfo) {
+		t.Errorf("added %s, want none", file.Path)
+	})
+
+	// 实际内容比记录的大小长时不会读完，返回错误
+	file := FileInfo{Path: "a.zip!/bomb", Size: 4, Archive: "a.zip"}
+	if _, err := run.hashMember(file, strings.NewReader(strings.Repeat("x", 1<<20))); !errors.Is(err, errMemberSize) {
+		t.Errorf("hashMember(longer) error = %v, want errMemberSize", err)
+	}
+	if _, err := run.hashMember(file, strings.NewReader("xxxx")); err != nil {
+		t.Errorf("hashMember(exact) error = %v", err)
+	}
+}
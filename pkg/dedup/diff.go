@@ -0,0 +1,199 @@
+package dedup
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DiffStat 一类比较结果的文件数与总大小
+type DiffStat struct {
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+// DiffMatch 两棵目录树中内容相同的一份文件，不要求文件名或位置相同，A、B 按路径排序
+type DiffMatch struct {
+	Hash string     `json:"hash"`
+	Size int64      `json:"size"`
+	A    []FileInfo `json:"a"`
+	B    []FileInfo `json:"b"`
+}
+
+// DiffSummary 比较结果的汇总。Both 按内容计数，每份相同的内容只计算一次。
+type DiffSummary struct {
+	OnlyA DiffStat `json:"only_a"`
+	OnlyB DiffStat `json:"only_b"`
+	Both  DiffStat `json:"both"`
+}
+
+// DiffResult 按内容比较两棵目录树的结果
+type DiffResult struct {
+	SchemaVersion int    `json:"schema_version"`
+	Algorithm     string `json:"algorithm"`
+	A             string `json:"a"`
+	B             string `json:"b"`
+	// OnlyA 在 B 中找不到相同内容的 A 中文件，按路径排序
+	OnlyA []FileInfo `json:"only_a"`
+	// OnlyB 在 A 中找不到相同内容的 B 中文件，按路径排序
+	OnlyB []FileInfo `json:"only_b"`
+	// Both 两边都有的内容，按大小从大到小排序
+	Both    []DiffMatch `json:"both"`
+	Summary DiffSummary `json:"summary"`
+}
+
+// Diff 按内容比较目录树 a 和 b，找出只在一边存在的文件以及两边都有的内容。
+//
+// 大小只在一边出现的文件不需要读取即可确定为独有，其余文件计算哈希后比较。
+// 过滤条件（最小大小、排除模式、文件类型、归档）与 Scan 相同，参考目录不参与比较。
+// 无法读取的文件保守地视为独有，Hash 为空。
+func (s *Scanner) Diff(ctx context.Context, a, b string) (*DiffResult, error) {
+	run, err := s.newRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sides := [2]map[int64][]FileInfo{make(map[int64][]FileInfo), make(map[int64][]FileInfo)}
+	for i, root := range []string{a, b} {
+		side := sides[i]
+		err := run.walk([]string{root}, func(file FileInfo) {
+			side[file.Size] = append(side[file.Size], file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 只有两边都出现的大小才需要计算哈希
+	var pending []*FileInfo
+	for size, filesA := range sides[0] {
+		filesB, ok := sides[1][size]
+		if !ok {
+			continue
+		}
+		for i := range filesA {
+			pending = append(pending, &filesA[i])
+		}
+		for i := range filesB {
+			pending = append(pending, &filesB[i])
+		}
+	}
+	if err := run.hashAll(pending); err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{
+		SchemaVersion: ResultSchemaVersion,
+		Algorithm:     s.opts.HashAlgorithm,
+		A:             a,
+		B:             b,
+		OnlyA:         []FileInfo{},
+		OnlyB:         []FileInfo{},
+		Both:          []DiffMatch{},
+	}
+	for size, filesA := range sides[0] {
+		byHash := make(map[string][]FileInfo)
+		for _, file := range sides[1][size] {
+			if file.Hash != "" {
+				byHash[file.Hash] = append(byHash[file.Hash], file)
+			}
+		}
+
+		matched := make(map[string]*DiffMatch)
+		for _, file := range filesA {
+			filesB := byHash[file.Hash]
+			if file.Hash == "" || len(filesB) == 0 {
+				result.OnlyA = append(result.OnlyA, file)
+				continue
+			}
+			m, ok := matched[file.Hash]
+			if !ok {
+				m = &DiffMatch{Hash: file.Hash, Size: size}
+				for _, fb := range filesB {
+					fb.Hash = ""
+					m.B = append(m.B, fb)
+				}
+				matched[file.Hash] = m
+			}
+			file.Hash = ""
+			m.A = append(m.A, file)
+		}
+		for _, m := range matched {
+			result.Both = append(result.Both, *m)
+		}
+		for _, file := range sides[1][size] {
+			if file.Hash == "" || matched[file.Hash] == nil {
+				result.OnlyB = append(result.OnlyB, file)
+			}
+		}
+	}
+	for size, filesB := range sides[1] {
+		if _, ok := sides[0][size]; !ok {
+			result.OnlyB = append(result.OnlyB, filesB...)
+		}
+	}
+
+	sort.Slice(result.OnlyA, func(i, j int) bool { return result.OnlyA[i].Path < result.OnlyA[j].Path })
+	sort.Slice(result.OnlyB, func(i, j int) bool { return result.OnlyB[i].Path < result.OnlyB[j].Path })
+	for _, m := range result.Both {
+		sort.Slice(m.A, func(i, j int) bool { return m.A[i].Path < m.A[j].Path })
+		sort.Slice(m.B, func(i, j int) bool { return m.B[i].Path < m.B[j].Path })
+	}
+	sort.Slice(result.Both, func(i, j int) bool {
+		if result.Both[i].Size != result.Both[j].Size {
+			return result.Both[i].Size > result.Both[j].Size
+		}
+		return result.Both[i].Hash < result.Both[j].Hash
+	})
+
+	for _, file := range result.OnlyA {
+		result.Summary.OnlyA.Files++
+		result.Summary.OnlyA.Size += file.Size
+	}
+	for _, file := range result.OnlyB {
+		result.Summary.OnlyB.Files++
+		result.Summary.OnlyB.Size += file.Size
+	}
+	for _, m := range result.Both {
+		result.Summary.Both.Files++
+		result.Summary.Both.Size += m.Size
+	}
+	return result, nil
+}
+
+// hashAll 使用工作协程计算所有文件的哈希并写入 Hash 字段，读取失败的文件 Hash 保持为空
+func (r *scanRun) hashAll(files []*FileInfo) error {
+	r.zips = newZipCache()
+	for _, file := range files {
+		r.zips.add(*file)
+	}
+	defer func() {
+		r.zips.close()
+		r.zips = nil
+	}()
+
+	jobs := make(chan *FileInfo)
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				hash, err := r.hashFile(*file)
+				if err == nil {
+					file.Hash = hash
+				}
+			}
+		}()
+	}
+
+	for _, file := range files {
+		if r.ctx.Err() != nil {
+			break
+		}
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+	return r.ctx.Err()
+}
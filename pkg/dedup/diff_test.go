@@ -0,0 +1,92 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func filePaths(files []FileInfo) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func TestDiff(t *testing.T) {
+	fsys := fstest.MapFS{
+		// 移动并改名的文件两边都有
+		"old/keep.txt":          {Data: []byte("same")},
+		"new/moved/renamed.txt": {Data: []byte("same")},
+		// 删除和新增的文件大小相同，内容不同
+		"old/removed.txt": {Data: []byte("gone!")},
+		"new/added.txt":   {Data: []byte("added")},
+		// 同名文件内容变化，两边各算一个独有文件
+		"old/changed.txt": {Data: []byte("v1")},
+		"new/changed.txt": {Data: []byte("v2")},
+		// A 中的两个副本对应 B 中的一个文件
+		"old/dup1": {Data: []byte("dup")},
+		"old/dup2": {Data: []byte("dup")},
+		"new/dup":  {Data: []byte("dup")},
+		// 大小只在一边出现的文件不需要读取
+		"new/big.bin": {Data: []byte("only in new")},
+	}
+	scanner, err := New(WithFS(FromFS(fsys)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Diff(context.Background(), "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := filePaths(result.OnlyA), []string{"old/changed.txt", "old/removed.txt"}; !equalStrings(got, want) {
+		t.Errorf("OnlyA = %v, want %v", got, want)
+	}
+	if got, want := filePaths(result.OnlyB), []string{"new/added.txt", "new/big.bin", "new/changed.txt"}; !equalStrings(got, want) {
+		t.Errorf("OnlyB = %v, want %v", got, want)
+	}
+
+	if len(result.Both) != 2 {
+		t.Fatalf("Both = %+v, want 2 matches", result.Both)
+	}
+	// 按大小从大到小排序
+	same, dup := result.Both[0], result.Both[1]
+	if !equalStrings(filePaths(same.A), []string{"old/keep.txt"}) || !equalStrings(filePaths(same.B), []string{"new/moved/renamed.txt"}) {
+		t.Errorf("Both[0] = %+v, want keep.txt and moved/renamed.txt", same)
+	}
+	if !equalStrings(filePaths(dup.A), []string{"old/dup1", "old/dup2"}) || !equalStrings(filePaths(dup.B), []string{"new/dup"}) {
+		t.Errorf("Both[1] = %+v, want dup1, dup2 and dup", dup)
+	}
+	if same.Hash == "" || same.A[0].Hash != "" {
+		t.Errorf("Both[0] Hash = %q, file Hash = %q, want hash only on the match", same.Hash, same.A[0].Hash)
+	}
+
+	want := DiffSummary{
+		OnlyA: DiffStat{Files: 2, Size: 7},
+		OnlyB: DiffStat{Files: 3, Size: 18},
+		Both:  DiffStat{Files: 2, Size: 7},
+	}
+	if result.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", result.Summary, want)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x": {Data: []byte("x")},
+		"b/y": {Data: []byte("x")},
+	}
+	scanner, err := New(WithFS(FromFS(fsys)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Diff(context.Background(), "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.OnlyA) != 0 || len(result.OnlyB) != 0 || len(result.Both) != 1 {
+		t.Errorf("Diff = %+v, want one match and nothing unique", result)
+	}
+}
@@ -0,0 +1,374 @@
+package dedup
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// DirGroup 一组内容完全相同的目录：文件名、目录结构和文件内容都相同
+type DirGroup struct {
+	// Digest 目录的 Merkle 摘要，由子文件的名称与内容哈希、子目录的名称与摘要计算得到
+	Digest string `json:"digest"`
+	// Files 每个目录中（递归）的文件数
+	Files int `json:"files"`
+	// Size 每个目录中（递归）文件的总大小
+	Size int64    `json:"size"`
+	Dirs []string `json:"dirs"`
+}
+
+// Reclaimable 返回每组只保留一个目录时可释放的字节数
+func (g DirGroup) Reclaimable() int64 {
+	return g.Size * int64(len(g.Dirs)-1)
+}
+
+// DirSubset 表示 Subset 目录中的每个文件在 Superset 目录中都有内容相同的副本，
+// 不要求文件名或目录结构相同
+type DirSubset struct {
+	Subset   string `json:"subset"`
+	Superset string `json:"superset"`
+	// Files 与 Size 为 Subset 目录中（递归）的文件数和总大小
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+// dirNode 目录树中的一个目录，只包含参与扫描的文件
+type dirNode struct {
+	path     string
+	name     string
+	parent   *dirNode
+	children []*dirNode
+	files    []dirFile
+
+	digest string
+	count  int   // 递归文件数
+	size   int64 // 递归文件总大小
+	allDup bool  // 递归所有文件在其他位置都有副本
+	// partial 目录中直接包含被排除或过滤掉的条目（排除的文件和目录、小于最小大小的文件、
+	// 类型不符的文件、符号链接等），这些内容没有参与比较
+	partial bool
+}
+
+type dirFile struct {
+	name string
+	key  string // 内容标识：重复文件为其哈希，没有副本的文件为唯一标记
+	size int64
+	dup  bool
+}
+
+// dirIndex 由扫描到的文件构建的目录树
+type dirIndex struct {
+	fsys  FileSystem
+	nodes map[string]*dirNode
+	// owners 每个内容标识直接位于哪些目录中
+	owners map[string][]*dirNode
+}
+
+// analyzeDirs 找出内容完全相同的目录，以及内容被另一目录完全包含的目录。
+//
+// 只比较参与扫描的文件（满足最小大小且未被排除），不在任何重复组中的文件
+// 视为没有副本。skipped 为遍历时被排除或过滤掉的条目，（递归）包含这些条目的目录
+// 内容不完整，不会被报告为相同的目录或被包含的目录，但仍可作为包含其他目录的一方。
+// 被上层目录的结果覆盖的子目录不会重复报告。
+func analyzeDirs(fsys FileSystem, files, skipped []FileInfo, exact map[string]string) ([]DirGroup, []DirSubset) {
+	idx := &dirIndex{
+		fsys:   fsys,
+		nodes:  make(map[string]*dirNode),
+		owners: make(map[string][]*dirNode),
+	}
+	for _, file := range files {
+		idx.addFile(file, exact)
+	}
+	for _, entry := range skipped {
+		idx.addSkipped(entry)
+	}
+
+	var roots []*dirNode
+	for _, node := range idx.nodes {
+		if node.parent == nil {
+			roots = append(roots, node)
+		}
+	}
+	for _, root := range roots {
+		root.computeDigest()
+	}
+
+	reported := make(map[*dirNode]bool)
+	groups := idx.identicalDirs(reported)
+	subsets := idx.dirSubsets(reported)
+	return groups, subsets
+}
+
+// addFile 将文件加入所在目录，必要时创建直到扫描根目录的各级目录
+func (idx *dirIndex) addFile(file FileInfo, exact map[string]string) {
+	dir := idx.dirName(file.Path)
+	if file.Path == file.Root || !withinDir(idx.fsys, file.Root, dir) {
+		return
+	}
+
+	key, dup := exact[file.Path]
+	if !dup {
+		key = "unique:" + file.Path
+	}
+
+	node := idx.node(dir, file.Root)
+	node.files = append(node.files, dirFile{
+		name: idx.baseName(file.Path),
+		key:  key,
+		size: file.Size,
+		dup:  dup,
+	})
+	idx.owners[key] = append(idx.owners[key], node)
+}
+
+// addSkipped 将被跳过的条目所在的目录标记为内容不完整
+func (idx *dirIndex) addSkipped(entry FileInfo) {
+	dir := idx.dirName(entry.Path)
+	if entry.Path == entry.Root || !withinDir(idx.fsys, entry.Root, dir) {
+		return
+	}
+	idx.node(dir, entry.Root).partial = true
+}
+
+// node 返回目录对应的节点，不存在时连同上级目录一起创建。
+// 向上创建到扫描根目录为止，到达文件系统的根目录时也停止，避免根目录成为自己的上级。
+func (idx *dirIndex) node(dir, root string) *dirNode {
+	if node, ok := idx.nodes[dir]; ok {
+		return node
+	}
+
+	node := &dirNode{path: dir, name: idx.baseName(dir)}
+	idx.nodes[dir] = node
+	if parentDir := idx.dirName(dir); dir != root && parentDir != dir {
+		parent := idx.node(parentDir, root)
+		node.parent = parent
+		parent.children = append(parent.children, node)
+	}
+	return node
+}
+
+func (idx *dirIndex) dirName(p string) string {
+	if _, ok := idx.fsys.(localFS); ok {
+		return filepath.Dir(p)
+	}
+	return path.Dir(p)
+}
+
+func (idx *dirIndex) baseName(p string) string {
+	return baseName(idx.fsys, p)
+}
+
+// computeDigest 自底向上计算 Merkle 摘要和统计信息。
+// 内容不完整的目录在摘要中加入自身路径，因此不会与任何目录相同，其上级目录也一样。
+func (n *dirNode) computeDigest() {
+	sort.Slice(n.files, func(i, j int) bool { return n.files[i].name < n.files[j].name })
+	sort.Slice(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+
+	h := sha256.New()
+	n.allDup = !n.partial
+	if n.partial {
+		fmt.Fprintf(h, "partial %q\n", n.path)
+	}
+	for _, file := range n.files {
+		fmt.Fprintf(h, "f %q %s\n", file.name, file.key)
+		n.count++
+		n.size += file.size
+		n.allDup = n.allDup && file.dup
+	}
+	for _, child := range n.children {
+		child.computeDigest()
+		fmt.Fprintf(h, "d %q %s\n", child.name, child.digest)
+		n.count += child.count
+		n.size += child.size
+		n.allDup = n.allDup && child.allDup
+	}
+	n.digest = fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// identicalDirs 按摘要分组。如果一组目录的上级目录也彼此相同，该组已被上级覆盖，不再报告。
+func (idx *dirIndex) identicalDirs(reported map[*dirNode]bool) []DirGroup {
+	byDigest := make(map[string][]*dirNode)
+	for _, node := range idx.nodes {
+		if node.count > 0 {
+			byDigest[node.digest] = append(byDigest[node.digest], node)
+		}
+	}
+
+	var groups []DirGroup
+	for digest, nodes := range byDigest {
+		if len(nodes) < 2 {
+			continue
+		}
+		for _, node := range nodes {
+			reported[node] = true
+		}
+		if coveredByParents(nodes, byDigest) {
+			continue
+		}
+
+		group := DirGroup{Digest: digest, Files: nodes[0].count, Size: nodes[0].size}
+		for _, node := range nodes {
+			group.Dirs = append(group.Dirs, node.path)
+		}
+		sort.Strings(group.Dirs)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		ri, rj := groups[i].Reclaimable(), groups[j].Reclaimable()
+		if ri != rj {
+			return ri > rj
+		}
+		return groups[i].Dirs[0] < groups[j].Dirs[0]
+	})
+	return groups
+}
+
+// coveredByParents 判断一组相同目录的上级目录是否也彼此相同
+func coveredByParents(nodes []*dirNode, byDigest map[string][]*dirNode) bool {
+	parent := nodes[0].parent
+	if parent == nil || len(byDigest[parent.digest]) < 2 {
+		return false
+	}
+	seen := make(map[*dirNode]bool)
+	for _, node := range nodes {
+		if node.parent == nil || node.parent.digest != parent.digest || seen[node.parent] {
+			return false
+		}
+		seen[node.parent] = true
+	}
+	return true
+}
+
+// dirSubsets 找出所有文件在另一目录中都有副本的目录，只报告最上层的目录，
+// 并为每个目录选择文件数最少的包含目录
+func (idx *dirIndex) dirSubsets(reported map[*dirNode]bool) []DirSubset {
+	var candidates []*dirNode
+	for _, node := range idx.nodes {
+		if node.allDup && node.count > 0 {
+			candidates = append(candidates, node)
+		}
+	}
+	// 从上层目录开始处理，以便跳过已被上级报告覆盖的子目录
+	sort.Slice(candidates, func(i, j int) bool {
+		di, dj := candidates[i].depth(), candidates[j].depth()
+		if di != dj {
+			return di < dj
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	sets := make(map[*dirNode]map[string]bool)
+	var subsets []DirSubset
+	for _, a := range candidates {
+		if reported[a] || ancestorReported(a, reported) {
+			continue
+		}
+
+		keysA := contentSet(a, sets)
+		var best *dirNode
+		var first string
+		for key := range keysA {
+			first = key
+			break
+		}
+		for _, owner := range idx.owners[first] {
+			for b := owner; b != nil; b = b.parent {
+				if b == a || isAncestor(b, a) || isAncestor(a, b) {
+					continue
+				}
+				if best != nil && b.count >= best.count {
+					break
+				}
+				keysB := contentSet(b, sets)
+				if !containsAll(keysB, keysA) {
+					continue
+				}
+				// 内容完全相同的两个目录只报告一次
+				if len(keysB) == len(keysA) && b.path < a.path {
+					continue
+				}
+				best = b
+				break
+			}
+		}
+
+		if best != nil {
+			reported[a] = true
+			subsets = append(subsets, DirSubset{
+				Subset:   a.path,
+				Superset: best.path,
+				Files:    a.count,
+				Size:     a.size,
+			})
+		}
+	}
+
+	sort.Slice(subsets, func(i, j int) bool {
+		if subsets[i].Size != subsets[j].Size {
+			return subsets[i].Size > subsets[j].Size
+		}
+		return subsets[i].Subset < subsets[j].Subset
+	})
+	return subsets
+}
+
+// contentSet 返回目录中（递归）所有文件的内容标识集合，结果会被缓存
+func contentSet(n *dirNode, cache map[*dirNode]map[string]bool) map[string]bool {
+	if set, ok := cache[n]; ok {
+		return set
+	}
+	set := make(map[string]bool)
+	for _, file := range n.files {
+		set[file.key] = true
+	}
+	for _, child := range n.children {
+		for key := range contentSet(child, cache) {
+			set[key] = true
+		}
+	}
+	cache[n] = set
+	return set
+}
+
+func containsAll(set, subset map[string]bool) bool {
+	if len(subset) > len(set) {
+		return false
+	}
+	for key := range subset {
+		if !set[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// depth 返回目录相对扫描根目录的层数
+func (n *dirNode) depth() int {
+	d := 0
+	for p := n.parent; p != nil; p = p.parent {
+		d++
+	}
+	return d
+}
+
+// isAncestor 判断 a 是否为 b 的上级目录
+func isAncestor(a, b *dirNode) bool {
+	for p := b.parent; p != nil; p = p.parent {
+		if p == a {
+			return true
+		}
+	}
+	return false
+}
+
+func ancestorReported(n *dirNode, reported map[*dirNode]bool) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if reported[p] {
+			return true
+		}
+	}
+	return false
+}
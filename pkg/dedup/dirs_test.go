@@ -0,0 +1,187 @@
+package dedup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// identicalTree 两个内容完全相同的目录 x/A 与 x/B，以及一个没有副本的文件
+func identicalTree() fstest.MapFS {
+	return fstest.MapFS{
+		"x/A/1.txt":     {Data: []byte("one")},
+		"x/A/s/2.txt":   {Data: []byte("two")},
+		"x/B/1.txt":     {Data: []byte("one")},
+		"x/B/s/2.txt":   {Data: []byte("two")},
+		"x/C/other.txt": {Data: []byte("unique")},
+	}
+}
+
+func scanDirs(t *testing.T, fsys FileSystem, roots ...string) *Result {
+	t.Helper()
+	scanner, err := New(WithFS(fsys), WithDirectories(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Scan(context.Background(), roots...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestDuplicateDirsRootSpellings(t *testing.T) {
+	tests := []struct {
+		root string
+		dirs []string
+	}{
+		{"x", []string{"x/A", "x/B"}},
+		{"x/", []string{"x/A", "x/B"}},
+		{"./x", []string{"x/A", "x/B"}},
+		{".", []string{"x/A", "x/B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.root, func(t *testing.T) {
+			result := scanDirs(t, FromFS(identicalTree()), tt.root)
+			if len(result.DuplicateDirs) != 1 {
+				t.Fatalf("DuplicateDirs = %+v, want 1 group", result.DuplicateDirs)
+			}
+			group := result.DuplicateDirs[0]
+			if !equalStrings(group.Dirs, tt.dirs) {
+				t.Errorf("Dirs = %v, want %v", group.Dirs, tt.dirs)
+			}
+			if group.Files != 2 || group.Size != 6 {
+				t.Errorf("Files, Size = %d, %d, want 2, 6", group.Files, group.Size)
+			}
+		})
+	}
+}
+
+func TestDuplicateDirsLocalTrailingSlash(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"A/1.txt", "B/1.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := scanDirs(t, Local, dir+string(filepath.Separator))
+	want := []string{filepath.Join(dir, "A"), filepath.Join(dir, "B")}
+	if len(result.DuplicateDirs) != 1 || !equalStrings(result.DuplicateDirs[0].Dirs, want) {
+		t.Fatalf("DuplicateDirs = %+v, want %v", result.DuplicateDirs, want)
+	}
+}
+
+func TestDuplicateDirsDigest(t *testing.T) {
+	fsys := fstest.MapFS{
+		// 内容相同但文件名不同的目录摘要不同
+		"renamed/A/1.txt": {Data: []byte("one")},
+		"renamed/B/2.txt": {Data: []byte("one")},
+		// 上级目录相同时只报告上级，不再报告其中相同的子目录
+		"nested/P/s/1.txt": {Data: []byte("nested")},
+		"nested/Q/s/1.txt": {Data: []byte("nested")},
+	}
+	result := scanDirs(t, FromFS(fsys), ".")
+	if len(result.DuplicateDirs) != 1 {
+		t.Fatalf("DuplicateDirs = %+v, want 1 group", result.DuplicateDirs)
+	}
+	group := result.DuplicateDirs[0]
+	if want := []string{"nested/P", "nested/Q"}; !equalStrings(group.Dirs, want) {
+		t.Errorf("Dirs = %v, want %v", group.Dirs, want)
+	}
+	if len(group.Digest) != 64 {
+		t.Errorf("Digest = %q, want a sha256 hex digest", group.Digest)
+	}
+	if got := group.Reclaimable(); got != int64(len("nested")) {
+		t.Errorf("Reclaimable = %d, want %d", got, len("nested"))
+	}
+
+	// 摘要只取决于名称和内容，与所在位置无关
+	other := scanDirs(t, FromFS(fstest.MapFS{
+		"elsewhere/X/s/1.txt": {Data: []byte("nested")},
+		"elsewhere/Y/s/1.txt": {Data: []byte("nested")},
+	}), "elsewhere")
+	if len(other.DuplicateDirs) != 1 || other.DuplicateDirs[0].Digest != group.Digest {
+		t.Errorf("DuplicateDirs = %+v, want digest %s", other.DuplicateDirs, group.Digest)
+	}
+}
+
+func TestDirSubsets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"small/1.txt": {Data: []byte("one")},
+		"big/1.txt":   {Data: []byte("one")},
+		"big/2.txt":   {Data: []byte("two")},
+	}
+	result := scanDirs(t, FromFS(fsys), ".")
+	if len(result.DuplicateDirs) != 0 {
+		t.Errorf("DuplicateDirs = %+v, want none", result.DuplicateDirs)
+	}
+	if len(result.DirSubsets) != 1 {
+		t.Fatalf("DirSubsets = %+v, want 1", result.DirSubsets)
+	}
+	if s := result.DirSubsets[0]; s.Subset != "small" || s.Superset != "big" {
+		t.Errorf("DirSubsets[0] = %+v, want small in big", s)
+	}
+}
+
+func TestDuplicateDirsSkippedEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+		opts  []Option
+	}{
+		{"excluded file", "x/B/s/skip.log", []Option{WithExcludePatterns("*.log")}},
+		{"excluded dir", "x/B/s/cache/1.bin", []Option{WithExcludePatterns("cache")}},
+		{"small file", "x/B/s/tiny", []Option{WithMinSize(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"x/A/1.txt":   {Data: []byte("one")},
+				"x/A/s/2.txt": {Data: []byte("two")},
+				"x/B/1.txt":   {Data: []byte("one")},
+				"x/B/s/2.txt": {Data: []byte("two")},
+				tt.extra:      {Data: []byte("x")},
+			}
+			scanner, err := New(append(tt.opts, WithFS(FromFS(fsys)), WithDirectories(true))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := scanner.Scan(context.Background(), "x")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// x/B 与 x/B/s 中有未参与比较的内容，不能算作与 x/A 相同或被 x/A 包含
+			if len(result.DuplicateDirs) != 0 {
+				t.Errorf("DuplicateDirs = %+v, want none", result.DuplicateDirs)
+			}
+			for _, s := range result.DirSubsets {
+				if s.Subset == "x/B" || s.Subset == "x/B/s" {
+					t.Errorf("DirSubsets has %+v, want x/B not reported as subset", s)
+				}
+			}
+			if len(result.DirSubsets) != 1 || result.DirSubsets[0].Subset != "x/A" {
+				t.Errorf("DirSubsets = %+v, want x/A in x/B", result.DirSubsets)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+// Package dedup 提供查找与处理重复文件的公共 API。
+//
+// 基本用法：
+//
+//	scanner, err := dedup.New(
+//		dedup.WithHashAlgorithm(dedup.SHA256),
+//		dedup.WithMinSize(1<<20),
+//		dedup.WithExcludePatterns(".git", "node_modules"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	result, err := scanner.Scan(ctx, "/data")
+//	if err != nil {
+//		return err
+//	}
+//	plan := dedup.NewPlan(result, dedup.KeepOldest)
+//	report, err := plan.Apply(ctx, dedup.TrashExecutor{})
+//
+// 扫描只对大小相同的文件计算哈希，结果中的重复组按可释放空间从大到小排序。
+// 需要在扫描过程中尽早处理结果时使用 Scanner.Stream。
+//
+// 执行计划前会重新检查每个文件，扫描之后被修改过的文件不会被移除，
+// 详见 Plan.Apply。
+package dedup
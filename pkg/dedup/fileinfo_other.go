@@ -0,0 +1,8 @@
+//go:build !unix
+
+package dedup
+
+import "io/fs"
+
+// fillSysInfo 在非 Unix 系统上无法获取 inode 和所有者，不做任何处理
+func fillSysInfo(file *FileInfo, info fs.FileInfo) {}
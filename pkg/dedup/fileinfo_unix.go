@@ -0,0 +1,20 @@
+//go:build unix
+
+package dedup
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fillSysInfo 从系统相关的 stat 信息中补充设备号、inode 和所有者
+func fillSysInfo(file *FileInfo, info fs.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	file.Device = uint64(st.Dev)
+	file.Inode = uint64(st.Ino)
+	file.UID = int(st.Uid)
+	file.Owner = lookupOwner(file.UID)
+}
@@ -0,0 +1,263 @@
+package dedup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 支持导入的其他去重工具的结果格式
+const (
+	// FormatFdupes fdupes 与 jdupes 的默认文本输出：每行一个路径，空行分隔各组
+	FormatFdupes = "fdupes"
+	// FormatJdupes jdupes -j 的 JSON 输出，也接受与 fdupes 相同的文本输出
+	FormatJdupes = "jdupes"
+	// FormatRmlint rmlint 的 JSON 输出（rmlint.json）
+	FormatRmlint = "rmlint"
+)
+
+// ErrInvalidForeign 表示无法解析其他工具的结果文件
+var ErrInvalidForeign = errors.New("无效的重复文件列表")
+
+// ForeignGroup 其他去重工具报告的一组重复文件
+type ForeignGroup struct {
+	Paths []string
+	// Original 工具标记为原始文件的路径（rmlint 的 is_original），没有标记时为空
+	Original string
+}
+
+// fdupesSizeLine fdupes -S 在每组前输出的大小行，如 "1024 bytes each:"
+var fdupesSizeLine = regexp.MustCompile(`^\d+ bytes each:$`)
+
+// ReadForeign 读取 fdupes、jdupes 或 rmlint 的结果。
+// format 为空时按内容自动识别：以 "[" 开头为 rmlint，以 "{" 开头为 jdupes JSON，否则为 fdupes 文本。
+// 少于两个文件的组会被忽略。
+func ReadForeign(r io.Reader, format string) ([]ForeignGroup, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+
+	switch strings.ToLower(format) {
+	case "":
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("[")):
+			return readRmlint(trimmed)
+		case bytes.HasPrefix(trimmed, []byte("{")):
+			return readJdupesJSON(trimmed)
+		default:
+			return readFdupes(data)
+		}
+	case FormatFdupes:
+		return readFdupes(data)
+	case FormatJdupes:
+		if bytes.HasPrefix(trimmed, []byte("{")) {
+			return readJdupesJSON(trimmed)
+		}
+		return readFdupes(data)
+	case FormatRmlint:
+		return readRmlint(trimmed)
+	default:
+		return nil, fmt.Errorf("未知的导入格式: %s", format)
+	}
+}
+
+// readFdupes 解析 fdupes 文本输出
+func readFdupes(data []byte) ([]ForeignGroup, error) {
+	var groups []ForeignGroup
+	var current ForeignGroup
+	flush := func() {
+		if len(current.Paths) >= 2 {
+			groups = append(groups, current)
+		}
+		current = ForeignGroup{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			flush()
+		case fdupesSizeLine.MatchString(line):
+			// 忽略 -S 输出的大小行
+		default:
+			current.Paths = append(current.Paths, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return groups, nil
+}
+
+// readJdupesJSON 解析 jdupes -j 的输出
+func readJdupesJSON(data []byte) ([]ForeignGroup, error) {
+	var doc struct {
+		MatchSets []struct {
+			FileList []struct {
+				FilePath string `json:"filePath"`
+			} `json:"fileList"`
+		} `json:"matchSets"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidForeign, err)
+	}
+
+	var groups []ForeignGroup
+	for _, set := range doc.MatchSets {
+		var group ForeignGroup
+		for _, file := range set.FileList {
+			group.Paths = append(group.Paths, file.FilePath)
+		}
+		if len(group.Paths) >= 2 {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// readRmlint 解析 rmlint 的 JSON 输出，只使用 duplicate_file 记录，按 checksum 分组
+func readRmlint(data []byte) ([]ForeignGroup, error) {
+	var records []struct {
+		Type       string `json:"type"`
+		Path       string `json:"path"`
+		Checksum   string `json:"checksum"`
+		IsOriginal bool   `json:"is_original"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidForeign, err)
+	}
+
+	index := make(map[string]int)
+	var groups []ForeignGroup
+	for _, rec := range records {
+		if rec.Type != "duplicate_file" || rec.Path == "" {
+			continue
+		}
+		i, ok := index[rec.Checksum]
+		if !ok {
+			i = len(groups)
+			index[rec.Checksum] = i
+			groups = append(groups, ForeignGroup{})
+		}
+		groups[i].Paths = append(groups[i].Paths, rec.Path)
+		if rec.IsOriginal && groups[i].Original == "" {
+			groups[i].Original = rec.Path
+		}
+	}
+
+	valid := groups[:0]
+	for _, group := range groups {
+		if len(group.Paths) >= 2 {
+			valid = append(valid, group)
+		}
+	}
+	return valid, nil
+}
+
+// Recheck 重新检查其他工具报告的重复组：只保留仍然存在的普通文件，
+// 用扫描器的哈希算法重新计算哈希，按实际内容重新分组，不再相同的文件会被剔除。
+// 返回的结果可以直接交给 NewPlan，文件是否属于参考目录按 WithReferenceDirs 判断。
+//
+// 过滤条件（最小大小、排除模式、文件类型）不适用于导入的文件；符号链接会被忽略。
+// 同一个文件（相同路径的不同写法或硬链接）在列表中出现多次时只保留第一次，
+// 否则删除其中一个“副本”就会删掉唯一的一份。
+func (s *Scanner) Recheck(ctx context.Context, groups []ForeignGroup) (*Result, error) {
+	run, err := s.newRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		SchemaVersion: ResultSchemaVersion,
+		Algorithm:     s.opts.HashAlgorithm,
+		ReferenceDirs: s.opts.ReferenceDirs,
+	}
+
+	seen := make(map[string]bool)
+	members := make([][]*FileInfo, len(groups))
+	var pending []*FileInfo
+	for i, group := range groups {
+		for _, path := range group.Paths {
+			if run.fs == Local {
+				path = filepath.Clean(path)
+			}
+			info, err := lstat(run.fs, path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			file := newFileInfo(parentDir(run.fs, path), path, info)
+			key := fileKey(run.fs, file)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			file.Reference = s.isReference(path)
+			members[i] = append(members[i], &file)
+			pending = append(pending, &file)
+			result.TotalFiles++
+			result.TotalSize += file.Size
+		}
+	}
+
+	if err := run.hashAll(pending); err != nil {
+		return nil, err
+	}
+
+	result.DuplicateGroups = []DuplicateGroup{}
+	for _, files := range members {
+		type key struct {
+			hash string
+			size int64
+		}
+		byContent := make(map[key][]FileInfo)
+		var order []key
+		for _, file := range files {
+			if file.Hash == "" {
+				continue
+			}
+			k := key{file.Hash, file.Size}
+			if _, ok := byContent[k]; !ok {
+				order = append(order, k)
+			}
+			f := *file
+			f.Hash = ""
+			byContent[k] = append(byContent[k], f)
+		}
+
+		for _, k := range order {
+			same := byContent[k]
+			if len(same) < 2 {
+				continue
+			}
+			sort.Slice(same, func(i, j int) bool { return same[i].Path < same[j].Path })
+			group := DuplicateGroup{Hash: k.hash, Algorithm: result.Algorithm, Size: k.size, Files: same}
+			result.DuplicateGroups = append(result.DuplicateGroups, group)
+			result.SavedSize += group.Reclaimable()
+		}
+	}
+
+	sortGroups(result.DuplicateGroups)
+	return result, nil
+}
+
+// lstat 获取文件信息，本地磁盘上不跟随符号链接
+func lstat(fsys FileSystem, name string) (os.FileInfo, error) {
+	if fsys == Local {
+		return os.Lstat(name)
+	}
+	return fsys.Stat(name)
+}
@@ -0,0 +1,166 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadForeign(t *testing.T) {
+	tests := []struct {
+		name, format, data string
+		want               []ForeignGroup
+	}{
+		{
+			name:   "fdupes",
+			format: FormatFdupes,
+			data:   "a/1\na/2\n\nb/1\nb/2\nb/3\n",
+			want:   []ForeignGroup{{Paths: []string{"a/1", "a/2"}}, {Paths: []string{"b/1", "b/2", "b/3"}}},
+		},
+		{
+			name:   "fdupes size lines and CRLF",
+			format: FormatFdupes,
+			data:   "3 bytes each:\r\na/1\r\na/2\r\n\r\n5 bytes each:\r\nb/1\r\n",
+			want:   []ForeignGroup{{Paths: []string{"a/1", "a/2"}}},
+		},
+		{
+			name: "fdupes auto-detected",
+			data: "\n\na/1\na/2",
+			want: []ForeignGroup{{Paths: []string{"a/1", "a/2"}}},
+		},
+		{
+			name:   "jdupes JSON",
+			format: FormatJdupes,
+			data: `{"jdupesVersion": "1.27", "matchSets": [
+				{"fileSize": 3, "fileList": [{"filePath": "a/1"}, {"filePath": "a/2"}]},
+				{"fileSize": 5, "fileList": [{"filePath": "single"}]}
+			]}`,
+			want: []ForeignGroup{{Paths: []string{"a/1", "a/2"}}},
+		},
+		{
+			name:   "jdupes text",
+			format: FormatJdupes,
+			data:   "a/1\na/2\n",
+			want:   []ForeignGroup{{Paths: []string{"a/1", "a/2"}}},
+		},
+		{
+			name: "rmlint auto-detected",
+			data: `[
+				{"description": "rmlint json-dump"},
+				{"type": "duplicate_file", "path": "a/1", "checksum": "aa", "is_original": true},
+				{"type": "emptyfile", "path": "empty", "checksum": "aa"},
+				{"type": "duplicate_file", "path": "b/1", "checksum": "bb"},
+				{"type": "duplicate_file", "path": "a/2", "checksum": "aa"},
+				{"type": "duplicate_file", "path": "c/1", "checksum": "cc"},
+				{"type": "duplicate_file", "path": "b/2", "checksum": "bb", "is_original": true},
+				{"aborted": false, "progress": 100}
+			]`,
+			want: []ForeignGroup{
+				{Paths: []string{"a/1", "a/2"}, Original: "a/1"},
+				{Paths: []string{"b/1", "b/2"}, Original: "b/2"},
+			},
+		},
+		{
+			name:   "empty",
+			format: FormatFdupes,
+			data:   "\n\n",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadForeign(strings.NewReader(tt.data), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadForeign = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadForeignMalformed(t *testing.T) {
+	tests := []struct {
+		name, format, data string
+	}{
+		{"jdupes truncated", FormatJdupes, `{"matchSets": [{"fileList": [`},
+		{"jdupes wrong type", "", `{"matchSets": {"fileList": []}}`},
+		{"rmlint truncated", "", `[{"type": "duplicate_file", "path": "a"`},
+		{"rmlint not an array", FormatRmlint, `{"type": "duplicate_file"}`},
+		{"rmlint wrong field type", FormatRmlint, `[{"type": "duplicate_file", "path": 1}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadForeign(strings.NewReader(tt.data), tt.format); !errors.Is(err, ErrInvalidForeign) {
+				t.Errorf("ReadForeign error = %v, want %v", err, ErrInvalidForeign)
+			}
+		})
+	}
+
+	if _, err := ReadForeign(strings.NewReader("a\nb\n"), "dupeguru"); err == nil {
+		t.Error("ReadForeign with unknown format succeeded, want error")
+	}
+}
+
+func recheck(t *testing.T, groups ...ForeignGroup) *Result {
+	t.Helper()
+	scanner, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Recheck(context.Background(), groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRecheckDropsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	writeFile(t, a, "same")
+	writeFile(t, b, "same")
+	// c 在其他工具报告之后被修改，大小不变但内容已不同
+	writeFile(t, c, "diff")
+
+	result := recheck(t,
+		ForeignGroup{Paths: []string{a, b, c, filepath.Join(dir, "missing")}},
+		ForeignGroup{Paths: []string{c, filepath.Join(dir, "gone")}},
+	)
+	if len(result.DuplicateGroups) != 1 {
+		t.Fatalf("DuplicateGroups = %+v, want 1", result.DuplicateGroups)
+	}
+	if got := groupPaths(result.DuplicateGroups[0]); !equalStrings(got, []string{a, b}) {
+		t.Errorf("group = %v, want %v", got, []string{a, b})
+	}
+	if result.SavedSize != 4 {
+		t.Errorf("SavedSize = %d, want 4", result.SavedSize)
+	}
+}
+
+func TestRecheckSameFileOnce(t *testing.T) {
+	dir := t.TempDir()
+	a, b, link := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "link")
+	writeFile(t, a, "same")
+	writeFile(t, b, "same")
+	if err := os.Link(a, link); err != nil {
+		t.Skip("不支持硬链接:", err)
+	}
+
+	// 同一个文件的不同写法和硬链接只保留第一次出现的路径
+	result := recheck(t, ForeignGroup{Paths: []string{a, dir + "/./a", link, b}})
+	if len(result.DuplicateGroups) != 1 {
+		t.Fatalf("DuplicateGroups = %+v, want 1", result.DuplicateGroups)
+	}
+	if got := groupPaths(result.DuplicateGroups[0]); !equalStrings(got, []string{a, b}) {
+		t.Errorf("group = %v, want %v", got, []string{a, b})
+	}
+	if result.TotalFiles != 2 {
+		t.Errorf("TotalFiles = %d, want 2", result.TotalFiles)
+	}
+}
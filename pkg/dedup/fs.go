@@ -0,0 +1,150 @@
+package dedup
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileSystem 扫描器读取文件所需的文件系统接口。
+//
+// 除 Local 外，路径遵循 io/fs 的约定：使用斜杠分隔，不以斜杠开头，"." 表示根目录。
+// 任意 fs.FS（zip.Reader、embed.FS、fstest.MapFS 等）都可以通过 FromFS 转换得到。
+type FileSystem interface {
+	fs.StatFS
+	fs.ReadDirFS
+}
+
+// RemoveFS 支持删除文件的文件系统，用于执行删除计划
+type RemoveFS interface {
+	FileSystem
+	Remove(name string) error
+}
+
+// Local 本地磁盘，路径使用操作系统的原生格式（可以是绝对路径），默认使用该文件系统
+var Local RemoveFS = localFS{}
+
+// localFS 直接调用 os 包访问本地磁盘
+type localFS struct{}
+
+func (localFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (localFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (localFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (localFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// FromFS 将任意 fs.FS 转换为 FileSystem，缺少的 Stat、ReadDir 方法由 io/fs 的通用实现补齐
+func FromFS(fsys fs.FS) FileSystem {
+	if f, ok := fsys.(FileSystem); ok {
+		return f
+	}
+	return genericFS{fsys}
+}
+
+type genericFS struct {
+	fs.FS
+}
+
+func (g genericFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(g.FS, name)
+}
+
+func (g genericFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(g.FS, name)
+}
+
+// joinPath 按文件系统的路径约定拼接路径
+func joinPath(fsys FileSystem, dir, name string) string {
+	if _, ok := fsys.(localFS); ok {
+		return filepath.Join(dir, name)
+	}
+	return path.Join(dir, name)
+}
+
+// baseName 按文件系统的路径约定返回路径的最后一个元素
+func baseName(fsys FileSystem, name string) string {
+	if _, ok := fsys.(localFS); ok {
+		return filepath.Base(name)
+	}
+	return path.Base(name)
+}
+
+// parentDir 按文件系统的路径约定返回上级目录
+func parentDir(fsys FileSystem, name string) string {
+	if _, ok := fsys.(localFS); ok {
+		return filepath.Dir(name)
+	}
+	return path.Dir(name)
+}
+
+// cleanPath 按文件系统的路径约定规范化路径，去掉末尾的分隔符和 "."、".." 等多余的元素
+func cleanPath(fsys FileSystem, name string) string {
+	if _, ok := fsys.(localFS); ok {
+		return filepath.Clean(name)
+	}
+	return path.Clean(name)
+}
+
+// withinDir 判断 name 是否就是 dir 或位于 dir 之下，本地磁盘上按绝对路径比较
+func withinDir(fsys FileSystem, dir, name string) bool {
+	if _, ok := fsys.(localFS); ok {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+		rel, err := filepath.Rel(dir, name)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+
+	dir, name = path.Clean(dir), path.Clean(name)
+	return dir == "." || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// walkFunc 遍历回调，返回 fs.SkipDir 时跳过当前目录
+type walkFunc func(path string, d fs.DirEntry) error
+
+// walkDir 按字典序深度优先遍历 root，不跟随符号链接。
+// 与 fs.WalkDir 不同，路径按文件系统自身的约定拼接，因此本地磁盘上保留原生分隔符。
+func walkDir(fsys FileSystem, root string, fn walkFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		return err
+	}
+	err = walkEntry(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walkEntry(fsys FileSystem, name string, d fs.DirEntry, fn walkFunc) error {
+	if err := fn(name, d); err != nil || !d.IsDir() {
+		return err
+	}
+
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err := walkEntry(fsys, joinPath(fsys, name, entry.Name()), entry, fn)
+		if err != nil && err != fs.SkipDir {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package dedup
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // 注册 GIF 解码器
+	_ "image/jpeg" // 注册 JPEG 解码器
+	_ "image/png"  // 注册 PNG 解码器
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// 支持的感知哈希算法
+const (
+	AHash = "ahash" // 均值哈希：最快，对亮度、对比度调整较敏感
+	DHash = "dhash" // 差值哈希：速度与准确度之间的折中
+	PHash = "phash" // DCT 感知哈希：最稳健，计算量最大
+)
+
+// DefaultImageThreshold 默认的汉明距离阈值，64 位哈希中不同的位数不超过该值即视为相似
+const DefaultImageThreshold = 10
+
+// SimilarFile 相似组中的一个文件
+type SimilarFile struct {
+	FileInfo
+	// ImageHash 感知哈希值的十六进制形式
+	ImageHash string `json:"image_hash"`
+	// Distance 与组内第一个文件的汉明距离
+	Distance int `json:"distance"`
+}
+
+// SimilarGroup 一组内容相似但不一定完全相同的图片，Files 按路径排序
+type SimilarGroup struct {
+	Algorithm string        `json:"algorithm"`
+	Files     []SimilarFile `json:"files"`
+}
+
+// TotalSize 返回组内所有文件的总大小
+func (g SimilarGroup) TotalSize() int64 {
+	var size int64
+	for _, file := range g.Files {
+		size += file.Size
+	}
+	return size
+}
+
+// validImageAlgorithm 检查并规范化感知哈希算法名称
+func validImageAlgorithm(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case AHash, DHash, PHash:
+		return strings.ToLower(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownAlgorithm, name)
+	}
+}
+
+// imageHash 解码图片并计算 64 位感知哈希
+func imageHash(r io.Reader, algorithm string) (uint64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+
+	switch algorithm {
+	case AHash:
+		return averageHash(img), nil
+	case DHash:
+		return differenceHash(img), nil
+	default:
+		return perceptualHash(img), nil
+	}
+}
+
+// averageHash 缩小到 8x8 灰度图，每个像素与平均亮度比较
+func averageHash(img image.Image) uint64 {
+	pixels := grayscale(img, 8, 8)
+
+	var sum float64
+	for _, p := range pixels {
+		sum += p
+	}
+	mean := sum / float64(len(pixels))
+
+	var hash uint64
+	for i, p := range pixels {
+		if p > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// differenceHash 缩小到 9x8 灰度图，每行相邻像素比较亮度变化方向
+func differenceHash(img image.Image) uint64 {
+	pixels := grayscale(img, 9, 8)
+
+	var hash uint64
+	bit := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixels[y*9+x] < pixels[y*9+x+1] {
+				hash |= 1 << uint(bit)
+			}
+			bit++
+		}
+	}
+	return hash
+}
+
+// perceptualHash 缩小到 32x32 灰度图做二维 DCT，取左上角 8x8 低频系数与其中位数比较
+func perceptualHash(img image.Image) uint64 {
+	const size, low = 32, 8
+	pixels := grayscale(img, size, size)
+
+	// 先按行再按列做一维 DCT-II
+	rows := make([]float64, size*size)
+	for y := 0; y < size; y++ {
+		dct(pixels[y*size:(y+1)*size], rows[y*size:(y+1)*size])
+	}
+	coeffs := make([]float64, size*size)
+	col := make([]float64, size)
+	out := make([]float64, size)
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			col[y] = rows[y*size+x]
+		}
+		dct(col, out)
+		for y := 0; y < size; y++ {
+			coeffs[y*size+x] = out[y]
+		}
+	}
+
+	lowFreq := make([]float64, 0, low*low)
+	for y := 0; y < low; y++ {
+		for x := 0; x < low; x++ {
+			lowFreq = append(lowFreq, coeffs[y*size+x])
+		}
+	}
+
+	// 直流分量只反映整体亮度，不参与中位数计算
+	sorted := append([]float64(nil), lowFreq[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range lowFreq {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// dct 一维 DCT-II
+func dct(in, out []float64) {
+	n := len(in)
+	for k := 0; k < n; k++ {
+		var sum float64
+		for i, v := range in {
+			sum += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		out[k] = sum
+	}
+}
+
+// grayscale 将图片按区域平均缩放到 w x h 并转换为灰度，按行返回亮度值
+func grayscale(img image.Image, w, h int) []float64 {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	pixels := make([]float64, w*h)
+	if sw == 0 || sh == 0 {
+		return pixels
+	}
+
+	for y := 0; y < h; y++ {
+		y0 := bounds.Min.Y + y*sh/h
+		y1 := bounds.Min.Y + (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := bounds.Min.X + x*sw/w
+			x1 := bounds.Min.X + (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			pixels[y*w+x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return pixels
+}
+
+// hammingDistance 返回两个哈希值中不同的位数
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// bkTree 以汉明距离为度量的 BK 树，用于快速查找距离阈值内的哈希
+type bkTree struct {
+	root *bkNode
+}
+
+type bkNode struct {
+	hash     uint64
+	index    int
+	children map[int]*bkNode
+}
+
+func (t *bkTree) insert(hash uint64, index int) {
+	node := &bkNode{hash: hash, index: index}
+	if t.root == nil {
+		t.root = node
+		return
+	}
+
+	cur := t.root
+	for {
+		d := hammingDistance(cur.hash, hash)
+		next, ok := cur.children[d]
+		if !ok {
+			if cur.children == nil {
+				cur.children = make(map[int]*bkNode)
+			}
+			cur.children[d] = node
+			return
+		}
+		cur = next
+	}
+}
+
+// search 返回与 hash 的距离不超过 threshold 的所有元素下标
+func (t *bkTree) search(hash uint64, threshold int) []int {
+	var found []int
+	if t.root == nil {
+		return found
+	}
+
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		d := hammingDistance(node.hash, hash)
+		if d <= threshold {
+			found = append(found, node.index)
+		}
+		for cd, child := range node.children {
+			if cd >= d-threshold && cd <= d+threshold {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return found
+}
+
+// groupSimilar 将距离在阈值内的哈希用并查集连成组，只返回至少包含两个元素的组
+func groupSimilar(hashes []uint64, threshold int) [][]int {
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	tree := &bkTree{}
+	for i, hash := range hashes {
+		for _, j := range tree.search(hash, threshold) {
+			parent[find(i)] = find(j)
+		}
+		tree.insert(hash, i)
+	}
+
+	sets := make(map[int][]int)
+	for i := range hashes {
+		root := find(i)
+		sets[root] = append(sets[root], i)
+	}
+
+	var groups [][]int
+	for _, members := range sets {
+		if len(members) > 1 {
+			groups = append(groups, members)
+		}
+	}
+	return groups
+}
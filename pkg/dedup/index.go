@@ -0,0 +1,226 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrNotIndexed 表示文件不在任何被索引的根目录中，或不满足扫描的过滤条件
+var ErrNotIndexed = errors.New("文件不在索引范围内")
+
+// Index 扫描结果的内存索引，扫描之后可以逐个加入新文件并立即找出与之重复的已有文件，
+// 用于监视目录等需要实时发现重复的场景。归档内的成员不会被索引。
+// Index 的方法可以在多个协程中并发调用。
+type Index struct {
+	run   *scanRun
+	roots []string
+
+	mu     sync.Mutex
+	bySize map[int64][]*FileInfo
+	byPath map[string]*FileInfo
+}
+
+// NewIndex 完整扫描 roots 并建立索引，同时返回与 Scan 相同的扫描结果
+func (s *Scanner) NewIndex(ctx context.Context, roots ...string) (*Index, *Result, error) {
+	run, err := s.newRun(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idx := &Index{
+		run:    run,
+		roots:  run.walkRoots(roots),
+		bySize: make(map[int64][]*FileInfo),
+		byPath: make(map[string]*FileInfo),
+	}
+	// 借用增量扫描的状态记录扫描过程中计算出的哈希值
+	run.next = &State{Files: make(map[string]StateEntry), fs: run.fs}
+	run.onFile = func(file FileInfo) {
+		if !file.InArchive() {
+			idx.insert(file)
+		}
+	}
+
+	groups := []DuplicateGroup{}
+	result, err := run.stream(func(group DuplicateGroup) error {
+		groups = append(groups, group)
+		return nil
+	}, roots...)
+	if err != nil {
+		return nil, nil, err
+	}
+	sortGroups(groups)
+	result.DuplicateGroups = groups
+
+	for path, file := range idx.byPath {
+		if entry, ok := run.next.Files[run.next.key(path)]; ok {
+			file.Hash = entry.Hash
+		}
+	}
+	run.next = nil
+	run.onFile = nil
+	return idx, result, nil
+}
+
+// Len 返回索引中的文件数
+func (idx *Index) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.byPath)
+}
+
+// Has 判断文件是否已在索引中
+func (idx *Index) Has(path string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	_, ok := idx.byPath[path]
+	return ok
+}
+
+// Add 将新建或修改过的文件加入索引（已存在时替换原记录），
+// 返回包含该文件及其所有已知副本的重复组；没有副本时返回 nil。
+// 文件不在索引的根目录中或不满足过滤条件时返回 ErrNotIndexed。
+func (idx *Index) Add(path string) (*DuplicateGroup, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(path)
+
+	file, err := idx.stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 指向同一个文件的硬链接不算副本
+	key := fileKey(idx.run.fs, file)
+	var candidates []*FileInfo
+	for _, other := range idx.bySize[file.Size] {
+		if unchanged(idx.run.fs, *other) && fileKey(idx.run.fs, *other) != key {
+			candidates = append(candidates, other)
+		}
+	}
+	stored := idx.insert(file)
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	if err := idx.ensureHash(stored); err != nil {
+		return nil, err
+	}
+	group := &DuplicateGroup{
+		Hash:      stored.Hash,
+		Algorithm: idx.run.opts.HashAlgorithm,
+		Size:      stored.Size,
+	}
+	for _, other := range candidates {
+		if idx.ensureHash(other) == nil && other.Hash == stored.Hash {
+			group.Files = append(group.Files, *other)
+		}
+	}
+	if len(group.Files) == 0 {
+		return nil, nil
+	}
+
+	group.Files = append(group.Files, *stored)
+	for i := range group.Files {
+		group.Files[i].Hash = ""
+	}
+	sort.Slice(group.Files, func(i, j int) bool { return group.Files[i].Path < group.Files[j].Path })
+	return group, nil
+}
+
+// Remove 从索引中删除文件，path 为目录时删除其下的所有文件
+func (idx *Index) Remove(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.byPath[path]; ok {
+		idx.remove(path)
+		return
+	}
+	for p := range idx.byPath {
+		if withinDir(idx.run.fs, path, p) {
+			idx.remove(p)
+		}
+	}
+}
+
+// stat 读取文件信息并检查扫描的过滤条件
+func (idx *Index) stat(path string) (FileInfo, error) {
+	r := idx.run
+	root := ""
+	for _, candidate := range idx.roots {
+		if withinDir(r.fs, candidate, path) {
+			root = candidate
+			break
+		}
+	}
+	if root == "" || r.excludedBelow(root, path) {
+		return FileInfo{}, ErrNotIndexed
+	}
+
+	info, err := r.fs.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if !info.Mode().IsRegular() || info.Size() < r.opts.MinSize {
+		return FileInfo{}, ErrNotIndexed
+	}
+
+	file := newFileInfo(root, path, info)
+	if len(r.opts.FileTypes) > 0 {
+		fileType, err := r.detectFileType(path)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		if !containsFold(r.opts.FileTypes, fileType) {
+			return FileInfo{}, ErrNotIndexed
+		}
+		file.FileType = fileType
+	}
+	file.Reference = r.isReference(path)
+	return file, nil
+}
+
+// ensureHash 在需要时计算并缓存文件的哈希值
+func (idx *Index) ensureHash(file *FileInfo) error {
+	if file.Hash != "" {
+		return nil
+	}
+	hash, err := idx.run.hashFile(*file)
+	if err != nil {
+		return err
+	}
+	file.Hash = hash
+	return nil
+}
+
+func (idx *Index) insert(file FileInfo) *FileInfo {
+	stored := &file
+	idx.byPath[file.Path] = stored
+	idx.bySize[file.Size] = append(idx.bySize[file.Size], stored)
+	return stored
+}
+
+func (idx *Index) remove(path string) {
+	file, ok := idx.byPath[path]
+	if !ok {
+		return
+	}
+	delete(idx.byPath, path)
+
+	files := idx.bySize[file.Size]
+	for i, f := range files {
+		if f == file {
+			files = append(files[:i], files[i+1:]...)
+			break
+		}
+	}
+	if len(files) == 0 {
+		delete(idx.bySize, file.Size)
+	} else {
+		idx.bySize[file.Size] = files
+	}
+}
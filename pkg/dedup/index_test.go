@@ -0,0 +1,98 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestIndex(t *testing.T, roots ...string) *Index {
+	t.Helper()
+	scanner, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, _, err := scanner.NewIndex(context.Background(), roots...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexAdd(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	writeFile(t, a, "same")
+	writeFile(t, filepath.Join(dir, "b.txt"), "diff")
+	idx := newTestIndex(t, dir)
+	if idx.Len() != 2 || !idx.Has(a) {
+		t.Fatalf("Len = %d, Has(a) = %v", idx.Len(), idx.Has(a))
+	}
+
+	c := filepath.Join(dir, "sub", "c.txt")
+	writeFile(t, c, "same")
+	group, err := idx.Add(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group == nil || !equalStrings(groupPaths(*group), []string{a, c}) {
+		t.Fatalf("Add(c) = %+v, want a and c", group)
+	}
+
+	// 大小相同内容不同的文件不是副本
+	d := filepath.Join(dir, "d.txt")
+	writeFile(t, d, "other")
+	if group, err := idx.Add(d); err != nil || group != nil {
+		t.Errorf("Add(d) = %+v, %v, want no group", group, err)
+	}
+
+	// 硬链接不算副本
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Link(d, link); err == nil {
+		if group, err := idx.Add(link); err != nil || group != nil {
+			t.Errorf("Add(link) = %+v, %v, want no group", group, err)
+		}
+	}
+
+	if _, err := idx.Add(filepath.Join(t.TempDir(), "outside.txt")); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("Add(outside) error = %v, want ErrNotIndexed", err)
+	}
+}
+
+func TestIndexRemove(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	writeFile(t, a, "same")
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "same")
+	writeFile(t, filepath.Join(dir, "sub", "deeper", "c.txt"), "same")
+	idx := newTestIndex(t, dir)
+	if idx.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", idx.Len())
+	}
+
+	// 删除目录时删除其下的所有文件
+	idx.Remove(filepath.Join(dir, "sub"))
+	if idx.Len() != 1 || !idx.Has(a) {
+		t.Fatalf("Len = %d after removing sub, want only a", idx.Len())
+	}
+
+	// 被删除的文件不再作为副本报告
+	e := filepath.Join(dir, "e.txt")
+	writeFile(t, e, "same")
+	idx.Remove(a)
+	if group, err := idx.Add(e); err != nil || group != nil {
+		t.Errorf("Add(e) = %+v, %v, want no group", group, err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package dedup
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// 见 linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// setLowIOPriority 将当前进程的 I/O 调度优先级降为 idle 类，
+// 只有磁盘空闲时才会处理本进程的读请求。
+//
+// ioprio_set 作用于单个线程，因此这里逐个设置 /proc/self/task 下的所有线程，
+// 之后新建的线程会继承创建者的优先级。
+func setLowIOPriority() error {
+	prio := uintptr(ioprioClassIdle << ioprioClassShift)
+
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return ioprioSet(0, prio)
+	}
+
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := ioprioSet(tid, prio); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ioprioSet(tid int, prio uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio)
+	if errno != 0 && errno != syscall.ESRCH {
+		return errno
+	}
+	return nil
+}
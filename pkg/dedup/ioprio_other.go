@@ -0,0 +1,8 @@
+//go:build !linux
+
+package dedup
+
+// setLowIOPriority 在非 Linux 系统上没有对应的接口，不做任何处理
+func setLowIOPriority() error {
+	return nil
+}
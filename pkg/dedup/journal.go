@@ -0,0 +1,212 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 日志中记录的处理方式
+const (
+	JournalTrash  = "trash"
+	JournalDelete = "delete"
+)
+
+// 日志记录的状态
+const (
+	// JournalPending 即将移除文件，移除前写入
+	JournalPending = "pending"
+	// JournalDone 文件已被移除
+	JournalDone = "done"
+	// JournalFailed 移除失败，文件仍在原处
+	JournalFailed = "failed"
+)
+
+// JournalEntry 操作日志中的一条记录：一个被移除的文件
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// Status 为 JournalPending、JournalDone 或 JournalFailed，
+	// 旧版本写入的记录没有该字段，读取时视为 JournalDone
+	Status  string    `json:"status,omitempty"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+	// Kept 同组中被保留的文件，移除的文件与它内容相同
+	Kept string `json:"kept"`
+}
+
+// Journal 追加写入的操作日志，每行一条 JSON 记录，用于事后追查和恢复被移除的文件。
+// Journal 可以被多个协程同时使用。
+type Journal struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+	err error
+}
+
+// OpenJournal 以追加方式打开日志文件，文件或目录不存在时自动创建
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record 写入一条记录，Time 为零值时使用当前时间
+func (j *Journal) Record(entry JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(entry); err != nil {
+		if j.err == nil {
+			j.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+// Close 关闭日志文件，返回关闭错误或此前写入记录时遇到的第一个错误
+func (j *Journal) Close() error {
+	err := j.f.Close()
+	if j.err != nil {
+		return j.err
+	}
+	return err
+}
+
+// Executor 返回包装了 exec 的执行器：移除文件前先向日志写入 JournalPending 记录，
+// 移除后再写入一条 JournalDone 或 JournalFailed 记录，因此即使程序在移除过程中退出，
+// 被移除的文件也能在日志中找到。记录中的哈希值与保留文件取自 plan，
+// 本地磁盘上的路径记录为绝对路径。action 为 JournalTrash 或 JournalDelete。
+//
+// 无法写入 JournalPending 记录时不移除文件并返回错误；文件已被移除但写入结果失败时
+// 不会报告为失败，错误由 Close 返回。
+func (j *Journal) Executor(plan *Plan, exec Executor, action string) Executor {
+	groups := make(map[string]PlanGroup)
+	for _, group := range plan.Groups {
+		for _, file := range group.Remove {
+			groups[file.Path] = group
+		}
+	}
+	return &journalExecutor{exec: exec, journal: j, groups: groups, action: action}
+}
+
+type journalExecutor struct {
+	exec    Executor
+	journal *Journal
+	groups  map[string]PlanGroup
+	action  string
+}
+
+func (e *journalExecutor) Remove(file FileInfo) error {
+	group := e.groups[file.Path]
+	entry := JournalEntry{
+		Action:  e.action,
+		Status:  JournalPending,
+		Path:    e.absPath(file.Path),
+		Size:    file.Size,
+		ModTime: file.ModTime,
+		Hash:    group.Hash,
+		Kept:    e.absPath(group.Keep.Path),
+	}
+	if err := e.journal.Record(entry); err != nil {
+		return fmt.Errorf("写入操作日志失败: %w", err)
+	}
+
+	err := e.exec.Remove(file)
+	entry.Time = time.Time{}
+	entry.Status = JournalDone
+	if err != nil {
+		entry.Status = JournalFailed
+	}
+	e.journal.Record(entry)
+	return err
+}
+
+// absPath 本地磁盘上的路径记录为绝对路径，以便在其他工作目录下恢复
+func (e *journalExecutor) absPath(path string) string {
+	if e.fileSystem() != Local || path == "" {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// fileSystem 使 Apply 在被包装执行器所用的文件系统上检查文件状态
+func (e *journalExecutor) fileSystem() RemoveFS {
+	if inner, ok := e.exec.(interface{ fileSystem() RemoveFS }); ok {
+		return inner.fileSystem()
+	}
+	return Local
+}
+
+// ReadJournal 读取日志中被移除的文件，按写入顺序返回。
+//
+// 同一文件的 JournalPending 记录与随后的结果记录合并为一条，时间为移除前的时间：
+// 移除成功时 Status 为 JournalDone，移除失败的文件不会返回。
+// 没有结果记录的文件（移除过程中程序退出）保留为 JournalPending，文件可能已被移除，也可能仍在原处。
+func ReadJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	// pending 尚未读到结果的文件在 entries 中的下标
+	pending := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("日志第 %d 行无效: %v", line, err)
+		}
+
+		switch entry.Status {
+		case JournalPending:
+			pending[entry.Path] = len(entries)
+			entries = append(entries, entry)
+		case JournalDone, JournalFailed:
+			if i, ok := pending[entry.Path]; ok {
+				delete(pending, entry.Path)
+				entries[i].Status = entry.Status
+			} else if entry.Status == JournalDone {
+				entries = append(entries, entry)
+			}
+		default:
+			entry.Status = JournalDone
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	removed := entries[:0]
+	for _, entry := range entries {
+		if entry.Status != JournalFailed {
+			removed = append(removed, entry)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,97 @@
+package dedup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalExecutor(t *testing.T) {
+	dir := t.TempDir()
+	keep := filepath.Join(dir, "keep.txt")
+	remove := filepath.Join(dir, "remove.txt")
+	locked := filepath.Join(dir, "locked.txt")
+	plan := &Plan{Groups: []PlanGroup{{
+		Hash:   "h",
+		Size:   4,
+		Keep:   FileInfo{Path: keep, Size: 4},
+		Remove: []FileInfo{{Path: remove, Size: 4}, {Path: locked, Size: 4}},
+	}}}
+
+	path := filepath.Join(dir, "journal.jsonl")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errLocked := errors.New("locked")
+	var pendingSeen bool
+	exec := journal.Executor(plan, ExecutorFunc(func(file FileInfo) error {
+		// 移除文件之前日志中已经有这个文件的记录
+		entries, err := ReadJournal(path)
+		if err != nil || len(entries) == 0 || entries[len(entries)-1].Path != file.Path {
+			t.Errorf("移除 %s 前的日志 = %+v, %v", file.Path, entries, err)
+		} else {
+			pendingSeen = entries[len(entries)-1].Status == JournalPending
+		}
+		if file.Path == locked {
+			return errLocked
+		}
+		return nil
+	}), JournalDelete)
+	for _, file := range plan.Groups[0].Remove {
+		exec.Remove(file)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !pendingSeen {
+		t.Error("移除前的记录不是 pending")
+	}
+
+	entries, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v, want only %s", entries, remove)
+	}
+	if e := entries[0]; e.Path != remove || e.Status != JournalDone || e.Kept != keep || e.Hash != "h" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestReadJournalStatuses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	lines := `{"time":"2024-01-01T00:00:00Z","action":"trash","path":"/old"}
+{"time":"2024-01-02T00:00:00Z","action":"trash","status":"pending","path":"/crashed"}
+{"time":"2024-01-03T00:00:00Z","action":"delete","status":"pending","path":"/ok"}
+{"time":"2024-01-03T00:00:01Z","action":"delete","status":"done","path":"/ok"}
+{"time":"2024-01-04T00:00:00Z","action":"delete","status":"pending","path":"/failed"}
+{"time":"2024-01-04T00:00:01Z","action":"delete","status":"failed","path":"/failed"}
+`
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ path, status string }{
+		{"/old", JournalDone},
+		{"/crashed", JournalPending},
+		{"/ok", JournalDone},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %+v, want %d", entries, len(want))
+	}
+	for i, w := range want {
+		if entries[i].Path != w.path || entries[i].Status != w.status {
+			t.Errorf("entry %d = %s %s, want %s %s", i, entries[i].Path, entries[i].Status, w.path, w.status)
+		}
+	}
+	if entries[2].Time.Day() != 3 || entries[2].Time.Second() != 0 {
+		t.Errorf("合并后的时间 = %v, want 移除前的时间", entries[2].Time)
+	}
+}
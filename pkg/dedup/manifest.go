@@ -0,0 +1,301 @@
+package dedup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidManifest 表示清单文件格式无法识别
+var ErrInvalidManifest = errors.New("无效的清单文件")
+
+// ManifestEntry 清单中的一个文件
+type ManifestEntry struct {
+	// Path 相对清单根目录的路径，使用斜杠分隔
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+	// Size 与 ModTime 只在 JSON 清单中存在，读取 sha256sum 格式时为零值
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Manifest 目录的校验清单，Entries 按路径排序
+type Manifest struct {
+	SchemaVersion int             `json:"schema_version"`
+	Algorithm     string          `json:"algorithm"`
+	Root          string          `json:"root"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Entries       []ManifestEntry `json:"entries"`
+}
+
+// Manifest 计算 root 下所有文件的哈希，生成校验清单。
+// 过滤条件与 Scan 相同；归档内的成员不会写入清单，无法读取的文件会被跳过。
+func (s *Scanner) Manifest(ctx context.Context, root string) (*Manifest, error) {
+	run, err := s.newRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := run.looseFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*FileInfo, len(files))
+	for i := range files {
+		pending[i] = &files[i]
+	}
+	if err := run.hashAll(pending); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		SchemaVersion: ResultSchemaVersion,
+		Algorithm:     s.opts.HashAlgorithm,
+		Root:          root,
+		CreatedAt:     time.Now(),
+		Entries:       []ManifestEntry{},
+	}
+	for _, file := range files {
+		if file.Hash == "" {
+			continue
+		}
+		m.Entries = append(m.Entries, ManifestEntry{
+			Path:    relPath(run.fs, root, file.Path),
+			Hash:    file.Hash,
+			Size:    file.Size,
+			ModTime: file.ModTime,
+		})
+	}
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+	return m, nil
+}
+
+// WriteChecksums 以 sha256sum/md5sum 的格式写出清单，每行为“哈希值  路径”。
+// 与 coreutils 相同，路径中含有反斜杠或换行符时对其转义，并在行首加上反斜杠。
+func (m *Manifest) WriteChecksums(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, entry := range m.Entries {
+		name := entry.Path
+		prefix := ""
+		if strings.ContainsAny(name, "\\\n") {
+			prefix = "\\"
+			name = strings.ReplaceAll(name, "\\", "\\\\")
+			name = strings.ReplaceAll(name, "\n", "\\n")
+		}
+		if _, err := fmt.Fprintf(bw, "%s%s  %s\n", prefix, entry.Hash, name); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadManifest 读取 JSON 清单或 sha256sum/md5sum 格式的清单。
+// sha256sum 格式不含算法名称，按哈希值长度判断，也没有大小和修改时间。
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var m Manifest
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+		}
+		if m.Algorithm != MD5 && m.Algorithm != SHA256 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, m.Algorithm)
+		}
+		return &m, nil
+	}
+
+	m := &Manifest{SchemaVersion: ResultSchemaVersion, Entries: []ManifestEntry{}}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, algorithm, err := parseChecksumLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 第 %d 行: %v", ErrInvalidManifest, i+1, err)
+		}
+		if m.Algorithm == "" {
+			m.Algorithm = algorithm
+		} else if m.Algorithm != algorithm {
+			return nil, fmt.Errorf("%w: 第 %d 行: 哈希算法与前面的行不一致", ErrInvalidManifest, i+1)
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	if m.Algorithm == "" {
+		return nil, fmt.Errorf("%w: 清单为空", ErrInvalidManifest)
+	}
+	return m, nil
+}
+
+// parseChecksumLine 解析一行“哈希值  路径”或“哈希值 *路径”
+func parseChecksumLine(line string) (ManifestEntry, string, error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	i := strings.IndexByte(line, ' ')
+	if i < 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+		return ManifestEntry{}, "", errors.New("格式应为“哈希值  路径”")
+	}
+	hash, name := strings.ToLower(line[:i]), line[i+2:]
+
+	var algorithm string
+	switch len(hash) {
+	case 32:
+		algorithm = MD5
+	case 64:
+		algorithm = SHA256
+	default:
+		return ManifestEntry{}, "", fmt.Errorf("无法识别的哈希值 %s", hash)
+	}
+	if strings.Trim(hash, "0123456789abcdef") != "" {
+		return ManifestEntry{}, "", fmt.Errorf("无法识别的哈希值 %s", hash)
+	}
+
+	if escaped {
+		name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(name)
+	}
+	return ManifestEntry{Path: path.Clean(filepath.ToSlash(name)), Hash: hash}, algorithm, nil
+}
+
+// VerifyMismatch 内容与清单不一致的文件
+type VerifyMismatch struct {
+	Entry ManifestEntry `json:"expected"`
+	File  FileInfo      `json:"actual"`
+	// Corrupt 为 true 表示内容变化但大小和修改时间都与清单一致，很可能是静默损坏。
+	// 只有清单中记录了修改时间（JSON 清单）时才能判断。
+	Corrupt bool `json:"corrupt"`
+}
+
+// VerifyResult 按清单校验目录的结果
+type VerifyResult struct {
+	Algorithm string `json:"algorithm"`
+	Root      string `json:"root"`
+	// OK 内容与清单一致的文件数
+	OK int `json:"ok"`
+	// Missing 清单中有、目录中已不存在（或无法读取）的文件
+	Missing []ManifestEntry `json:"missing"`
+	// Modified 内容与清单中哈希值不一致的文件
+	Modified []VerifyMismatch `json:"modified"`
+	// New 目录中有、清单中没有的文件，路径与清单相同，是相对 Root 的路径。
+	// 新增文件不计算哈希，Hash 为空。
+	New []ManifestEntry `json:"new"`
+}
+
+// Clean 判断校验是否通过：没有丢失或被修改的文件。新增文件不影响校验结果。
+func (v *VerifyResult) Clean() bool {
+	return len(v.Missing) == 0 && len(v.Modified) == 0
+}
+
+// Verify 按清单重新计算 root 下文件的哈希，找出丢失、被修改和新增的文件。
+// 无论大小和修改时间是否变化，清单中的文件都会重新读取，以便发现静默损坏。
+// 使用清单记录的哈希算法，扫描器配置的算法不起作用。
+func (s *Scanner) Verify(ctx context.Context, root string, m *Manifest) (*VerifyResult, error) {
+	opts := s.opts
+	opts.HashAlgorithm = m.Algorithm
+	run, err := (&Scanner{opts: opts}).newRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := run.looseFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[string]ManifestEntry, len(m.Entries))
+	for _, entry := range m.Entries {
+		expected[entry.Path] = entry
+	}
+
+	result := &VerifyResult{
+		Algorithm: m.Algorithm,
+		Root:      root,
+		Missing:   []ManifestEntry{},
+		Modified:  []VerifyMismatch{},
+		New:       []ManifestEntry{},
+	}
+	var pending []*FileInfo
+	found := make(map[string]bool)
+	for i := range files {
+		rel := relPath(run.fs, root, files[i].Path)
+		if _, ok := expected[rel]; ok {
+			found[rel] = true
+			pending = append(pending, &files[i])
+		} else {
+			result.New = append(result.New, ManifestEntry{
+				Path:    rel,
+				Size:    files[i].Size,
+				ModTime: files[i].ModTime,
+			})
+		}
+	}
+	if err := run.hashAll(pending); err != nil {
+		return nil, err
+	}
+
+	for _, file := range pending {
+		entry := expected[relPath(run.fs, root, file.Path)]
+		switch {
+		case file.Hash == "":
+			result.Missing = append(result.Missing, entry)
+		case file.Hash == entry.Hash:
+			result.OK++
+		default:
+			result.Modified = append(result.Modified, VerifyMismatch{
+				Entry:   entry,
+				File:    *file,
+				Corrupt: !entry.ModTime.IsZero() && entry.Size == file.Size && entry.ModTime.Equal(file.ModTime),
+			})
+		}
+	}
+	for _, entry := range m.Entries {
+		if !found[entry.Path] {
+			result.Missing = append(result.Missing, entry)
+		}
+	}
+
+	sort.Slice(result.Missing, func(i, j int) bool { return result.Missing[i].Path < result.Missing[j].Path })
+	sort.Slice(result.Modified, func(i, j int) bool { return result.Modified[i].Entry.Path < result.Modified[j].Entry.Path })
+	sort.Slice(result.New, func(i, j int) bool { return result.New[i].Path < result.New[j].Path })
+	return result, nil
+}
+
+// looseFiles 遍历 root，返回符合过滤条件的归档外文件
+func (r *scanRun) looseFiles(root string) ([]FileInfo, error) {
+	var files []FileInfo
+	err := r.walk([]string{root}, func(file FileInfo) {
+		if !file.InArchive() {
+			files = append(files, file)
+		}
+	})
+	return files, err
+}
+
+// relPath 返回 name 相对 root 的路径，使用斜杠分隔
+func relPath(fsys FileSystem, root, name string) string {
+	if _, ok := fsys.(localFS); ok {
+		if rel, err := filepath.Rel(root, name); err == nil {
+			return filepath.ToSlash(rel)
+		}
+		return filepath.ToSlash(name)
+	}
+	if root == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, root+"/")
+}
@@ -0,0 +1,121 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManifestChecksumsRoundTrip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/a.txt":          {Data: []byte("alpha")},
+		"data/sub/b.txt":      {Data: []byte("beta")},
+		"data/back\\slash":    {Data: []byte("escaped")},
+		"data/new\nline.txt":  {Data: []byte("escaped too")},
+		"outside/ignored.txt": {Data: []byte("not in manifest")},
+	}
+	scanner, err := New(WithFS(FromFS(fsys)), WithHashAlgorithm(SHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := scanner.Manifest(context.Background(), "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 4 {
+		t.Fatalf("Entries = %+v, want 4", m.Entries)
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteChecksums(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "  sub/b.txt\n") || !strings.Contains(buf.String(), "\\n") {
+		t.Errorf("WriteChecksums =\n%s", buf.String())
+	}
+	read, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Algorithm != SHA256 {
+		t.Errorf("Algorithm = %q, want %q", read.Algorithm, SHA256)
+	}
+	if len(read.Entries) != len(m.Entries) {
+		t.Fatalf("Entries = %+v, want %+v", read.Entries, m.Entries)
+	}
+	for i, entry := range read.Entries {
+		if entry.Path != m.Entries[i].Path || entry.Hash != m.Entries[i].Hash {
+			t.Errorf("entry %d = %q %s, want %q %s", i, entry.Path, entry.Hash, m.Entries[i].Path, m.Entries[i].Hash)
+		}
+	}
+
+	result, err := scanner.Verify(context.Background(), "data", read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Clean() || result.OK != len(m.Entries) {
+		t.Errorf("Verify = %+v, want all %d files OK", result, len(m.Entries))
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	md5 := strings.Repeat("a", 32)
+	sha := strings.Repeat("b", 64)
+	tests := []struct {
+		name, data string
+		want       error
+	}{
+		{"empty", "\n\n", ErrInvalidManifest},
+		{"mixed algorithms", md5 + "  a\n" + sha + "  b\n", ErrInvalidManifest},
+		{"bad hash", "xyz  a\n", ErrInvalidManifest},
+		{"single space", md5 + " a\n", ErrInvalidManifest},
+		{"json algorithm", `{"algorithm": "crc32", "entries": []}`, ErrUnknownAlgorithm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadManifest(strings.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("ReadManifest error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPathsRelativeToRoot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/keep.txt":  {Data: []byte("keep")},
+		"data/gone.txt":  {Data: []byte("gone")},
+		"data/sub/c.txt": {Data: []byte("before")},
+	}
+	scanner, err := New(WithFS(FromFS(fsys)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := scanner.Manifest(context.Background(), "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delete(fsys, "data/gone.txt")
+	fsys["data/sub/c.txt"] = &fstest.MapFile{Data: []byte("after!")}
+	fsys["data/sub/new.txt"] = &fstest.MapFile{Data: []byte("new")}
+	result, err := scanner.Verify(context.Background(), "data", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.OK != 1 {
+		t.Errorf("OK = %d, want 1", result.OK)
+	}
+	if len(result.Missing) != 1 || result.Missing[0].Path != "gone.txt" {
+		t.Errorf("Missing = %+v, want gone.txt", result.Missing)
+	}
+	if len(result.Modified) != 1 || result.Modified[0].Entry.Path != "sub/c.txt" {
+		t.Errorf("Modified = %+v, want sub/c.txt", result.Modified)
+	}
+	if len(result.New) != 1 || result.New[0].Path != "sub/new.txt" || result.New[0].Size != 3 {
+		t.Errorf("New = %+v, want sub/new.txt with size 3", result.New)
+	}
+}
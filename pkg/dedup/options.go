@@ -0,0 +1,163 @@
+package dedup
+
+// 支持的哈希算法
+const (
+	MD5    = "md5"
+	SHA256 = "sha256"
+)
+
+// DefaultConcurrency 默认同时计算哈希的文件数
+const DefaultConcurrency = 5
+
+// Options 扫描器的全部配置，零值即为默认配置
+type Options struct {
+	// HashAlgorithm 内容比对使用的哈希算法，MD5（默认）或 SHA256
+	HashAlgorithm string
+	// MinSize 小于该字节数的文件不参与扫描
+	MinSize int64
+	// FileTypes 只扫描这些类型的文件（image、video、audio、text、pdf、archive、other），
+	// 为空时扫描所有文件。类型按文件头部内容识别，开启后遍历时每个文件都会被读取一次。
+	FileTypes []string
+	// ExcludePatterns 按 filepath.Match 语法匹配文件名或目录名，匹配的目录整体跳过
+	ExcludePatterns []string
+	// Concurrency 同时计算哈希的文件数，<= 0 时使用 DefaultConcurrency
+	Concurrency int
+	// MaxBytesPerSec 所有哈希工作协程合计的读取带宽上限（字节/秒），0 表示不限制
+	MaxBytesPerSec int64
+	// MaxFilesPerSec 每秒最多开始处理的文件数，0 表示不限制
+	MaxFilesPerSec int
+	// LowIOPriority 为 true 时在扫描前降低进程的 I/O 优先级（仅 Linux）
+	LowIOPriority bool
+	// FS 扫描使用的文件系统，为 nil 时使用本地磁盘 Local
+	FS FileSystem
+	// Archives 为 true 时展开 zip、tar、tar.gz、tar.bz2 归档，将其中的文件作为虚拟路径
+	// （如 backup.zip!/photos/a.jpg）参与比较。归档成员只用于报告，永远不会被修改。
+	// 解压后大于 4 GiB 的成员不参与比较。
+	Archives bool
+	// SimilarImages 非空时按该感知哈希算法（AHash、DHash、PHash）查找相似图片，
+	// 结果放在 Result.SimilarGroups 中。只有按文件头识别为图片的普通文件参与比较，
+	// 支持 JPEG、PNG、GIF。相似关系会传递：A 与 B 相似、B 与 C 相似时三者在同一组。
+	SimilarImages string
+	// ImageThreshold 相似图片的汉明距离阈值（0-64），0 只匹配感知哈希完全相同的图片，
+	// 为负数时使用 DefaultImageThreshold
+	ImageThreshold int
+	// Directories 为 true 时按 Merkle 摘要比较目录，报告完全相同的目录和被其他目录包含的目录，
+	// 结果放在 Result.DuplicateDirs 与 Result.DirSubsets 中。只比较参与扫描的文件，
+	// 包含被排除或过滤掉的条目的目录内容不完整，不会被报告。
+	Directories bool
+	// ReferenceDirs 参考目录（主库）。参考目录会与其他目录一起扫描，其中的文件永远保留，
+	// 执行计划只删除参考目录之外的副本，不含参考文件的重复组只报告不处理。
+	ReferenceDirs []string
+	// Progress 不为 nil 时在 Scan、Stream 期间定期接收进度
+	Progress ProgressFunc
+}
+
+// Option 用于修改 Options 的函数式选项
+type Option func(*Options)
+
+// WithOptions 整体替换当前配置，通常用于从配置文件构造扫描器
+func WithOptions(opts Options) Option {
+	return func(o *Options) {
+		*o = opts
+	}
+}
+
+// WithHashAlgorithm 设置哈希算法
+func WithHashAlgorithm(algorithm string) Option {
+	return func(o *Options) {
+		o.HashAlgorithm = algorithm
+	}
+}
+
+// WithMinSize 设置参与扫描的最小文件大小（字节）
+func WithMinSize(size int64) Option {
+	return func(o *Options) {
+		o.MinSize = size
+	}
+}
+
+// WithFileTypes 限定扫描的文件类型
+func WithFileTypes(types ...string) Option {
+	return func(o *Options) {
+		o.FileTypes = append(o.FileTypes, types...)
+	}
+}
+
+// WithExcludePatterns 追加排除模式
+func WithExcludePatterns(patterns ...string) Option {
+	return func(o *Options) {
+		o.ExcludePatterns = append(o.ExcludePatterns, patterns...)
+	}
+}
+
+// WithConcurrency 设置同时计算哈希的文件数
+func WithConcurrency(n int) Option {
+	return func(o *Options) {
+		o.Concurrency = n
+	}
+}
+
+// WithBandwidthLimit 设置所有工作协程合计的读取带宽上限（字节/秒）
+func WithBandwidthLimit(bytesPerSec int64) Option {
+	return func(o *Options) {
+		o.MaxBytesPerSec = bytesPerSec
+	}
+}
+
+// WithFileRateLimit 设置每秒最多开始处理的文件数
+func WithFileRateLimit(filesPerSec int) Option {
+	return func(o *Options) {
+		o.MaxFilesPerSec = filesPerSec
+	}
+}
+
+// WithLowIOPriority 设置是否在扫描前降低进程的 I/O 优先级
+func WithLowIOPriority(low bool) Option {
+	return func(o *Options) {
+		o.LowIOPriority = low
+	}
+}
+
+// WithFS 设置扫描使用的文件系统，扫描路径随之使用该文件系统的路径约定
+func WithFS(fsys FileSystem) Option {
+	return func(o *Options) {
+		o.FS = fsys
+	}
+}
+
+// WithArchives 设置是否展开归档文件查找其中的重复文件
+func WithArchives(enabled bool) Option {
+	return func(o *Options) {
+		o.Archives = enabled
+	}
+}
+
+// WithSimilarImages 按感知哈希算法查找汉明距离不超过 threshold 的相似图片，
+// threshold 为负数时使用 DefaultImageThreshold
+func WithSimilarImages(algorithm string, threshold int) Option {
+	return func(o *Options) {
+		o.SimilarImages = algorithm
+		o.ImageThreshold = threshold
+	}
+}
+
+// WithDirectories 设置是否查找重复目录
+func WithDirectories(enabled bool) Option {
+	return func(o *Options) {
+		o.Directories = enabled
+	}
+}
+
+// WithReferenceDirs 设置参考目录，参考目录中的文件永远不会被删除
+func WithReferenceDirs(dirs ...string) Option {
+	return func(o *Options) {
+		o.ReferenceDirs = append(o.ReferenceDirs, dirs...)
+	}
+}
+
+// WithProgress 设置进度回调
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *Options) {
+		o.Progress = fn
+	}
+}
@@ -0,0 +1,183 @@
+package dedup
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 扫描阶段
+const (
+	PhaseWalking   = "walking"   // 遍历目录，统计文件
+	PhaseHashing   = "hashing"   // 计算大小相同的文件的哈希
+	PhaseAnalyzing = "analyzing" // 相似图片、重复目录等附加分析
+	PhaseDone      = "done"      // 扫描结束
+)
+
+// progressInterval 进度回调的调用间隔
+const progressInterval = 200 * time.Millisecond
+
+// Progress 扫描进度快照
+type Progress struct {
+	Phase string `json:"phase"`
+	// FilesFound 与 BytesFound 遍历阶段已发现的文件数和总大小
+	FilesFound int64 `json:"files_found"`
+	BytesFound int64 `json:"bytes_found"`
+	// FilesToHash 与 BytesToHash 需要计算哈希的文件数和总大小，遍历结束后确定
+	FilesToHash int64 `json:"files_to_hash"`
+	BytesToHash int64 `json:"bytes_to_hash"`
+	FilesHashed int64 `json:"files_hashed"`
+	BytesHashed int64 `json:"bytes_hashed"`
+	// Groups 已确认的重复组数
+	Groups int64 `json:"groups"`
+	// Elapsed 扫描开始以来的时间
+	Elapsed time.Duration `json:"elapsed"`
+	// Remaining 按哈希阶段的平均读取速度估算的剩余时间，无法估算时为 0
+	Remaining time.Duration `json:"remaining"`
+	// CurrentFile 最近遍历到或正在计算哈希的文件
+	CurrentFile string `json:"current_file,omitempty"`
+}
+
+// ProgressFunc 接收扫描进度，扫描期间大约每 200ms 调用一次，扫描结束时再调用一次。
+// 同一次扫描中不会被并发调用。
+type ProgressFunc func(Progress)
+
+// progressTracker 在工作协程之间汇总进度计数，并定期调用 ProgressFunc
+type progressTracker struct {
+	fn    ProgressFunc
+	start time.Time
+
+	mu        sync.Mutex
+	phase     string
+	hashStart time.Time
+
+	filesFound, bytesFound   atomic.Int64
+	filesToHash, bytesToHash atomic.Int64
+	filesHashed, bytesHashed atomic.Int64
+	groups                   atomic.Int64
+	current                  atomic.Value
+
+	stopOnce sync.Once
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// newProgressTracker 创建进度跟踪器，fn 为 nil 时返回 nil，nil 跟踪器的所有方法都不做任何事
+func newProgressTracker(fn ProgressFunc) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn, phase: PhaseWalking}
+}
+
+// begin 开始计时并启动定期回调
+func (p *progressTracker) begin() {
+	if p == nil {
+		return
+	}
+	p.start = time.Now()
+	p.done = make(chan struct{})
+	p.finished = make(chan struct{})
+	go func() {
+		defer close(p.finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.fn(p.snapshot())
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// end 停止定期回调，并以最终状态调用一次
+func (p *progressTracker) end() {
+	if p == nil || p.done == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.done)
+		<-p.finished
+		p.fn(p.snapshot())
+	})
+}
+
+func (p *progressTracker) setPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.phase = phase
+	if phase == PhaseHashing {
+		p.hashStart = time.Now()
+	}
+	p.mu.Unlock()
+}
+
+func (p *progressTracker) found(file FileInfo) {
+	if p == nil {
+		return
+	}
+	p.filesFound.Add(1)
+	p.bytesFound.Add(file.Size)
+	p.current.Store(file.Path)
+}
+
+func (p *progressTracker) toHash(files int, size int64) {
+	if p == nil {
+		return
+	}
+	p.filesToHash.Add(int64(files))
+	p.bytesToHash.Add(size)
+}
+
+// hashing 记录开始计算哈希的文件
+func (p *progressTracker) hashing(file FileInfo) {
+	if p == nil {
+		return
+	}
+	p.current.Store(file.Path)
+}
+
+func (p *progressTracker) hashed(size int64) {
+	if p == nil {
+		return
+	}
+	p.filesHashed.Add(1)
+	p.bytesHashed.Add(size)
+}
+
+func (p *progressTracker) group() {
+	if p == nil {
+		return
+	}
+	p.groups.Add(1)
+}
+
+func (p *progressTracker) snapshot() Progress {
+	p.mu.Lock()
+	phase, hashStart := p.phase, p.hashStart
+	p.mu.Unlock()
+
+	pr := Progress{
+		Phase:       phase,
+		FilesFound:  p.filesFound.Load(),
+		BytesFound:  p.bytesFound.Load(),
+		FilesToHash: p.filesToHash.Load(),
+		BytesToHash: p.bytesToHash.Load(),
+		FilesHashed: p.filesHashed.Load(),
+		BytesHashed: p.bytesHashed.Load(),
+		Groups:      p.groups.Load(),
+		Elapsed:     time.Since(p.start),
+	}
+	pr.CurrentFile, _ = p.current.Load().(string)
+	if phase == PhaseHashing && pr.BytesHashed > 0 {
+		spent := time.Since(hashStart)
+		rest := pr.BytesToHash - pr.BytesHashed
+		pr.Remaining = time.Duration(float64(spent) * float64(rest) / float64(pr.BytesHashed))
+	}
+	return pr
+}
@@ -0,0 +1,251 @@
+package dedup
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 文件的恢复方式
+const (
+	// RestoredFromTrash 从回收站移回原位置
+	RestoredFromTrash = "trash"
+	// RestoredFromCopy 从同组保留的文件复制一份
+	RestoredFromCopy = "copy"
+)
+
+var (
+	// ErrRestoreExists 表示原位置已经存在文件，不会覆盖
+	ErrRestoreExists = errors.New("原位置已存在文件")
+	// ErrKeptChanged 表示同组保留的文件已不存在或内容已改变，无法从它复制
+	ErrKeptChanged = errors.New("保留的文件已不存在或内容已改变")
+)
+
+// Restore 恢复日志中记录的一个文件，返回使用的恢复方式。
+//
+// 移到回收站的文件优先从回收站移回原位置（目前支持 Linux 的 freedesktop 回收站和 macOS 的废纸篓）；
+// 无法从回收站找回或已被永久删除时，从同组保留的文件复制一份，复制前会校验其哈希值与记录一致，
+// 复制得到的文件修改时间恢复为记录中的值。原位置已存在文件时返回 ErrRestoreExists。
+func Restore(entry JournalEntry) (string, error) {
+	if _, err := os.Lstat(entry.Path); err == nil {
+		return "", ErrRestoreExists
+	}
+
+	if entry.Action == JournalTrash {
+		if err := restoreFromTrash(entry); err == nil {
+			return RestoredFromTrash, nil
+		}
+	}
+	if err := restoreFromCopy(entry); err != nil {
+		return "", err
+	}
+	return RestoredFromCopy, nil
+}
+
+// hasherFor 按哈希值的长度返回计算它所用的算法：32 位为 md5，64 位为 sha256
+func hasherFor(sum string) (hash.Hash, error) {
+	switch len(sum) {
+	case 32:
+		return md5.New(), nil
+	case 64:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, sum)
+	}
+}
+
+// restoreFromCopy 校验保留文件的哈希值后复制到原位置
+func restoreFromCopy(entry JournalEntry) error {
+	if entry.Kept == "" {
+		return ErrKeptChanged
+	}
+	hasher, err := hasherFor(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(entry.Kept)
+	if err != nil {
+		return ErrKeptChanged
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil || info.Size() != entry.Size {
+		return ErrKeptChanged
+	}
+
+	if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+		return err
+	}
+	// 先写入临时文件，校验通过后再重命名到原位置
+	tmp, err := os.CreateTemp(filepath.Dir(entry.Path), ".dedupgo-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if fmt.Sprintf("%x", hasher.Sum(nil)) != entry.Hash {
+		return ErrKeptChanged
+	}
+
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), entry.ModTime, entry.ModTime); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(entry.Path); err == nil {
+		return ErrRestoreExists
+	}
+	return os.Rename(tmp.Name(), entry.Path)
+}
+
+// restoreFromTrash 在回收站中找到文件并移回原位置
+func restoreFromTrash(entry JournalEntry) error {
+	switch runtime.GOOS {
+	case "linux":
+		return restoreFreedesktop(entry)
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		// 废纸篓不记录原位置，只在同名且大小一致时认为是同一个文件
+		trashed := filepath.Join(home, ".Trash", filepath.Base(entry.Path))
+		info, err := os.Lstat(trashed)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || info.Size() != entry.Size {
+			return os.ErrNotExist
+		}
+		return os.Rename(trashed, entry.Path)
+	default:
+		return fmt.Errorf("不支持在当前操作系统(%s)上从回收站恢复", runtime.GOOS)
+	}
+}
+
+// restoreFreedesktop 按 freedesktop 回收站规范查找文件：先查找主目录下的回收站，
+// 再查找原路径各级上层目录中的 .Trash/<uid> 与 .Trash-<uid>（其他分区上的回收站）。
+// 同一路径被多次删除时，选择删除时间与记录最接近的一份。
+func restoreFreedesktop(entry JournalEntry) error {
+	type trashDir struct {
+		dir    string
+		topdir string
+	}
+	var dirs []trashDir
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+	}
+	if dataHome != "" {
+		dirs = append(dirs, trashDir{dir: filepath.Join(dataHome, "Trash")})
+	}
+	uid := fmt.Sprint(os.Getuid())
+	for dir := filepath.Dir(entry.Path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs,
+			trashDir{dir: filepath.Join(dir, ".Trash", uid), topdir: dir},
+			trashDir{dir: filepath.Join(dir, ".Trash-"+uid), topdir: dir})
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	var best, bestInfo string
+	var bestDiff time.Duration = -1
+	for _, td := range dirs {
+		infos, err := filepath.Glob(filepath.Join(td.dir, "info", "*.trashinfo"))
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			path, deleted, err := readTrashInfo(info)
+			if err != nil {
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				if td.topdir == "" {
+					continue
+				}
+				path = filepath.Join(td.topdir, path)
+			}
+			if path != entry.Path {
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(info), ".trashinfo")
+			file := filepath.Join(td.dir, "files", name)
+			if st, err := os.Lstat(file); err != nil || !st.Mode().IsRegular() || st.Size() != entry.Size {
+				continue
+			}
+			diff := deleted.Sub(entry.Time)
+			if diff < 0 {
+				diff = -diff
+			}
+			if bestDiff < 0 || diff < bestDiff {
+				best, bestInfo, bestDiff = file, info, diff
+			}
+		}
+	}
+	if best == "" {
+		return os.ErrNotExist
+	}
+
+	if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(best, entry.Path); err != nil {
+		return err
+	}
+	os.Remove(bestInfo)
+	return nil
+}
+
+// readTrashInfo 读取 .trashinfo 文件中的原路径和删除时间
+func readTrashInfo(name string) (string, time.Time, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	var path string
+	var deleted time.Time
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "Path="):
+			path, err = url.PathUnescape(strings.TrimPrefix(line, "Path="))
+			if err != nil {
+				return "", time.Time{}, err
+			}
+		case strings.HasPrefix(line, "DeletionDate="):
+			// 删除时间使用本地时间且不带时区
+			deleted, _ = time.ParseInLocation("2006-01-02T15:04:05", strings.TrimPrefix(line, "DeletionDate="), time.Local)
+		}
+	}
+	if path == "" {
+		return "", time.Time{}, errors.New("缺少 Path")
+	}
+	return path, deleted, scanner.Err()
+}
@@ -0,0 +1,217 @@
+package dedup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ResultSchemaVersion 扫描结果的结构版本，结构发生不兼容变化时递增
+const ResultSchemaVersion = 1
+
+// FileInfo 存储文件信息
+type FileInfo struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	Mode    os.FileMode `json:"mode"`
+	// Device 与 Inode 用于识别同一个文件（包括硬链接），扫描时同一个文件只计入一次，不支持的平台上为 0
+	Device uint64 `json:"device,omitempty"`
+	Inode  uint64 `json:"inode,omitempty"`
+	// UID 文件所有者的用户 ID，不支持的平台上为 -1
+	UID int `json:"uid"`
+	// Owner 文件所有者的用户名，无法解析时为 UID 的字符串形式
+	Owner string `json:"owner,omitempty"`
+	// Root 发现该文件时所在的扫描根目录
+	Root string `json:"root"`
+	// Hash 文件内容的哈希值，在重复组中与 DuplicateGroup.Hash 相同，因此留空
+	Hash     string `json:"hash,omitempty"`
+	FileType string `json:"file_type,omitempty"`
+	// Archive 非空时表示该文件是归档中的成员，值为归档文件的路径，Path 为虚拟路径
+	Archive string `json:"archive,omitempty"`
+	// Reference 为 true 时表示文件位于参考目录中，永远保留，不会成为删除对象
+	Reference bool `json:"reference,omitempty"`
+}
+
+// InArchive 判断文件是否位于归档内
+func (f FileInfo) InArchive() bool {
+	return f.Archive != ""
+}
+
+// DuplicateGroup 一组内容相同的文件，Files 按路径排序
+type DuplicateGroup struct {
+	Hash      string     `json:"hash"`
+	Algorithm string     `json:"algorithm"`
+	Size      int64      `json:"size"`
+	Files     []FileInfo `json:"files"`
+}
+
+// Reclaimable 返回每组只保留一个文件时可释放的字节数，归档内的成员不能被删除，不计算在内。
+// 组内有参考目录中的文件时，参考文件全部保留，其余文件都可以删除。
+func (g DuplicateGroup) Reclaimable() int64 {
+	loose := len(g.Removable())
+	if len(g.References()) > 0 {
+		return g.Size * int64(loose)
+	}
+	if loose < 2 {
+		return 0
+	}
+	return g.Size * int64(loose-1)
+}
+
+// Removable 返回组内可以被删除的文件，即不在归档内、也不在参考目录中的文件
+func (g DuplicateGroup) Removable() []FileInfo {
+	var files []FileInfo
+	for _, file := range g.Files {
+		if !file.InArchive() && !file.Reference {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// References 返回组内位于参考目录中、且不在归档内的文件
+func (g DuplicateGroup) References() []FileInfo {
+	var files []FileInfo
+	for _, file := range g.Files {
+		if file.Reference && !file.InArchive() {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// HasReference 判断组内是否有参考目录中的文件（包括参考目录中归档内的成员）
+func (g DuplicateGroup) HasReference() bool {
+	for _, file := range g.Files {
+		if file.Reference {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths 返回组内所有文件的路径
+func (g DuplicateGroup) Paths() []string {
+	paths := make([]string, len(g.Files))
+	for i, file := range g.Files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+// Result 扫描结果
+type Result struct {
+	SchemaVersion int    `json:"schema_version"`
+	Algorithm     string `json:"algorithm"`
+	// ReferenceDirs 扫描时使用的参考目录，为空表示未使用参考目录模式
+	ReferenceDirs []string `json:"reference_dirs,omitempty"`
+	// DuplicateGroups 按可释放空间从大到小排序，相同时按哈希值排序
+	DuplicateGroups []DuplicateGroup `json:"duplicate_groups"`
+	// SimilarGroups 内容相似的图片，按组内文件总大小从大到小排序，只在开启相似图片检测时存在
+	SimilarGroups []SimilarGroup `json:"similar_groups,omitempty"`
+	// DuplicateDirs 内容完全相同的目录，按可释放空间从大到小排序，只在开启目录比较时存在
+	DuplicateDirs []DirGroup `json:"duplicate_dirs,omitempty"`
+	// DirSubsets 内容被其他目录完全包含的目录，按大小从大到小排序，只在开启目录比较时存在
+	DirSubsets []DirSubset `json:"dir_subsets,omitempty"`
+	TotalFiles int         `json:"total_files"`
+	TotalSize  int64       `json:"total_size"`
+	SavedSize  int64       `json:"saved_size"`
+	// CachedFiles 增量扫描时复用了上次哈希值、没有重新读取的文件数
+	CachedFiles int `json:"cached_files,omitempty"`
+}
+
+// ErrInvalidResult 表示无法解析保存的扫描结果
+var ErrInvalidResult = errors.New("无效的扫描结果")
+
+// ReadResult 读取以 JSON 格式保存的扫描结果（dedupgo scan --output json 的输出）
+func ReadResult(r io.Reader) (*Result, error) {
+	var result Result
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResult, err)
+	}
+	if result.SchemaVersion != ResultSchemaVersion {
+		return nil, fmt.Errorf("%w: 不支持的结构版本 %d", ErrInvalidResult, result.SchemaVersion)
+	}
+	return &result, nil
+}
+
+// UnreferencedGroups 返回参考目录模式下不含参考文件的重复组，这些组不会出现在执行计划中。
+// 未使用参考目录时返回 nil。
+func (r *Result) UnreferencedGroups() []DuplicateGroup {
+	if len(r.ReferenceDirs) == 0 {
+		return nil
+	}
+	var groups []DuplicateGroup
+	for _, group := range r.DuplicateGroups {
+		if !group.HasReference() {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// sortGroups 按可释放空间从大到小排序重复组，保证输出顺序稳定
+func sortGroups(groups []DuplicateGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		ri, rj := groups[i].Reclaimable(), groups[j].Reclaimable()
+		if ri != rj {
+			return ri > rj
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+}
+
+// newFileInfo 根据遍历得到的 fs.FileInfo 构造文件信息
+func newFileInfo(root, path string, info fs.FileInfo) FileInfo {
+	file := FileInfo{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+		Root:    root,
+		UID:     -1,
+	}
+	fillSysInfo(&file, info)
+	return file
+}
+
+// fileKey 返回识别同一个文件的键：有 inode 时使用设备号和 inode，
+// 否则（归档成员、不支持的平台或文件系统）使用规范化的绝对路径
+func fileKey(fsys FileSystem, file FileInfo) string {
+	if file.Inode != 0 && !file.InArchive() {
+		return fmt.Sprintf("%d:%d", file.Device, file.Inode)
+	}
+	name := cleanPath(fsys, file.Path)
+	if _, ok := fsys.(localFS); ok {
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+	}
+	return name
+}
+
+var ownerCache sync.Map
+
+// lookupOwner 将 UID 解析为用户名，结果会被缓存
+func lookupOwner(uid int) string {
+	if name, ok := ownerCache.Load(uid); ok {
+		return name.(string)
+	}
+
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	ownerCache.Store(uid, name)
+	return name
+}
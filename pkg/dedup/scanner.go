@@ -0,0 +1,207 @@
+package dedup
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownAlgorithm 表示不支持的哈希算法
+var ErrUnknownAlgorithm = errors.New("不支持的哈希算法")
+
+// Scanner 文件扫描器。
+//
+// Scanner 创建后配置不再改变，可以被多个协程同时用来执行互不相关的扫描。
+type Scanner struct {
+	opts Options
+}
+
+// New 按选项创建扫描器，未指定的选项使用默认值
+func New(opts ...Option) (*Scanner, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch strings.ToLower(o.HashAlgorithm) {
+	case "", MD5:
+		o.HashAlgorithm = MD5
+	case SHA256:
+		o.HashAlgorithm = SHA256
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, o.HashAlgorithm)
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+	if o.FS == nil {
+		o.FS = Local
+	}
+	if o.SimilarImages != "" {
+		algorithm, err := validImageAlgorithm(o.SimilarImages)
+		if err != nil {
+			return nil, err
+		}
+		o.SimilarImages = algorithm
+		if o.ImageThreshold < 0 {
+			o.ImageThreshold = DefaultImageThreshold
+		} else if o.ImageThreshold > 64 {
+			return nil, fmt.Errorf("相似图片的汉明距离阈值需要在 0 到 64 之间: %d", o.ImageThreshold)
+		}
+	}
+
+	return &Scanner{opts: o}, nil
+}
+
+// Options 返回扫描器实际使用的配置
+func (s *Scanner) Options() Options {
+	return s.opts
+}
+
+// getHasher 根据配置返回相应的哈希函数
+func (s *Scanner) getHasher() hash.Hash {
+	switch s.opts.HashAlgorithm {
+	case SHA256:
+		return sha256.New()
+	default:
+		return md5.New()
+	}
+}
+
+// scanRun 单次扫描的运行状态，限速器在同一次扫描的所有工作协程之间共享
+type scanRun struct {
+	*Scanner
+	fs          FileSystem
+	ctx         context.Context
+	byteLimiter *rateLimiter
+	fileLimiter *rateLimiter
+
+	// images 遍历时收集的图片，用于相似图片检测
+	images []FileInfo
+	// files 遍历时收集的归档外文件，用于目录比较
+	files []FileInfo
+	// skipped 遍历时被排除或过滤掉的条目，用于目录比较
+	skipped []FileInfo
+
+	// prev 与 next 为增量扫描的上次状态和本次状态，普通扫描时为 nil
+	prev    *State
+	next    *State
+	stateMu sync.Mutex
+	cached  int
+
+	progress *progressTracker
+	// zips 哈希阶段共用的 zip 归档，为 nil 时每个成员单独打开归档
+	zips *zipCache
+
+	// onFile 不为 nil 时，遍历到的每个文件（包括大小唯一的文件）都会交给它，用于建立索引
+	onFile func(FileInfo)
+}
+
+// newRun 准备一次扫描，按配置降低 I/O 优先级并创建限速器
+func (s *Scanner) newRun(ctx context.Context) (*scanRun, error) {
+	if s.opts.LowIOPriority {
+		if err := setLowIOPriority(); err != nil {
+			return nil, fmt.Errorf("降低 I/O 优先级失败: %v", err)
+		}
+	}
+
+	return &scanRun{
+		Scanner:     s,
+		fs:          s.opts.FS,
+		ctx:         ctx,
+		byteLimiter: newRateLimiter(s.opts.MaxBytesPerSec),
+		fileLimiter: newRateLimiter(int64(s.opts.MaxFilesPerSec)),
+		progress:    newProgressTracker(s.opts.Progress),
+	}, nil
+}
+
+// calculateFileHash 计算文件哈希值
+func (r *scanRun) calculateFileHash(path string) (string, error) {
+	file, err := r.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return r.hashReader(file)
+}
+
+// limitedReader 为 rd 加上限速和上下文取消控制
+func (r *scanRun) limitedReader(rd io.Reader) io.Reader {
+	var reader io.Reader = &contextReader{ctx: r.ctx, r: rd}
+	if r.byteLimiter != nil {
+		reader = &throttledReader{r: reader, limiter: r.byteLimiter}
+	}
+	return reader
+}
+
+// hashReader 计算 rd 中全部内容的哈希值，读取受限速和上下文取消控制
+func (r *scanRun) hashReader(rd io.Reader) (string, error) {
+	hasher := r.getHasher()
+	if _, err := io.Copy(hasher, r.limitedReader(rd)); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// hashFile 计算候选文件的哈希值
+func (r *scanRun) hashFile(file FileInfo) (string, error) {
+	switch {
+	case file.Hash != "":
+		// tar 成员在遍历时已计算过哈希
+		return file.Hash, nil
+	case file.Archive != "":
+		r.fileLimiter.wait(1)
+		return r.hashZipMember(file)
+	default:
+		if hash, ok := r.cachedHash(file); ok {
+			return hash, nil
+		}
+		r.fileLimiter.wait(1)
+		hash, err := r.calculateFileHash(file.Path)
+		if err == nil {
+			r.storeHash(file, hash)
+		}
+		return hash, err
+	}
+}
+
+// contextReader 在上下文取消后停止读取，使大文件的哈希计算也能及时中断
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Scan 扫描 roots 下的所有文件，扫描结束后按可释放空间从大到小一次性返回全部重复组。
+// roots 是扫描器所用文件系统中的路径，见 WithFS。
+//
+// ctx 取消时扫描尽快停止并返回 ctx.Err()。
+func (s *Scanner) Scan(ctx context.Context, roots ...string) (*Result, error) {
+	groups := []DuplicateGroup{}
+
+	result, err := s.Stream(ctx, func(group DuplicateGroup) error {
+		groups = append(groups, group)
+		return nil
+	}, roots...)
+	if err != nil {
+		return nil, err
+	}
+
+	sortGroups(groups)
+	result.DuplicateGroups = groups
+	return result, nil
+}
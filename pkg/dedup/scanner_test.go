@@ -0,0 +1,25 @@
+package dedup
+
+import "testing"
+
+func TestNewImageThreshold(t *testing.T) {
+	tests := []struct {
+		threshold, want int
+	}{
+		{-1, DefaultImageThreshold},
+		{0, 0},
+		{64, 64},
+	}
+	for _, tt := range tests {
+		scanner, err := New(WithSimilarImages(DHash, tt.threshold))
+		if err != nil {
+			t.Fatalf("New(threshold %d): %v", tt.threshold, err)
+		}
+		if got := scanner.Options().ImageThreshold; got != tt.want {
+			t.Errorf("threshold %d: ImageThreshold = %d, want %d", tt.threshold, got, tt.want)
+		}
+	}
+	if _, err := New(WithSimilarImages(DHash, 65)); err == nil {
+		t.Error("New(threshold 65) succeeded, want error")
+	}
+}
@@ -0,0 +1,109 @@
+package dedup
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// similarImages 计算所有候选图片的感知哈希，并将汉明距离在阈值内的图片分组。
+//
+// exact 记录每个文件所属的完全重复组，成员全部属于同一完全重复组的相似组已在
+// DuplicateGroups 中报告，不再重复输出。无法解码的图片会被跳过。
+func (r *scanRun) similarImages(images []FileInfo, exact map[string]string) []SimilarGroup {
+	hashes := make([]uint64, len(images))
+	ok := make([]bool, len(images))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				r.fileLimiter.wait(1)
+				hash, err := r.imageHashFile(images[i].Path)
+				if err == nil {
+					hashes[i], ok[i] = hash, true
+				}
+			}
+		}()
+	}
+	for i := range images {
+		if r.ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// 只对成功解码的图片分组
+	var decoded []int
+	var decodedHashes []uint64
+	for i := range images {
+		if ok[i] {
+			decoded = append(decoded, i)
+			decodedHashes = append(decodedHashes, hashes[i])
+		}
+	}
+
+	var groups []SimilarGroup
+	for _, members := range groupSimilar(decodedHashes, r.opts.ImageThreshold) {
+		files := make([]int, len(members))
+		for i, m := range members {
+			files[i] = decoded[m]
+		}
+		sort.Slice(files, func(i, j int) bool { return images[files[i]].Path < images[files[j]].Path })
+
+		if sameExactGroup(images, files, exact) {
+			continue
+		}
+
+		group := SimilarGroup{Algorithm: r.opts.SimilarImages}
+		first := hashes[files[0]]
+		for _, i := range files {
+			group.Files = append(group.Files, SimilarFile{
+				FileInfo:  images[i],
+				ImageHash: fmt.Sprintf("%016x", hashes[i]),
+				Distance:  hammingDistance(first, hashes[i]),
+			})
+		}
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		si, sj := groups[i].TotalSize(), groups[j].TotalSize()
+		if si != sj {
+			return si > sj
+		}
+		return groups[i].Files[0].Path < groups[j].Files[0].Path
+	})
+	return groups
+}
+
+// imageHashFile 读取并解码图片文件，计算感知哈希
+func (r *scanRun) imageHashFile(path string) (uint64, error) {
+	file, err := r.fs.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := r.limitedReader(file)
+	return imageHash(reader, r.opts.SimilarImages)
+}
+
+// sameExactGroup 判断 files 是否全部属于同一个完全重复组
+func sameExactGroup(images []FileInfo, files []int, exact map[string]string) bool {
+	hash, ok := exact[images[files[0]].Path]
+	if !ok {
+		return false
+	}
+	for _, i := range files[1:] {
+		if exact[images[i].Path] != hash {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package dedup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// StateSchemaVersion 增量扫描状态文件的结构版本，结构发生不兼容变化时递增
+const StateSchemaVersion = 1
+
+// StateEntry 状态中记录的一个文件，大小和修改时间都不变时复用其哈希值
+type StateEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Hash    string    `json:"hash"`
+}
+
+// State 增量扫描的状态：已计算过哈希的文件以及上次扫描发现的重复组
+type State struct {
+	SchemaVersion int       `json:"schema_version"`
+	Algorithm     string    `json:"algorithm"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// Files 以路径为键的文件哈希缓存，只包含归档外、计算过哈希的文件。
+	// 路径在本地磁盘上是规范化的绝对路径，扫描时根目录的写法不同也能命中
+	Files map[string]StateEntry `json:"files"`
+	// Groups 以哈希值为键的重复组成员路径，路径的形式与 Files 相同
+	Groups map[string][]string `json:"groups"`
+
+	// fs 状态中的路径所属的文件系统，为 nil 时为本地磁盘
+	fs FileSystem
+}
+
+// GroupChange 与上次扫描相比新出现或增加了成员的重复组
+type GroupChange struct {
+	DuplicateGroup
+	// Added 上次扫描时不在该重复组中的文件路径
+	Added []string `json:"added"`
+}
+
+// LoadState 读取状态文件，文件不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("解析状态文件失败: %v", err)
+	}
+	if st.SchemaVersion != StateSchemaVersion {
+		return nil, fmt.Errorf("不支持的状态文件版本: %d", st.SchemaVersion)
+	}
+	return &st, nil
+}
+
+// Save 将状态写入文件。先写入临时文件再重命名，写入中断时不会损坏原有状态。
+func (st *State) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Changes 返回 result 中新出现的重复组，以及增加了新成员的重复组。
+// 路径按规范化的绝对路径比较；st 为 nil（首次扫描）时返回 nil。
+// 扫描使用 WithFS 指定的文件系统时，st 应当是传给 ScanIncremental 的上次状态。
+func (st *State) Changes(result *Result) []GroupChange {
+	if st == nil {
+		return nil
+	}
+
+	var changes []GroupChange
+	for _, group := range result.DuplicateGroups {
+		before := make(map[string]bool)
+		for _, p := range st.Groups[group.Hash] {
+			before[st.key(p)] = true
+		}
+
+		var added []string
+		for _, file := range group.Files {
+			if !before[st.key(file.Path)] {
+				added = append(added, file.Path)
+			}
+		}
+		if len(added) > 0 {
+			changes = append(changes, GroupChange{DuplicateGroup: group, Added: added})
+		}
+	}
+	return changes
+}
+
+// ScanIncremental 与 Scan 相同，但复用 prev 中记录的哈希值：
+// 大小和修改时间与上次扫描相同的文件不再读取，只有新文件和发生变化的文件才计算哈希。
+// 重复组在新旧文件之间重新计算。
+//
+// 返回本次扫描后的新状态，调用方负责保存。prev 为 nil 或哈希算法不同时等同于完整扫描。
+// Result.CachedFiles 记录复用了哈希值的文件数。
+func (s *Scanner) ScanIncremental(ctx context.Context, prev *State, roots ...string) (*Result, *State, error) {
+	run, err := s.newRun(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if prev != nil {
+		prev.fs = run.fs
+		if prev.Algorithm == s.opts.HashAlgorithm {
+			run.prev = prev
+		}
+	}
+	run.next = &State{
+		SchemaVersion: StateSchemaVersion,
+		Algorithm:     s.opts.HashAlgorithm,
+		Files:         make(map[string]StateEntry),
+		Groups:        make(map[string][]string),
+		fs:            run.fs,
+	}
+
+	groups := []DuplicateGroup{}
+	result, err := run.stream(func(group DuplicateGroup) error {
+		groups = append(groups, group)
+		return nil
+	}, roots...)
+	if err != nil {
+		return nil, nil, err
+	}
+	sortGroups(groups)
+	result.DuplicateGroups = groups
+	result.CachedFiles = run.cached
+
+	next := run.next
+	next.UpdatedAt = time.Now()
+	for _, group := range groups {
+		var paths []string
+		for _, p := range group.Paths() {
+			paths = append(paths, next.key(p))
+		}
+		sort.Strings(paths)
+		next.Groups[group.Hash] = paths
+	}
+	return result, next, nil
+}
+
+// cachedHash 在上次状态中查找大小和修改时间都未变化的文件的哈希值，命中时计入 cached
+func (r *scanRun) cachedHash(file FileInfo) (string, bool) {
+	hash, ok := r.prev.lookup(file)
+	if ok {
+		r.stateMu.Lock()
+		r.cached++
+		r.stateMu.Unlock()
+	}
+	return hash, ok
+}
+
+// storeHash 将新计算的哈希值记录到本次状态
+func (r *scanRun) storeHash(file FileInfo, hash string) {
+	if r.next == nil {
+		return
+	}
+	r.stateMu.Lock()
+	r.next.Files[r.next.key(file.Path)] = StateEntry{Size: file.Size, ModTime: file.ModTime, Hash: hash}
+	r.stateMu.Unlock()
+}
+
+// carryOver 将上次状态中仍然有效的记录带入本次状态，
+// 这样本次没有参与比较的文件（例如大小唯一）下次仍可复用哈希值
+func (r *scanRun) carryOver(file FileInfo) {
+	if hash, ok := r.prev.lookup(file); ok {
+		r.storeHash(file, hash)
+	}
+}
+
+// lookup 返回大小和修改时间都与记录一致的文件的哈希值，st 为 nil 时总是未命中
+func (st *State) lookup(file FileInfo) (string, bool) {
+	if st == nil {
+		return "", false
+	}
+	entry, ok := st.Files[st.key(file.Path)]
+	if !ok || entry.Hash == "" || entry.Size != file.Size || !entry.ModTime.Equal(file.ModTime) {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// key 返回路径在状态中的键：本地磁盘上为规范化的绝对路径
+func (st *State) key(path string) string {
+	fsys := st.fs
+	if fsys == nil {
+		fsys = Local
+	}
+	return fileKey(fsys, FileInfo{Path: path})
+}
@@ -0,0 +1,131 @@
+package dedup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func scanIncremental(t *testing.T, prev *State, roots ...string) (*Result, *State) {
+	t.Helper()
+	scanner, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, next, err := scanner.ScanIncremental(context.Background(), prev, roots...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, next
+}
+
+// rewrite 改写文件内容并设置新的修改时间
+func rewrite(t *testing.T, path, data string, mtime time.Time) {
+	t.Helper()
+	writeFile(t, path, data)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanIncrementalCache(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	writeFile(t, a, "same")
+	writeFile(t, b, "same")
+	writeFile(t, c, "diff")
+
+	result, st := scanIncremental(t, nil, dir)
+	if result.CachedFiles != 0 || len(st.Files) != 3 {
+		t.Fatalf("首次扫描 CachedFiles = %d, Files = %v", result.CachedFiles, st.Files)
+	}
+	if _, ok := st.Files[a]; !ok {
+		t.Errorf("Files = %v, want absolute key %s", st.Files, a)
+	}
+
+	// 根目录换一种写法，状态仍然命中
+	result, st = scanIncremental(t, st, dir+string(filepath.Separator)+".")
+	if result.CachedFiles != 3 || len(result.DuplicateGroups) != 1 {
+		t.Errorf("CachedFiles = %d, groups = %d, want 3 and 1", result.CachedFiles, len(result.DuplicateGroups))
+	}
+
+	// 修改时间变化的文件重新计算
+	rewrite(t, c, "same", time.Now().Add(time.Hour))
+	result, _ = scanIncremental(t, st, dir)
+	if result.CachedFiles != 2 {
+		t.Errorf("CachedFiles = %d, want 2", result.CachedFiles)
+	}
+	if len(result.DuplicateGroups) != 1 || len(result.DuplicateGroups[0].Files) != 3 {
+		t.Errorf("DuplicateGroups = %+v, want a, b and c", result.DuplicateGroups)
+	}
+}
+
+func TestScanIncrementalCarryOver(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeFile(t, a, "same")
+	writeFile(t, b, "same")
+	_, st := scanIncremental(t, nil, dir)
+
+	// a 的大小变得唯一，本次不计算哈希，但记录被带入新状态
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	_, st = scanIncremental(t, st, dir)
+	if _, ok := st.Files[a]; !ok || len(st.Files) != 1 {
+		t.Fatalf("Files = %v, want only %s", st.Files, a)
+	}
+
+	writeFile(t, b, "same")
+	result, _ := scanIncremental(t, st, dir)
+	if result.CachedFiles != 1 {
+		t.Errorf("CachedFiles = %d, want 1 (a)", result.CachedFiles)
+	}
+}
+
+func TestStateChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	writeFile(t, path("a1"), "aaaa")
+	writeFile(t, path("a2"), "aaaa")
+	writeFile(t, path("b1"), "bb")
+	writeFile(t, path("b2"), "bb")
+	writeFile(t, path("c1"), "c")
+	writeFile(t, path("c2"), "c")
+	result, st := scanIncremental(t, nil, dir)
+	if changes := (*State)(nil).Changes(result); changes != nil {
+		t.Errorf("首次扫描 Changes = %+v, want nil", changes)
+	}
+
+	// 新的组和增加了成员的组被报告；减少了成员和消失的组不报告
+	writeFile(t, path("a3"), "aaaa")
+	if err := os.Remove(path("c2")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path("d1"), "ddd")
+	writeFile(t, path("d2"), "ddd")
+	rewrite(t, path("b2"), "xx", time.Now().Add(time.Hour))
+	writeFile(t, path("b3"), "xx")
+
+	result, _ = scanIncremental(t, st, dir)
+	changes := st.Changes(result)
+	got := make(map[string][]string)
+	for _, change := range changes {
+		got[filepath.Base(change.Files[0].Path)] = change.Added
+	}
+	want := map[string][]string{
+		"a1": {path("a3")},
+		"b2": {path("b2"), path("b3")},
+		"d1": {path("d1"), path("d2")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Changes = %v, want %v", got, want)
+	}
+	for first, added := range want {
+		if !equalStrings(got[first], added) {
+			t.Errorf("group %s added %v, want %v", first, got[first], added)
+		}
+	}
+}
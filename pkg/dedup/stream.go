@@ -0,0 +1,403 @@
+package dedup
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xiaozhe/dedupgo/internal/utils/fileutil"
+)
+
+// GroupHandler 接收扫描过程中确认的重复文件组，返回错误时扫描提前终止
+type GroupHandler func(group DuplicateGroup) error
+
+// sizeBucket 大小相同的一批候选文件，只有大小相同的文件才可能内容相同
+type sizeBucket struct {
+	size      int64
+	algorithm string
+	files     []FileInfo
+
+	mu        sync.Mutex
+	hashes    map[string][]FileInfo
+	remaining int
+}
+
+// add 记录一个文件的哈希结果，桶内最后一个文件完成时返回确认的重复组
+func (b *sizeBucket) add(file FileInfo, hash string, err error) []DuplicateGroup {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		file.Hash = ""
+		b.hashes[hash] = append(b.hashes[hash], file)
+	}
+	b.remaining--
+	if b.remaining > 0 {
+		return nil
+	}
+
+	var groups []DuplicateGroup
+	for hash, files := range b.hashes {
+		if len(files) > 1 {
+			sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+			groups = append(groups, DuplicateGroup{
+				Hash:      hash,
+				Algorithm: b.algorithm,
+				Size:      b.size,
+				Files:     files,
+			})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Hash < groups[j].Hash })
+	b.hashes = nil
+	return groups
+}
+
+// Stream 扫描 roots 下的所有文件，每确认一组重复文件就立即交给 handler 处理。
+// 配置了参考目录时，不在 roots 之下的参考目录也会被扫描。
+//
+// 扫描分两个阶段：先遍历目录按文件大小分桶，再只对大小相同的文件计算哈希。
+// 每个桶的文件全部计算完成后，该桶内的重复组即被确认并输出，不必等待整个扫描结束。
+// 桶按文件大小从大到小处理，因此最占空间的重复组最先输出。
+//
+// 注意“流式”只针对输出：只有遍历完所有目录才能确定哪些文件的大小是唯一的，
+// 因此第一组重复要等遍历阶段结束后才会输出，遍历期间所有符合条件的文件信息都保存在内存中，
+// 内存占用与文件数成正比（每个文件约几百字节，千万级文件约需数 GB）。
+// 大小唯一的文件在分桶后即被释放（比较目录或检测相似图片时仍需保留），不会进入哈希阶段。
+// handler 总是在调用 Stream 的协程中串行调用，返回错误时扫描提前终止并返回该错误。
+//
+// 返回的 Result 包含统计信息以及相似图片、重复目录的分析结果，DuplicateGroups 为 nil。
+// 流式输出的组按桶的完成顺序到达，不保证全局有序；需要稳定顺序时使用 Scan。
+// 无法读取的文件会被跳过，遍历目录出错时扫描失败。
+func (s *Scanner) Stream(ctx context.Context, handler GroupHandler, roots ...string) (*Result, error) {
+	run, err := s.newRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return run.stream(handler, roots...)
+}
+
+// stream 执行一次扫描，见 Stream
+func (r *scanRun) stream(handler GroupHandler, roots ...string) (*Result, error) {
+	ctx := r.ctx
+	r.progress.begin()
+	defer r.progress.end()
+
+	buckets, result, err := r.collect(roots...)
+	if err != nil {
+		return nil, err
+	}
+	r.progress.setPhase(PhaseHashing)
+	for _, bucket := range buckets {
+		r.progress.toHash(len(bucket.files), bucket.size*int64(len(bucket.files)))
+	}
+	// handler 出错时工作协程可能仍在运行，因此只关闭归档，不清除 r.zips
+	r.zips = newZipCache()
+	for _, bucket := range buckets {
+		for _, file := range bucket.files {
+			r.zips.add(file)
+		}
+	}
+	defer r.zips.close()
+
+	type hashJob struct {
+		bucket *sizeBucket
+		file   FileInfo
+	}
+
+	jobs := make(chan hashJob)
+	groups := make(chan DuplicateGroup)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < r.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				r.progress.hashing(job.file)
+				hash, err := r.hashFile(job.file)
+				r.progress.hashed(job.file.Size)
+				for _, group := range job.bucket.add(job.file, hash, err) {
+					select {
+					case groups <- group:
+					case <-done:
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, bucket := range buckets {
+			for _, file := range bucket.files {
+				select {
+				case jobs <- hashJob{bucket: bucket, file: file}:
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(groups)
+	}()
+
+	// 记录每个文件所属的完全重复组，供相似图片检测和目录比较使用
+	exact := make(map[string]string)
+	needExact := len(r.images) > 0 || r.opts.Directories
+
+	var handlerErr error
+	for group := range groups {
+		if handlerErr != nil {
+			continue
+		}
+		if err := handler(group); err != nil {
+			handlerErr = err
+			close(done)
+			continue
+		}
+		result.SavedSize += group.Reclaimable()
+		r.progress.group()
+		if needExact {
+			for _, file := range group.Files {
+				exact[file.Path] = group.Hash
+			}
+		}
+	}
+
+	if handlerErr != nil {
+		return nil, handlerErr
+	}
+	if len(r.images) > 0 || r.opts.Directories {
+		r.progress.setPhase(PhaseAnalyzing)
+	}
+	if len(r.images) > 0 {
+		result.SimilarGroups = r.similarImages(r.images, exact)
+	}
+	if r.opts.Directories {
+		result.DuplicateDirs, result.DirSubsets = analyzeDirs(r.fs, r.files, r.skipped, exact)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.progress.setPhase(PhaseDone)
+	return result, nil
+}
+
+// collect 遍历目录并按文件大小分桶，只返回至少包含两个文件的桶。
+//
+// 同一个文件只计入一次：扫描根目录相互重叠、同一目录写法不同或通过符号链接到达时，
+// 同一个文件会被遍历多次，硬链接也是同一份数据。若它们进入同一个重复组，
+// 删除其中一个“副本”就会删掉唯一的一份，因此只保留最先遍历到的路径。
+func (r *scanRun) collect(roots ...string) ([]*sizeBucket, *Result, error) {
+	result := &Result{
+		SchemaVersion: ResultSchemaVersion,
+		Algorithm:     r.opts.HashAlgorithm,
+		ReferenceDirs: r.opts.ReferenceDirs,
+	}
+	sizeMap := make(map[int64][]FileInfo)
+	seen := make(map[string]bool)
+	add := func(file FileInfo) {
+		key := fileKey(r.fs, file)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		if file.InArchive() {
+			file.Reference = r.isReference(file.Archive)
+		} else {
+			file.Reference = r.isReference(file.Path)
+		}
+		if r.next != nil && !file.InArchive() {
+			r.carryOver(file)
+		}
+		if r.onFile != nil {
+			r.onFile(file)
+		}
+		r.progress.found(file)
+		sizeMap[file.Size] = append(sizeMap[file.Size], file)
+		result.TotalFiles++
+		result.TotalSize += file.Size
+
+		if !file.InArchive() {
+			if r.opts.SimilarImages != "" && file.FileType == "image" {
+				r.images = append(r.images, file)
+			}
+			if r.opts.Directories {
+				r.files = append(r.files, file)
+			}
+		}
+	}
+
+	if err := r.walk(r.walkRoots(roots), add); err != nil {
+		return nil, nil, err
+	}
+
+	var buckets []*sizeBucket
+	for size, files := range sizeMap {
+		if len(files) < 2 {
+			continue
+		}
+		buckets = append(buckets, &sizeBucket{
+			size:      size,
+			algorithm: result.Algorithm,
+			files:     files,
+			hashes:    make(map[string][]FileInfo),
+			remaining: len(files),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].size > buckets[j].size })
+
+	return buckets, result, nil
+}
+
+// walk 遍历 roots 下所有符合过滤条件的文件并交给 add，开启归档扫描时归档成员也会交给 add。
+// 根目录先经过规范化，因此 "dir/"、"./dir" 与 "dir" 得到相同的路径，FileInfo.Root 也是规范化后的形式。
+func (r *scanRun) walk(roots []string, add func(FileInfo)) error {
+	for _, root := range roots {
+		root = cleanPath(r.fs, root)
+		// skip 记录被过滤掉的条目，目录比较时包含这些条目的目录不算完全相同
+		skip := func(path string) {
+			if r.opts.Directories {
+				r.skipped = append(r.skipped, FileInfo{Path: path, Root: root})
+			}
+		}
+		err := walkDir(r.fs, root, func(path string, d fs.DirEntry) error {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+
+			// 检查是否匹配排除模式，匹配的目录整体跳过
+			if path != root && r.isExcluded(path) {
+				skip(path)
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				skip(path)
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			// 按设置展开归档，归档文件本身仍作为普通文件参与比较
+			if r.opts.Archives && archiveFormat(path) != "" {
+				r.collectArchive(root, path, info, add)
+			}
+
+			if info.Size() < r.opts.MinSize {
+				skip(path)
+				return nil
+			}
+
+			file := newFileInfo(root, path, info)
+			if len(r.opts.FileTypes) > 0 || r.opts.SimilarImages != "" {
+				fileType, err := r.detectFileType(path)
+				if err != nil {
+					skip(path)
+					return nil
+				}
+				if len(r.opts.FileTypes) > 0 && !containsFold(r.opts.FileTypes, fileType) {
+					skip(path)
+					return nil
+				}
+				file.FileType = fileType
+			}
+
+			add(file)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkRoots 返回需要遍历的根目录：roots 加上不在任何 root 之下的参考目录
+func (r *scanRun) walkRoots(roots []string) []string {
+	walk := append([]string(nil), roots...)
+	for _, ref := range r.opts.ReferenceDirs {
+		covered := false
+		for _, root := range roots {
+			if withinDir(r.fs, root, ref) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			walk = append(walk, ref)
+		}
+	}
+	return walk
+}
+
+// excludedBelow 判断 path 或它在 root 之下的任意一级上级目录是否匹配排除模式
+func (s *Scanner) excludedBelow(root, path string) bool {
+	for p := path; p != root && withinDir(s.opts.FS, root, p); p = parentDir(s.opts.FS, p) {
+		if s.isExcluded(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isReference 判断路径是否位于某个参考目录中
+func (s *Scanner) isReference(path string) bool {
+	for _, ref := range s.opts.ReferenceDirs {
+		if withinDir(s.opts.FS, ref, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded 判断路径的最后一个元素是否匹配排除模式
+func (s *Scanner) isExcluded(path string) bool {
+	for _, pattern := range s.opts.ExcludePatterns {
+		if matched, _ := filepath.Match(pattern, baseName(s.opts.FS, path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// detectFileType 根据文件头部内容识别文件类型
+func (r *scanRun) detectFileType(path string) (string, error) {
+	file, err := r.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return fileutil.DetectFileType(file)
+}
+
+// containsFold 判断列表中是否包含 value，忽略大小写
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package dedup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func scan(t *testing.T, fsys FileSystem, opts []Option, roots ...string) *Result {
+	t.Helper()
+	scanner, err := New(append([]Option{WithFS(fsys)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Scan(context.Background(), roots...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func groupPaths(group DuplicateGroup) []string {
+	var paths []string
+	for _, file := range group.Files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func TestScanOverlappingRoots(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/a.txt":     {Data: []byte("same")},
+		"data/sub/b.txt": {Data: []byte("same")},
+	}
+	result := scan(t, FromFS(fsys), nil, "data", "data/sub", "data/", ".")
+	if result.TotalFiles != 2 {
+		t.Errorf("TotalFiles = %d, want 2", result.TotalFiles)
+	}
+	if len(result.DuplicateGroups) != 1 {
+		t.Fatalf("DuplicateGroups = %+v, want 1 group", result.DuplicateGroups)
+	}
+	want := []string{"data/a.txt", "data/sub/b.txt"}
+	if got := groupPaths(result.DuplicateGroups[0]); !equalStrings(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestScanHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	if err := os.WriteFile(a, []byte("same"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(a, filepath.Join(dir, "b")); err != nil {
+		t.Skipf("不支持硬链接: %v", err)
+	}
+
+	result := scan(t, Local, nil, dir)
+	if len(result.DuplicateGroups) != 0 {
+		t.Errorf("DuplicateGroups = %+v, want none", result.DuplicateGroups)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("TotalFiles = %d, want 1", result.TotalFiles)
+	}
+}
+
+func TestScanGroupsBySizeAndContent(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":      {Data: []byte("same")},
+		"dir/b.txt":  {Data: []byte("same")},
+		"c.txt":      {Data: []byte("diff")},
+		"d.txt":      {Data: []byte("unique size")},
+		"e.txt":      {Data: []byte("longer copy")},
+		"dir/f.txt":  {Data: []byte("longer copy")},
+		"empty1.txt": {Data: nil},
+	}
+	result := scan(t, FromFS(fsys), nil, ".")
+	if result.TotalFiles != len(fsys) {
+		t.Errorf("TotalFiles = %d, want %d", result.TotalFiles, len(fsys))
+	}
+
+	// 大小相同但内容不同的 c.txt 不在任何组中；组按大小从大到小排列，组内按路径排序
+	want := [][]string{{"dir/f.txt", "e.txt"}, {"a.txt", "dir/b.txt"}}
+	if len(result.DuplicateGroups) != len(want) {
+		t.Fatalf("DuplicateGroups = %+v, want %d groups", result.DuplicateGroups, len(want))
+	}
+	for i, group := range result.DuplicateGroups {
+		if got := groupPaths(group); !equalStrings(got, want[i]) {
+			t.Errorf("group %d = %v, want %v", i, got, want[i])
+		}
+	}
+	if want := int64(len("longer copy") + len("same")); result.SavedSize != want {
+		t.Errorf("SavedSize = %d, want %d", result.SavedSize, want)
+	}
+
+	// 小于 MinSize 的文件不参与扫描
+	result = scan(t, FromFS(fsys), []Option{WithMinSize(5)}, ".")
+	if len(result.DuplicateGroups) != 1 || result.DuplicateGroups[0].Size != int64(len("longer copy")) {
+		t.Errorf("DuplicateGroups with MinSize = %+v, want only the larger group", result.DuplicateGroups)
+	}
+}
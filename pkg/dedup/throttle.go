@@ -0,0 +1,75 @@
+package dedup
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// throttleChunk 限速读取时单次读取的最大字节数，
+// 较小的分块可以让多个工作协程更均匀地分享带宽
+const throttleChunk = 64 * 1024
+
+// rateLimiter 令牌桶限速器，可被多个工作协程共享
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒产生的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter 创建每秒 rate 个令牌的限速器，rate <= 0 时返回 nil 表示不限速
+func newRateLimiter(rate int64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// wait 消耗 n 个令牌，令牌不足时阻塞到令牌补足为止。
+// 令牌允许透支，因此并发调用者会按顺序排队而不会超过总速率。
+func (l *rateLimiter) wait(n int64) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// throttledReader 按字节限速的读取器
+type throttledReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := t.r.Read(p)
+	t.limiter.wait(int64(n))
+	return n, err
+}
@@ -0,0 +1,35 @@
+package dedup
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// MoveToTrash 将文件移动到系统回收站
+func MoveToTrash(filePath string) error {
+	// 在 macOS 上使用 osascript 将文件移动到回收站
+	if strings.HasPrefix(runtime.GOOS, "darwin") {
+		script := fmt.Sprintf(`tell app "Finder" to delete POSIX file "%s"`, filePath)
+		cmd := exec.Command("osascript", "-e", script)
+		return cmd.Run()
+	}
+
+	// 在 Windows 上使用 PowerShell 将文件移动到回收站
+	if runtime.GOOS == "windows" {
+		script := fmt.Sprintf(`Add-Type -AssemblyName Microsoft.VisualBasic
+[Microsoft.VisualBasic.FileIO.FileSystem]::DeleteFile('%s','OnlyErrorDialogs','SendToRecycleBin')`, filePath)
+		cmd := exec.Command("powershell", "-Command", script)
+		return cmd.Run()
+	}
+
+	// 在 Linux 上使用 gio 将文件移动到回收站
+	if runtime.GOOS == "linux" {
+		cmd := exec.Command("gio", "trash", filePath)
+		return cmd.Run()
+	}
+
+	// 如果以上方法都不适用，则返回错误
+	return fmt.Errorf("不支持在当前操作系统(%s)上使用回收站功能", runtime.GOOS)
+}